@@ -0,0 +1,163 @@
+package api
+
+import (
+	"chatweb/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler 处理修改密码、修改邮箱和注销账号等请求
+type AccountHandler struct {
+	accountService *service.AccountService // 账号服务
+}
+
+// NewAccountHandler 构造函数，初始化 AccountHandler
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// ChangePasswordRequest 修改密码请求体
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"` // 与注册一致，至少6个字符
+}
+
+// ChangePassword 修改密码，成功后其他设备的登录失效，返回当前设备使用的新 token
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.accountService.ChangePassword(c.Request.Context(), userID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Password changed successfully",
+		"data": gin.H{
+			"token": token,
+		},
+	})
+}
+
+// ChangeEmailRequest 修改邮箱请求体，有密码的用户提供 password，没有密码的（SSO）用户提供身份确认验证码 code
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// RequestReauthCode 没有密码的（SSO）用户申请身份确认验证码，验证码发送到当前邮箱
+func (h *AccountHandler) RequestReauthCode(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.accountService.RequestReauthCode(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent to the current email"})
+}
+
+// RequestEmailChange 申请修改邮箱，验证码发送到新邮箱
+func (h *AccountHandler) RequestEmailChange(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.RequestEmailChange(c.Request.Context(), userID, req.Password, req.Code, req.NewEmail); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent to the new email"})
+}
+
+// VerifyEmailChange 使用验证码确认修改邮箱
+func (h *AccountHandler) VerifyEmailChange(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"` // 邮件中的验证码
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.accountService.ConfirmEmailChange(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Email changed successfully",
+		"data": gin.H{
+			"email": user.Email,
+		},
+	})
+}
+
+// DeleteAccount 申请注销账号，冷静期内重新登录可撤销
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"` // 需要再次输入密码确认
+		Code     string `json:"code"`     // 没有密码的（SSO）用户使用身份确认验证码
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deleteAt, err := h.accountService.ScheduleDeletion(c.Request.Context(), userID, req.Password, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Account scheduled for deletion, log in again before the deadline to cancel",
+		"data": gin.H{
+			"deletion_scheduled_at": deleteAt,
+		},
+	})
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"chatweb/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler 处理管理员相关的请求
+type AdminHandler struct {
+	userService  *service.UserService  // 用户服务
+	adminService *service.AdminService // 管理后台服务
+}
+
+// NewAdminHandler 构造函数，初始化 AdminHandler
+func NewAdminHandler(userService *service.UserService, adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{
+		userService:  userService,
+		adminService: adminService,
+	}
+}
+
+// actorRole 获取当前操作者的角色（由 Auth 中间件写入）
+func actorRole(c *gin.Context) model.UserRole {
+	return model.UserRole(c.GetString("role"))
+}
+
+// ListUsers 分页列出和搜索用户，支持按关键字、角色和禁用状态过滤
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	filter := repository.UserListFilter{
+		Query: c.Query("query"),
+		Role:  model.UserRole(c.Query("role")),
+	}
+	if disabled := c.Query("disabled"); disabled != "" {
+		value, err := strconv.ParseBool(disabled)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "disabled must be true or false"})
+			return
+		}
+		filter.Disabled = &value
+	}
+
+	users, total, err := h.adminService.ListUsers(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"users": users,
+			"total": total,
+			"page":  page,
+		},
+	})
+}
+
+// UnlockUser 解除用户因多次登录失败导致的锁定
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	adminID := c.GetString("userID")
+
+	// 从 URL 参数获取被解锁的用户 ID
+	userID := c.Param("id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
+
+	// 可选：同时解除某个 IP 的锁定
+	var req struct {
+		IP string `json:"ip"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.userService.UnlockAccount(c.Request.Context(), adminID, userID, req.IP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// DisableUser 禁用账号，请求体可选 {"reason": "..."}
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"max=200"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.adminService.DisableUser(c.Request.Context(), c.GetString("userID"), actorRole(c), c.Param("id"), req.Reason, c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User disabled successfully"})
+}
+
+// EnableUser 解除账号禁用
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	if err := h.adminService.EnableUser(c.Request.Context(), c.GetString("userID"), actorRole(c), c.Param("id"), c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled successfully"})
+}
+
+// ForceLogout 强制用户在所有设备上下线
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	if err := h.adminService.ForceLogout(c.Request.Context(), c.GetString("userID"), actorRole(c), c.Param("id"), c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out successfully"})
+}
+
+// ChangeRole 修改用户角色
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.adminService.ChangeRole(c.Request.Context(), c.GetString("userID"), actorRole(c), c.Param("id"), model.UserRole(req.Role), c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role changed successfully"})
+}
+
+// GetStats 获取系统统计数据
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	stats, err := h.adminService.GetStats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": stats})
+}
+
+// BroadcastRequest 系统广播请求体，user_ids 为空表示发送给所有用户
+type BroadcastRequest struct {
+	Title   string   `json:"title" binding:"required,max=100"`
+	Content string   `json:"content" binding:"required,max=2000"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// Broadcast 向全部或指定用户发送系统通知
+func (h *AdminHandler) Broadcast(c *gin.Context) {
+	var req BroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sent, err := h.adminService.Broadcast(c.Request.Context(), c.GetString("userID"), req.UserIDs, req.Title, req.Content, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Broadcast sent successfully", "data": gin.H{"sent": sent}})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"chatweb/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlockHandler 处理黑名单相关的请求
+type BlockHandler struct {
+	blockService *service.BlockService // 黑名单服务
+}
+
+// NewBlockHandler 构造函数，初始化 BlockHandler
+func NewBlockHandler(blockService *service.BlockService) *BlockHandler {
+	return &BlockHandler{
+		blockService: blockService,
+	}
+}
+
+// BlockUser 将指定用户加入当前用户的黑名单
+func (h *BlockHandler) BlockUser(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"` // 要拉黑的用户ID
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.blockService.Block(c.Request.Context(), c.GetString("userID"), req.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "User blocked successfully"})
+}
+
+// UnblockUser 将指定用户移出当前用户的黑名单
+func (h *BlockHandler) UnblockUser(c *gin.Context) {
+	if err := h.blockService.Unblock(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "User unblocked successfully"})
+}
+
+// ListBlockedUsers 获取当前用户的黑名单
+func (h *BlockHandler) ListBlockedUsers(c *gin.Context) {
+	blocked, err := h.blockService.ListBlocked(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"blocked_users": blocked,
+		},
+	})
+}
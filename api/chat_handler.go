@@ -3,11 +3,11 @@ package api
 import (
 	"chatweb/internal/model"
 	"chatweb/internal/service"
-	"chatweb/pkg/event"
 	"chatweb/pkg/websocketM"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -30,14 +30,14 @@ func NewChatHandler(
 	notificationService *service.NotificationService,
 	groupService *service.GroupService,
 	onlineService *service.OnlineService,
-	eventBus *event.EventBus,
+	wsHub *websocketM.Hub,
 ) *ChatHandler {
 	return &ChatHandler{
 		messageService:      messageService,
 		notificationService: notificationService,
 		groupService:        groupService,
 		onlineService:       onlineService,
-		wsHub:               websocketM.NewHub(eventBus), // 初始化 WebSocket Hub
+		wsHub:               wsHub, // 与 main 中运行的 Hub 共用
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024 * 1024, // 1MB
 			WriteBufferSize: 1024 * 1024, // 1MB
@@ -50,14 +50,12 @@ func NewChatHandler(
 
 // HandleWebSocket 处理 WebSocket 连接的建立和消息处理
 func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
-	log.Println("WebSocket connection established")
-	userID := c.DefaultQuery("userId", "")
-	log.Println(userID)
+	// 连接身份以握手时认证的 token 为准
+	userID := c.GetString("userID")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	log.Printf("User %s connected via WebSocket", userID)
 
 	// 升级 HTTP 连接为 WebSocket 连接
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -67,7 +65,7 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 	}
 
 	// 创建 WebSocket 客户端并注册到 Hub
-	client := websocketM.NewClient(h.wsHub, conn, userID, h.onlineService)
+	client := websocketM.NewClient(h.wsHub, conn, userID, h.onlineService, h.messageService)
 	h.wsHub.Register(client)
 
 	log.Printf("User %s connected", userID)
@@ -86,16 +84,22 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 	}
 
 	var req struct {
-		Type       string `json:"type" binding:"required"`    // 消息类型
-		Content    string `json:"content" binding:"required"` // 消息内容
-		ReceiverID string `json:"receiver_id"`                // 接收者 ID
-		GroupID    string `json:"group_id"`                   // 群组 ID
+		Type       string `json:"type" binding:"required"` // 消息类型
+		Content    string `json:"content"`                 // 消息内容，图片和文件消息由服务端填写
+		FileID     string `json:"file_id"`                 // 图片和文件消息引用的文件 ID
+		ReceiverID string `json:"receiver_id"`             // 接收者 ID
+		GroupID    string `json:"group_id"`                // 群组 ID
+		ChannelID  string `json:"channel_id"`              // 群频道 ID，为空时发到默认频道
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Content == "" && req.FileID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content or file_id is required"})
+		return
+	}
 
 	// 转换为 ObjectID
 	senderObjID, err := primitive.ObjectIDFromHex(userID)
@@ -106,19 +110,65 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 
 	// 构建消息对象
 	message := &model.Message{
-		Type:      model.MessageType(req.Type),
-		Content:   req.Content,
-		SenderID:  senderObjID,
-		Status:    "sent",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Type:     model.MessageType(req.Type),
+		Content:  req.Content,
+		SenderID: senderObjID,
+	}
+	if req.FileID != "" {
+		if message.FileID, err = primitive.ObjectIDFromHex(req.FileID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+			return
+		}
+	}
+	if req.GroupID != "" {
+		if message.GroupID, err = primitive.ObjectIDFromHex(req.GroupID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+			return
+		}
+		if req.ChannelID != "" {
+			if message.ChannelID, err = primitive.ObjectIDFromHex(req.ChannelID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+				return
+			}
+		}
+	} else if req.ReceiverID != "" {
+		if message.ReceiverID, err = primitive.ObjectIDFromHex(req.ReceiverID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receiver ID"})
+			return
+		}
 	}
 
-	// 保存消息
-	if err := h.messageService.CreateMessage(c.Request.Context(), message); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// 保存消息，被拉黑时只返回通用错误
+	if err := h.messageService.SendMessage(c.Request.Context(), message); err != nil {
+		var postErr *service.GroupPostError
+		if errors.As(err, &postErr) {
+			status := http.StatusForbidden
+			if postErr.Code == service.GroupPostSlowMode {
+				status = http.StatusTooManyRequests
+			}
+			c.JSON(status, gin.H{"error": err.Error(), "code": postErr.Code, "retry_after": postErr.RetryAfterSeconds()})
+			return
+		}
+		if err == service.ErrActionNotAllowed || err == service.ErrNotGroupMember || err == service.ErrGroupDissolved || err == service.ErrMentionAllDenied || err == service.ErrChannelAccessDenied || err == service.ErrFileAccessDenied {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+
+	// 私聊消息实时推送给在线的接收者，群消息推送给所在频道的在线成员
+	if data, err := json.Marshal(message); err == nil {
+		if message.GroupID.IsZero() {
+			h.wsHub.SendToUser(message.ReceiverID.Hex(), data)
+		} else if recipients, err := h.messageService.GroupMessageRecipients(c.Request.Context(), message); err == nil {
+			h.wsHub.BroadcastToUsers(recipients, data)
+		} else {
+			log.Printf("Failed to resolve recipients of message %s: %v", message.ID.Hex(), err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message sent successfully", "data": message})
 }
 
 // getMessagesById 根据userID获取与当前用户的所有消息
@@ -188,7 +238,8 @@ func (h *ChatHandler) getAllLastMessages(c *gin.Context) {
 // getGroupMessages 获取群组聊天记录
 func (h *ChatHandler) getGroupMessages(c *gin.Context) {
 	var requestBody struct {
-		GroupID string `json:"groupId"` // 解析 JSON 请求体中的 groupId
+		GroupID   string `json:"groupId"`   // 解析 JSON 请求体中的 groupId
+		ChannelID string `json:"channelId"` // 频道 ID，为空时获取默认频道的消息
 	}
 
 	// 解析 JSON 数据
@@ -201,9 +252,13 @@ func (h *ChatHandler) getGroupMessages(c *gin.Context) {
 
 	log.Println("groupId:", groupId)
 
-	// 调用服务层获取群聊记录
-	messages, err := h.messageService.GetGroupMessages(c.Request.Context(), groupId)
+	// 调用服务层获取群聊记录，只有群成员和私有频道成员可以查看
+	messages, err := h.messageService.GetGroupMessages(c.Request.Context(), groupId, requestBody.ChannelID, c.GetString("userID"))
 	if err != nil {
+		if err == service.ErrNotGroupMember || err == service.ErrChannelAccessDenied {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
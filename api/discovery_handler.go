@@ -0,0 +1,57 @@
+package api
+
+import (
+	"chatweb/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiscoveryHandler 处理通讯录匹配请求
+type DiscoveryHandler struct {
+	discoveryService *service.DiscoveryService // 通讯录匹配服务
+}
+
+// NewDiscoveryHandler 构造函数，初始化 DiscoveryHandler
+func NewDiscoveryHandler(discoveryService *service.DiscoveryService) *DiscoveryHandler {
+	return &DiscoveryHandler{
+		discoveryService: discoveryService,
+	}
+}
+
+// GetSalt 返回客户端计算通讯录哈希所需的盐和规则
+func (h *DiscoveryHandler) GetSalt(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"salt":      h.discoveryService.Salt(),
+			"algorithm": "sha256(salt + normalized), hex encoded",
+		},
+	})
+}
+
+// Discover 上传通讯录哈希，返回其中已注册的用户
+func (h *DiscoveryHandler) Discover(c *gin.Context) {
+	var req struct {
+		Hashes []string `json:"hashes" binding:"required"` // 规范化后的手机号或邮箱的加盐哈希
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contacts, err := h.discoveryService.Discover(c.Request.Context(), c.GetString("userID"), req.Hashes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"contacts": contacts,
+		},
+	})
+}
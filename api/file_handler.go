@@ -2,14 +2,8 @@ package api
 
 import (
 	"chatweb/internal/service"
-	"fmt"
-	"io"
-	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -42,38 +36,10 @@ func (h *FileHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	// 检查文件大小，限制为 20MB
-	if file.Size > 20*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large"})
-		return
-	}
-
-	// 检查文件扩展名是否允许
-	ext := filepath.Ext(file.Filename)
-	allowedExts := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".mp4":  true,
-		".avi":  true,
-		".mp3":  true,
-		".wav":  true,
-		".doc":  true,
-		".docx": true,
-		".pdf":  true,
-	}
-
-	// 如果文件类型不在允许范围内，返回错误
-	if !allowedExts[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File type not allowed"})
-		return
-	}
-
-	// 调用服务层方法处理文件上传
+	// 调用服务层方法处理文件上传，大小和类型由服务层按配置校验
 	uploadedFile, err := h.fileService.UploadFile(c.Request.Context(), file, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -127,86 +93,226 @@ func (h *FileHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
 }
 
-func (h *FileHandler) UploadFile(c *gin.Context) {
-	log.Print("uploading...")
+// PresignUpload 签发预签名上传 URL，客户端直传 MinIO 后调用 ConfirmUpload 完成上传
+func (h *FileHandler) PresignUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
 
-	// 获取文件
-	file, fileHeader, err := c.Request.FormFile("file")
+	upload, err := h.fileService.PresignUpload(c.Request.Context(), userID, req.Name, req.ContentType, req.Size)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File upload failed"})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upload": upload})
+}
+
+// ConfirmUpload 确认预签名上传已完成，校验通过后返回文件信息
+func (h *FileHandler) ConfirmUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	defer file.Close()
-
-	// 获取文件类型和大小
-	fileType := fileHeader.Header.Get("Content-Type")
-	fileSize := fileHeader.Size
-
-	log.Print("fileType: ", fileType)
-	log.Print("fileSize: ", fileSize)
-
-	// 生成唯一文件名
-	filename := fmt.Sprintf("%d-%s", time.Now().Unix(), fileHeader.Filename)
-	log.Print("filename: ", filename)
-
-	// 设置保存文件的路径
-	var filePath string
-	if fileType == "image/jpeg" || fileType == "image/png" {
-		if fileSize < 2*1024*1024 {
-			filePath = "./uploads/avator/" + filename
-		} else {
-			filePath = "./uploads/files/" + filename
-		}
-	} else if fileType == "audio/mpeg" || fileType == "audio/wav" {
-		if fileSize < 60*1024*1024 {
-			filePath = "./uploads/audio/" + filename
-		} else {
-			filePath = "./uploads/files/" + filename
-		}
-	} else if fileType == "video/mp4" || fileType == "video/webm" {
-		if fileSize < 60*1024*1024 {
-			filePath = "./uploads/video/" + filename
-		} else {
-			filePath = "./uploads/files/" + filename
-		}
-	} else {
-		filePath = "./uploads/files/" + filename
-	}
-
-	// 确保目录存在
-	err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+
+	file, err := h.fileService.ConfirmUpload(c.Request.Context(), c.Param("id"), userID)
 	if err != nil {
-		log.Print("Failed to create directory: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create directory"})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// 创建目标文件
-	dst, err := os.Create(filePath)
+	c.JSON(http.StatusOK, gin.H{"file": file})
+}
+
+// Download 校验访问权限后返回短时有效的预签名下载 URL
+func (h *FileHandler) Download(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	url, expiresAt, err := h.fileService.GetDownloadURL(c.Request.Context(), c.Param("id"), userID)
 	if err != nil {
-		log.Print("Failed to create file: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url, "expires_at": expiresAt})
+}
+
+// InitResumableUpload 创建分片上传，返回分片大小、分片数和上传 ID
+func (h *FileHandler) InitResumableUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	defer dst.Close()
 
-	// 重新设置文件指针，确保文件流未被提前读取完
-	file.Seek(0, io.SeekStart)
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
 
-	// 将文件内容从上传的 file 写入到目标文件
-	_, err = io.Copy(dst, file)
+	progress, err := h.fileService.InitResumableUpload(c.Request.Context(), userID, req.Name, req.ContentType, req.Size)
 	if err != nil {
-		log.Print("Failed to write file: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write file"})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// 生成文件的访问URL
-	fileURL := strings.TrimPrefix(filePath, "./uploads") // 去掉本地路径前缀，形成 URL 路径
-	fileURL = "http://localhost:8080/uploads" + fileURL
+	c.JSON(http.StatusOK, gin.H{"upload": progress})
+}
+
+// UploadPart 上传一个分片，请求体为分片内容，X-Checksum-SHA256 请求头为分片内容的 SHA-256（十六进制）
+func (h *FileHandler) UploadPart(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
-	log.Print("File uploaded successfully: ", fileURL)
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrInvalidPart.Error()})
+		return
+	}
+	// 分片大小必须预先确定，不接受分块传输编码
+	if c.Request.ContentLength <= 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return
+	}
+
+	progress, err := h.fileService.UploadPart(
+		c.Request.Context(), c.Param("id"), userID, number,
+		c.Request.Body, c.Request.ContentLength, c.GetHeader("X-Checksum-SHA256"),
+	)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upload": progress})
+}
+
+// GetUploadProgress 查询分片上传的进度
+func (h *FileHandler) GetUploadProgress(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	progress, err := h.fileService.GetUploadProgress(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upload": progress})
+}
+
+// CompleteResumableUpload 合并所有分片，完成上传后返回文件信息
+func (h *FileHandler) CompleteResumableUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	file, err := h.fileService.CompleteResumableUpload(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"file": file})
+}
+
+// AbortResumableUpload 取消分片上传
+func (h *FileHandler) AbortResumableUpload(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.fileService.AbortResumableUpload(c.Request.Context(), c.Param("id"), userID); err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload aborted"})
+}
+
+// uploadErrorStatus 经服务端中转的上传只有大小和类型是客户端错误，其余按服务端错误处理
+func uploadErrorStatus(err error) int {
+	switch err {
+	case service.ErrFileTooLarge, service.ErrFileTypeNotAllowed:
+		return fileErrorStatus(err)
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// fileErrorStatus 把文件服务的错误映射为 HTTP 状态码
+func fileErrorStatus(err error) int {
+	switch err {
+	case service.ErrFileTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case service.ErrFileAccessDenied:
+		return http.StatusForbidden
+	case service.ErrUploadNotFound:
+		return http.StatusNotFound
+	case service.ErrUploadExpired:
+		return http.StatusGone
+	case service.ErrUploadIncomplete, service.ErrUploadMismatch:
+		return http.StatusConflict
+	case service.ErrChecksumMismatch:
+		return http.StatusUnprocessableEntity
+	case service.ErrStorageUnsupported:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// UploadFile 旧版上传接口，保留原来的返回格式，和 Upload 一样经过认证并由文件服务写入存储后端
+func (h *FileHandler) UploadFile(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File upload failed"})
+		return
+	}
+
+	uploadedFile, err := h.fileService.UploadFile(c.Request.Context(), file, userID)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	// 返回文件的URL
-	c.JSON(http.StatusOK, gin.H{"url": fileURL})
+	c.JSON(http.StatusOK, gin.H{"url": uploadedFile.URL, "file": uploadedFile})
 }
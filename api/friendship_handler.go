@@ -1,8 +1,13 @@
 package api
 
 import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
 	"chatweb/internal/service"
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,11 +25,12 @@ func NewFriendshipHandler(friendshipService *service.FriendshipService) *Friends
 	}
 }
 
-// SendRequest 处理发送好友请求的操作
+// SendRequest 处理发送好友请求的操作，对方同意后才会成为好友
 func (h *FriendshipHandler) SendRequest(c *gin.Context) {
 	// 定义请求参数结构体，绑定请求体中的 JSON 数据
 	var req struct {
 		FriendID string `json:"user_id" binding:"required"` // 目标好友的用户ID
+		Greeting string `json:"greeting" binding:"max=100"` // 验证消息，可选
 	}
 
 	// 绑定请求数据，如果出现错误则返回 400 错误
@@ -37,32 +43,98 @@ func (h *FriendshipHandler) SendRequest(c *gin.Context) {
 	userID := c.GetString("userID")
 
 	// 调用服务层方法发送好友请求
-	if err := h.friendshipService.SendFriendRequest(c.Request.Context(), userID, req.FriendID); err != nil {
-		// 如果出错，返回 500 错误和错误信息
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
+	request, err := h.friendshipService.SendFriendRequest(c.Request.Context(), userID, req.FriendID, req.Greeting)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
 			"message": err.Error(),
 		})
 		return
 	}
 
-	// 返回成功的消息
+	// 返回成功的消息，对方已向自己发出请求时 status 直接为 accepted
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Friend request sent successfully",
+		"data": gin.H{
+			"request": request,
+		},
 	})
 }
 
+// ListIncomingRequests 获取收到的好友请求，可通过 status 参数过滤
+func (h *FriendshipHandler) ListIncomingRequests(c *gin.Context) {
+	requests, err := h.friendshipService.ListIncomingRequests(c.Request.Context(), c.GetString("userID"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": gin.H{"requests": requests}})
+}
+
+// ListOutgoingRequests 获取发出的好友请求，可通过 status 参数过滤
+func (h *FriendshipHandler) ListOutgoingRequests(c *gin.Context) {
+	requests, err := h.friendshipService.ListOutgoingRequests(c.Request.Context(), c.GetString("userID"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": gin.H{"requests": requests}})
+}
+
+// AcceptRequest 同意好友请求
+func (h *FriendshipHandler) AcceptRequest(c *gin.Context) {
+	h.handleRequestAction(c, h.friendshipService.AcceptRequest, "Friend request accepted")
+}
+
+// RejectRequest 拒绝好友请求
+func (h *FriendshipHandler) RejectRequest(c *gin.Context) {
+	h.handleRequestAction(c, h.friendshipService.RejectRequest, "Friend request rejected")
+}
+
+// CancelRequest 撤回自己发出的好友请求
+func (h *FriendshipHandler) CancelRequest(c *gin.Context) {
+	h.handleRequestAction(c, h.friendshipService.CancelRequest, "Friend request cancelled")
+}
+
+// handleRequestAction 处理同意、拒绝、撤回请求的公共逻辑
+func (h *FriendshipHandler) handleRequestAction(
+	c *gin.Context,
+	action func(ctx context.Context, userID, requestID string) (*model.FriendRequest, error),
+	message string,
+) {
+	request, err := action(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, repository.ErrFriendRequestNotPending) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": message, "data": gin.H{"request": request}})
+}
+
 // GetFriendsList 处理获取当前用户的好友列表的请求
 func (h *FriendshipHandler) GetFriendsList(c *gin.Context) {
 	// 获取当前用户的 ID，确保用户已认证
 	userID := c.GetString("userID")
 
+	// 可选的过滤条件和排序：?tag=<标签ID>&starred=true&sort=name|recent
+	filter := service.FriendListFilter{
+		TagID:   c.Query("tag"),
+		Starred: c.Query("starred") == "true",
+		Sort:    c.Query("sort"),
+	}
+
 	// 调用服务层方法获取好友列表
-	friends, err := h.friendshipService.GetFriendsList(c.Request.Context(), userID)
+	friends, err := h.friendshipService.GetFriendsList(c.Request.Context(), userID, filter)
 	if err != nil {
-		// 如果获取好友列表出错，返回 500 错误和错误信息
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// 如果获取好友列表出错，返回错误信息
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -93,3 +165,101 @@ func (h *FriendshipHandler) DeleteFriend(c *gin.Context) {
 		"message": "ok",
 	})
 }
+
+// UpdateFriendMeta 修改当前用户对好友的备注、标签、星标和描述
+func (h *FriendshipHandler) UpdateFriendMeta(c *gin.Context) {
+	var req service.FriendMetaUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := h.friendshipService.UpdateFriendMeta(c.Request.Context(), c.GetString("userID"), c.Param("id"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Friend updated successfully", "data": gin.H{"meta": meta}})
+}
+
+// ListTags 获取当前用户的好友标签
+func (h *FriendshipHandler) ListTags(c *gin.Context) {
+	tags, err := h.friendshipService.ListTags(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": gin.H{"tags": tags}})
+}
+
+// CreateTag 创建好友标签
+func (h *FriendshipHandler) CreateTag(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"` // 标签名称
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.friendshipService.CreateTag(c.Request.Context(), c.GetString("userID"), req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Tag created successfully", "data": gin.H{"tag": tag}})
+}
+
+// RenameTag 修改好友标签名称
+func (h *FriendshipHandler) RenameTag(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"` // 新的标签名称
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.friendshipService.RenameTag(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Tag updated successfully", "data": gin.H{"tag": tag}})
+}
+
+// DeleteTag 删除好友标签
+func (h *FriendshipHandler) DeleteTag(c *gin.Context) {
+	if err := h.friendshipService.DeleteTag(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Tag deleted successfully"})
+}
+
+// GetSuggestions 分页获取“可能认识的人”
+func (h *FriendshipHandler) GetSuggestions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	suggestions, total, err := h.friendshipService.GetSuggestions(c.Request.Context(), c.GetString("userID"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"suggestions": suggestions,
+			"total":       total,
+			"page":        page,
+		},
+	})
+}
@@ -2,9 +2,14 @@ package api
 
 import (
 	"chatweb/internal/model"
+	"chatweb/internal/repository"
 	"chatweb/internal/service"
+	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -68,7 +73,6 @@ func (h *GroupHandler) Create(c *gin.Context) {
 		Name:        req.Name,
 		Description: req.Description,
 		CreatorID:   creatorID,
-		Members:     []primitive.ObjectID{creatorID}, // 默认创建者为群组成员
 	}
 
 	// 调用服务层创建群组
@@ -154,21 +158,27 @@ func (h *GroupHandler) Join(c *gin.Context) {
 
 	GroupID := req.GroupID
 	UserIDs := req.UserIDs
+	actorID := c.GetString("userID")
 
-	// 遍历用户列表，逐个加入群组
-	var failedUsers []string
+	// 遍历用户列表，逐个加入群组，对方隐私设置不允许被拉进群时计入失败
+	// 群组开启入群审核时只创建入群申请，计入待审核
+	var failedUsers, pendingUsers []string
 	for _, userID := range UserIDs {
-		if err := h.groupService.JoinGroup(c.Request.Context(), GroupID, userID); err != nil {
+		request, err := h.groupService.JoinGroup(c.Request.Context(), GroupID, actorID, userID)
+		if err != nil {
 			failedUsers = append(failedUsers, userID) // 记录失败的用户 ID
+		} else if request != nil {
+			pendingUsers = append(pendingUsers, userID) // 记录等待审核的用户 ID
 		}
 	}
 
 	// 返回批量加入结果
-	if len(failedUsers) > 0 {
+	if len(failedUsers) > 0 || len(pendingUsers) > 0 {
 		c.JSON(http.StatusPartialContent, gin.H{
-			"message":       "Some users failed to join",
+			"message":       "Some users failed to join or are pending approval",
 			"failed_users":  failedUsers,
-			"success_count": len(req.UserIDs) - len(failedUsers),
+			"pending_users": pendingUsers,
+			"success_count": len(req.UserIDs) - len(failedUsers) - len(pendingUsers),
 		})
 		return
 	}
@@ -193,7 +203,7 @@ func (h *GroupHandler) Leave(c *gin.Context) {
 		return
 	}
 
-	// 调用服务层方法离开群组
+	// 调用服务层方法离开群组，群主需要先转让群主
 	if err := h.groupService.LeaveGroup(c.Request.Context(), groupID, userID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -202,3 +212,529 @@ func (h *GroupHandler) Leave(c *gin.Context) {
 	// 返回成功离开群组的消息
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully left the group"})
 }
+
+// groupErrorStatus 将群组操作的错误映射为 HTTP 状态码
+func groupErrorStatus(err error) int {
+	switch err {
+	case service.ErrGroupPermissionDenied, service.ErrNotGroupMember, service.ErrActionNotAllowed, service.ErrChannelAccessDenied:
+		return http.StatusForbidden
+	case repository.ErrGroupInviteUnavailable, repository.ErrGroupJoinNotPending, repository.ErrAlreadyPinned, repository.ErrGroupFull, service.ErrGroupDissolved,
+		repository.ErrChannelNameTaken:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Update 修改群名称、描述和入群审核设置，需要管理员或群主权限
+func (h *GroupHandler) Update(c *gin.Context) {
+	var req service.GroupInfoUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.groupService.UpdateGroupInfo(c.Request.Context(), c.Param("id"), c.GetString("userID"), &req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group updated successfully", "group": group})
+}
+
+// KickMember 将成员移出群组
+func (h *GroupHandler) KickMember(c *gin.Context) {
+	if err := h.groupService.KickMember(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("user_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed from the group"})
+}
+
+// PromoteMember 将成员设为管理员，只有群主可以操作
+func (h *GroupHandler) PromoteMember(c *gin.Context) {
+	if err := h.groupService.PromoteMember(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("user_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member promoted to admin"})
+}
+
+// DemoteAdmin 取消管理员，只有群主可以操作
+func (h *GroupHandler) DemoteAdmin(c *gin.Context) {
+	if err := h.groupService.DemoteAdmin(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("user_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admin demoted to member"})
+}
+
+// TransferOwnership 将群主转让给另一个成员
+func (h *GroupHandler) TransferOwnership(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"` // 新群主的用户 ID
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := h.groupService.TransferOwnership(c.Request.Context(), c.Param("id"), c.GetString("userID"), req.UserID); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transferred"})
+}
+
+// CreateInviteLink 创建邀请链接，可以设置有效期（小时）和最多使用次数，0 表示不限
+func (h *GroupHandler) CreateInviteLink(c *gin.Context) {
+	var req struct {
+		ExpiresInHours int `json:"expires_in_hours"` // 有效期，单位小时
+		MaxUses        int `json:"max_uses"`         // 最多使用次数
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	invite, err := h.groupService.CreateInviteLink(c.Request.Context(), c.Param("id"), c.GetString("userID"), service.InviteLinkOptions{
+		ExpiresIn: time.Duration(req.ExpiresInHours) * time.Hour,
+		MaxUses:   req.MaxUses,
+	})
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite link created", "invite": invite})
+}
+
+// ListInviteLinks 获取群组中未撤销的邀请链接
+func (h *GroupHandler) ListInviteLinks(c *gin.Context) {
+	invites, err := h.groupService.ListInviteLinks(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// RevokeInvite 撤销邀请链接或直接邀请
+func (h *GroupHandler) RevokeInvite(c *gin.Context) {
+	if err := h.groupService.RevokeInvite(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("invite_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+// InviteFriends 直接邀请好友入群，好友会收到群组通知
+func (h *GroupHandler) InviteFriends(c *gin.Context) {
+	var req struct {
+		UserIDs []string `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	result, err := h.groupService.InviteFriends(c.Request.Context(), c.Param("id"), c.GetString("userID"), req.UserIDs)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitations sent", "result": result})
+}
+
+// AcceptInvite 通过邀请码加入群组，群组需要审核时返回入群申请
+func (h *GroupHandler) AcceptInvite(c *gin.Context) {
+	request, err := h.groupService.AcceptInvite(c.Request.Context(), c.Param("code"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if request != nil {
+		c.JSON(http.StatusAccepted, gin.H{"message": "Join request submitted for approval", "request": request})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully joined the group"})
+}
+
+// ListJoinRequests 获取群组中待审核的入群申请
+func (h *GroupHandler) ListJoinRequests(c *gin.Context) {
+	requests, err := h.groupService.ListJoinRequests(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// ApproveJoinRequest 通过入群申请
+func (h *GroupHandler) ApproveJoinRequest(c *gin.Context) {
+	h.reviewJoinRequest(c, true)
+}
+
+// RejectJoinRequest 拒绝入群申请
+func (h *GroupHandler) RejectJoinRequest(c *gin.Context) {
+	h.reviewJoinRequest(c, false)
+}
+
+func (h *GroupHandler) reviewJoinRequest(c *gin.Context, approve bool) {
+	request, err := h.groupService.ReviewJoinRequest(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("request_id"), approve)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Join request " + string(request.Status), "request": request})
+}
+
+// UpdateSettings 修改全员禁言和慢速模式，需要管理员或群主权限
+func (h *GroupHandler) UpdateSettings(c *gin.Context) {
+	var req service.GroupSettingsUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.groupService.UpdateGroupSettings(c.Request.Context(), c.Param("id"), c.GetString("userID"), &req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group settings updated successfully", "group": group})
+}
+
+// SetNotificationsMuted 当前用户屏蔽或恢复群组通知，@ 提及不受影响
+func (h *GroupHandler) SetNotificationsMuted(c *gin.Context) {
+	var req struct {
+		Muted *bool `json:"muted" binding:"required"` // 是否屏蔽群组通知
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.groupService.SetNotificationsMuted(c.Request.Context(), c.Param("id"), c.GetString("userID"), *req.Muted); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group notification settings updated"})
+}
+
+// MuteMember 禁言成员一段时间
+func (h *GroupHandler) MuteMember(c *gin.Context) {
+	var req struct {
+		DurationSeconds int `json:"duration_seconds" binding:"required"` // 禁言时长，60 秒到 30 天
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	until, err := h.groupService.MuteMember(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("user_id"), time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member muted", "muted_until": until})
+}
+
+// UnmuteMember 解除成员禁言
+func (h *GroupHandler) UnmuteMember(c *gin.Context) {
+	if err := h.groupService.UnmuteMember(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("user_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member unmuted"})
+}
+
+// PostAnnouncement 发布群公告，需要管理员或群主权限
+func (h *GroupHandler) PostAnnouncement(c *gin.Context) {
+	var req struct {
+		Content string `json:"content" binding:"required"` // 公告内容，最多 2000 个字符
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	announcement, err := h.groupService.PostAnnouncement(c.Request.Context(), c.Param("id"), c.GetString("userID"), req.Content)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement posted", "announcement": announcement})
+}
+
+// ListAnnouncements 获取群公告列表
+func (h *GroupHandler) ListAnnouncements(c *gin.Context) {
+	announcements, err := h.groupService.ListAnnouncements(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"announcements": announcements})
+}
+
+// AckAnnouncement 确认已阅读群公告
+func (h *GroupHandler) AckAnnouncement(c *gin.Context) {
+	if err := h.groupService.AckAnnouncement(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("announcement_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement acknowledged"})
+}
+
+// GetAnnouncementReads 获取群公告的已读列表，需要管理员或群主权限
+func (h *GroupHandler) GetAnnouncementReads(c *gin.Context) {
+	status, err := h.groupService.GetAnnouncementReadStatus(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("announcement_id"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"acks": status.Acks, "unread": status.Unread})
+}
+
+// DeleteAnnouncement 删除群公告，需要管理员或群主权限
+func (h *GroupHandler) DeleteAnnouncement(c *gin.Context) {
+	if err := h.groupService.DeleteAnnouncement(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("announcement_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement deleted"})
+}
+
+// ListPins 获取群组的置顶消息
+func (h *GroupHandler) ListPins(c *gin.Context) {
+	pins, err := h.groupService.ListPins(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pins": pins})
+}
+
+// PinMessage 置顶群消息，需要管理员或群主权限
+func (h *GroupHandler) PinMessage(c *gin.Context) {
+	var req struct {
+		MessageID string `json:"message_id" binding:"required"` // 要置顶的消息 ID
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.groupService.PinMessage(c.Request.Context(), c.Param("id"), c.GetString("userID"), req.MessageID); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message pinned"})
+}
+
+// UnpinMessage 取消置顶群消息，需要管理员或群主权限
+func (h *GroupHandler) UnpinMessage(c *gin.Context) {
+	if err := h.groupService.UnpinMessage(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("message_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message unpinned"})
+}
+
+// ListChannels 获取当前用户在群组中可以读写的频道及各频道的未读消息数
+func (h *GroupHandler) ListChannels(c *gin.Context) {
+	channels, err := h.groupService.ListChannels(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"channels": channels})
+}
+
+// CreateChannel 创建频道，需要管理员或群主权限
+func (h *GroupHandler) CreateChannel(c *gin.Context) {
+	var req service.GroupChannelCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel, err := h.groupService.CreateChannel(c.Request.Context(), c.Param("id"), c.GetString("userID"), &req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel created successfully", "channel": channel})
+}
+
+// UpdateChannel 修改频道名称或主题，需要管理员或群主权限
+func (h *GroupHandler) UpdateChannel(c *gin.Context) {
+	var req service.GroupChannelUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel, err := h.groupService.UpdateChannel(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("channel_id"), &req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel updated successfully", "channel": channel})
+}
+
+// DeleteChannel 删除频道及其消息，需要管理员或群主权限
+func (h *GroupHandler) DeleteChannel(c *gin.Context) {
+	if err := h.groupService.DeleteChannel(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("channel_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel deleted successfully"})
+}
+
+// AddChannelMembers 将群成员加入私有频道，需要管理员或群主权限
+func (h *GroupHandler) AddChannelMembers(c *gin.Context) {
+	var req struct {
+		UserIDs []string `json:"user_ids" binding:"required"` // 要加入频道的群成员 ID
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel, err := h.groupService.AddChannelMembers(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("channel_id"), req.UserIDs)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel members added", "channel": channel})
+}
+
+// RemoveChannelMember 将成员移出私有频道，成员也可以自己退出
+func (h *GroupHandler) RemoveChannelMember(c *gin.Context) {
+	if err := h.groupService.RemoveChannelMember(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("channel_id"), c.Param("user_id")); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel member removed"})
+}
+
+// MarkChannelRead 将频道中当前用户未读的消息全部标记为已读
+func (h *GroupHandler) MarkChannelRead(c *gin.Context) {
+	marked, err := h.groupService.MarkChannelRead(c.Request.Context(), c.Param("id"), c.GetString("userID"), c.Param("channel_id"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel marked as read", "marked": marked})
+}
+
+// groupAvatarExts 群头像允许的图片扩展名
+var groupAvatarExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// UploadAvatar 上传群头像，需要管理员或群主权限
+func (h *GroupHandler) UploadAvatar(c *gin.Context) {
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	// 群头像限制为 5MB 以内的图片
+	if file.Size > 5*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large"})
+		return
+	}
+	if !groupAvatarExts[strings.ToLower(filepath.Ext(file.Filename))] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File type not allowed"})
+		return
+	}
+
+	group, err := h.groupService.UpdateGroupAvatar(c.Request.Context(), c.Param("id"), c.GetString("userID"), file)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group avatar updated", "group": group})
+}
+
+// RemoveAvatar 清除群头像，需要管理员或群主权限
+func (h *GroupHandler) RemoveAvatar(c *gin.Context) {
+	group, err := h.groupService.RemoveGroupAvatar(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group avatar removed", "group": group})
+}
+
+// Dissolve 解散群组，只有群主可以操作
+func (h *GroupHandler) Dissolve(c *gin.Context) {
+	var req struct {
+		Purge bool `json:"purge"` // 保留期结束后是否删除群组的消息和文件
+	}
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	group, err := h.groupService.DissolveGroup(c.Request.Context(), c.Param("id"), c.GetString("userID"), req.Purge)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Group dissolved", "group": group})
+}
+
+// Export 以 JSON 文件导出群聊历史，群成员在群组解散后也可以导出
+func (h *GroupHandler) Export(c *gin.Context) {
+	export, err := h.groupService.ExportGroupHistory(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=group-%s.json", export.Group.ID.Hex()))
+	c.JSON(http.StatusOK, export)
+}
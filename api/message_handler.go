@@ -3,6 +3,7 @@ package api
 import (
 	"chatweb/internal/service"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -96,7 +97,7 @@ func (h *MessageHandler) GetUnreadMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"messages": messages})
 }
 
-// GetGroupUnreadMessages 获取指定群组中的未读消息
+// GetGroupUnreadMessages 获取指定群组中的未读消息，可通过 channel_id 只查看某个频道
 func (h *MessageHandler) GetGroupUnreadMessages(c *gin.Context) {
 	// 获取当前用户的 ID，确保用户已认证
 	userID := c.GetString("userID")
@@ -112,15 +113,22 @@ func (h *MessageHandler) GetGroupUnreadMessages(c *gin.Context) {
 		return
 	}
 
+	// 可选的频道 ID，为空时统计所有可读频道
+	channelID := c.Query("channel_id")
+
 	// 获取群组中的未读消息
-	messages, err := h.messageService.GetGroupUnreadMessages(c.Request.Context(), groupID, userID)
+	messages, err := h.messageService.GetGroupUnreadMessages(c.Request.Context(), groupID, channelID, userID)
 	if err != nil {
+		if err == service.ErrNotGroupMember || err == service.ErrChannelAccessDenied {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 获取群组未读消息的数量
-	unreadCount, err := h.messageService.GetGroupUnreadCount(c.Request.Context(), groupID, userID)
+	unreadCount, err := h.messageService.GetGroupUnreadCount(c.Request.Context(), groupID, channelID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -158,3 +166,45 @@ func (h *MessageHandler) MarkGroupMessageAsRead(c *gin.Context) {
 	// 返回成功消息
 	c.JSON(http.StatusOK, gin.H{"message": "Group message marked as read"})
 }
+
+// GetMentions 分页获取提及当前用户的群消息
+func (h *MessageHandler) GetMentions(c *gin.Context) {
+	// 获取当前用户的 ID，确保用户已认证
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// 分页参数，非法值交给服务层使用默认值
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	messages, err := h.messageService.GetMentions(c.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回提及消息
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// GetUnreadMentionCounts 获取各群组中提及当前用户的未读消息数
+func (h *MessageHandler) GetUnreadMentionCounts(c *gin.Context) {
+	// 获取当前用户的 ID，确保用户已认证
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	counts, err := h.messageService.GetUnreadMentionCounts(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回按群组统计的未读提及数
+	c.JSON(http.StatusOK, gin.H{"counts": counts})
+}
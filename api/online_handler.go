@@ -21,8 +21,8 @@ func NewOnlineHandler(onlineService *service.OnlineService) *OnlineHandler {
 
 // GetOnlineUsers：获取当前在线的用户列表
 func (h *OnlineHandler) GetOnlineUsers(c *gin.Context) {
-	// 调用服务层获取在线用户列表
-	onlineUsers, err := h.onlineService.GetOnlineUsers(c.Request.Context())
+	// 调用服务层获取当前用户可见的在线用户列表
+	onlineUsers, err := h.onlineService.GetVisibleOnlineUsers(c.Request.Context(), c.GetString("userID"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}) // 错误处理
 		return
@@ -41,8 +41,8 @@ func (h *OnlineHandler) CheckUserOnline(c *gin.Context) {
 		return
 	}
 
-	// 调用服务层检查用户是否在线
-	isOnline := h.onlineService.IsUserOnline(userID)
+	// 调用服务层检查用户是否在线，存在拉黑关系时显示为离线
+	isOnline := h.onlineService.IsUserOnlineFor(c.Request.Context(), c.GetString("userID"), userID)
 
 	// 返回用户在线状态
 	c.JSON(http.StatusOK, gin.H{"is_online": isOnline})
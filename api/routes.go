@@ -2,37 +2,85 @@ package api
 
 import (
 	"chatweb/config"
+	"chatweb/internal/model"
 	"chatweb/middleware"
+	"chatweb/pkg/ratelimit"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InitRoutes(r *gin.Engine, cfg *config.Config, handlers *Handlers) {
+func InitRoutes(r *gin.Engine, cfg *config.Config, handlers *Handlers, sessions middleware.SessionValidator) {
+	// 注册接口按 IP 限流
+	registerLimiter := ratelimit.NewLimiter(cfg.Security.RegisterLimitPerHour, time.Hour)
+	// 通讯录匹配按用户限流，防止枚举手机号和邮箱
+	discoverLimiter := ratelimit.NewLimiter(cfg.Contact.DiscoverLimitPerHour, time.Hour)
+	// 按手机号/邮箱精确查找用户同样按用户限流
+	lookupLimiter := ratelimit.NewLimiter(cfg.Contact.LookupLimitPerHour, time.Hour)
+
 	// 公开路由
 	public := r.Group("/api/v1")
 	{
-		public.POST("/register", handlers.User.Register)
+		public.POST("/register", middleware.RateLimit(registerLimiter), handlers.User.Register)
 		public.POST("/login", handlers.User.Login)
-		// WebSocket连接
-		public.GET("/ws", handlers.Chat.HandleWebSocket)
-		public.POST("/user/uploadAvatar", handlers.User.UploadAvatar)
-		public.POST("/file/uploadFile", handlers.File.UploadFile)
+		// WebSocket连接，token 可以放在 Authorization 头或 token 查询参数中
+		public.GET("/ws", middleware.WebSocketAuth(cfg, sessions), handlers.Chat.HandleWebSocket)
+
+		// 单点登录，未启用 OIDC 时不注册
+		if handlers.SSO != nil {
+			public.GET("/auth/oidc/login", handlers.SSO.Login)
+			public.GET("/auth/oidc/callback", handlers.SSO.Callback)
+		}
 	}
 
 	// 需要认证的路由
 	authorized := r.Group("/api/v1")
-	authorized.Use(middleware.Auth(cfg))
+	authorized.Use(middleware.Auth(cfg, sessions))
 	{
 		// 用户相关
 		authorized.GET("/user/profile", handlers.User.GetProfile)
-		authorized.PUT("/user/updateprofile", handlers.User.UpdateProfile)
-		authorized.GET("/user/search", handlers.User.SearchUser)
+		authorized.PATCH("/user/profile", handlers.User.UpdateProfile)
+		authorized.PUT("/user/updateprofile", handlers.User.UpdateProfile) // 兼容旧客户端
+		authorized.POST("/user/uploadAvatar", handlers.User.UploadAvatar)
+		authorized.GET("/user/search", middleware.RateLimitByUser(lookupLimiter), handlers.User.SearchUser)
+		authorized.GET("/user/directory", handlers.User.SearchDirectory)
 		authorized.POST("/user/getUsersByIDs", handlers.User.GetUsersByIDs)
 
+		// 隐私设置
+		authorized.GET("/user/privacy", handlers.User.GetPrivacy)
+		authorized.PATCH("/user/privacy", handlers.User.UpdatePrivacy)
+
+		// 通讯录匹配
+		authorized.GET("/contacts/discovery/salt", handlers.Discovery.GetSalt)
+		authorized.POST("/contacts/discover", middleware.RateLimitByUser(discoverLimiter), handlers.Discovery.Discover)
+
+		// 黑名单相关
+		authorized.GET("/user/blocks", handlers.Block.ListBlockedUsers)
+		authorized.POST("/user/blocks", handlers.Block.BlockUser)
+		authorized.DELETE("/user/blocks/:id", handlers.Block.UnblockUser)
+
+		// 账号安全相关
+		authorized.PUT("/user/password", handlers.Account.ChangePassword)
+		authorized.POST("/user/email/change", handlers.Account.RequestEmailChange)
+		authorized.POST("/user/email/verify", handlers.Account.VerifyEmailChange)
+		authorized.DELETE("/user/account", handlers.Account.DeleteAccount)
+		authorized.POST("/user/reauth/code", handlers.Account.RequestReauthCode)
+
 		// 好友相关路由
 		authorized.POST("/friendship/request", handlers.Friendship.SendRequest)
+		authorized.GET("/friendship/requests/incoming", handlers.Friendship.ListIncomingRequests)
+		authorized.GET("/friendship/requests/outgoing", handlers.Friendship.ListOutgoingRequests)
+		authorized.POST("/friendship/requests/:id/accept", handlers.Friendship.AcceptRequest)
+		authorized.POST("/friendship/requests/:id/reject", handlers.Friendship.RejectRequest)
+		authorized.POST("/friendship/requests/:id/cancel", handlers.Friendship.CancelRequest)
 		authorized.GET("/friendship/list", handlers.Friendship.GetFriendsList)
+		authorized.GET("/friendship/suggestions", handlers.Friendship.GetSuggestions)
 		authorized.POST("/friendship/delete", handlers.Friendship.DeleteFriend)
+		authorized.PATCH("/friendship/friends/:id", handlers.Friendship.UpdateFriendMeta)
+		authorized.GET("/friendship/tags", handlers.Friendship.ListTags)
+		authorized.POST("/friendship/tags", handlers.Friendship.CreateTag)
+		authorized.PUT("/friendship/tags/:id", handlers.Friendship.RenameTag)
+		authorized.DELETE("/friendship/tags/:id", handlers.Friendship.DeleteTag)
 
 		// 聊天相关
 		authorized.POST("/chat/message", handlers.Chat.SendMessage)
@@ -42,6 +90,8 @@ func InitRoutes(r *gin.Engine, cfg *config.Config, handlers *Handlers) {
 		authorized.PUT("/messages/:id/read", handlers.Message.MarkAsRead)
 		authorized.PUT("/messages/read", handlers.Message.MarkMultipleAsRead)
 		authorized.GET("/messages/unread", handlers.Message.GetUnreadMessages)
+		authorized.GET("/messages/mentions", handlers.Message.GetMentions)
+		authorized.GET("/messages/mentions/unread", handlers.Message.GetUnreadMentionCounts)
 		authorized.GET("/groups/:group_id/messages/unread", handlers.Message.GetGroupUnreadMessages)
 		authorized.PUT("/groups/messages/:id/read", handlers.Message.MarkGroupMessageAsRead)
 		authorized.POST("/messages/delete", handlers.Chat.deleteMessage)
@@ -53,11 +103,66 @@ func InitRoutes(r *gin.Engine, cfg *config.Config, handlers *Handlers) {
 		authorized.GET("/group/:id", handlers.Group.Get)
 		authorized.POST("/group/join", handlers.Group.Join)
 		authorized.POST("/group/:id/leave", handlers.Group.Leave)
+		authorized.POST("/group/:id/dissolve", handlers.Group.Dissolve)
+		authorized.GET("/group/:id/export", handlers.Group.Export)
+		authorized.PATCH("/group/:id", handlers.Group.Update)
+		authorized.POST("/group/:id/avatar", handlers.Group.UploadAvatar)
+		authorized.DELETE("/group/:id/avatar", handlers.Group.RemoveAvatar)
+
+		// 群成员管理
+		authorized.DELETE("/group/:id/members/:user_id", handlers.Group.KickMember)
+		authorized.POST("/group/:id/admins/:user_id", handlers.Group.PromoteMember)
+		authorized.DELETE("/group/:id/admins/:user_id", handlers.Group.DemoteAdmin)
+		authorized.POST("/group/:id/transfer", handlers.Group.TransferOwnership)
+
+		// 全员禁言、成员禁言与慢速模式
+		authorized.PATCH("/group/:id/settings", handlers.Group.UpdateSettings)
+		authorized.POST("/group/:id/members/:user_id/mute", handlers.Group.MuteMember)
+		authorized.DELETE("/group/:id/members/:user_id/mute", handlers.Group.UnmuteMember)
+
+		// 群公告与置顶消息
+		authorized.PUT("/group/:id/notifications", handlers.Group.SetNotificationsMuted)
+		authorized.GET("/group/:id/announcements", handlers.Group.ListAnnouncements)
+		authorized.POST("/group/:id/announcements", handlers.Group.PostAnnouncement)
+		authorized.DELETE("/group/:id/announcements/:announcement_id", handlers.Group.DeleteAnnouncement)
+		authorized.POST("/group/:id/announcements/:announcement_id/ack", handlers.Group.AckAnnouncement)
+		authorized.GET("/group/:id/announcements/:announcement_id/reads", handlers.Group.GetAnnouncementReads)
+		authorized.GET("/group/:id/pins", handlers.Group.ListPins)
+		authorized.POST("/group/:id/pins", handlers.Group.PinMessage)
+		authorized.DELETE("/group/:id/pins/:message_id", handlers.Group.UnpinMessage)
+
+		// 群频道
+		authorized.GET("/group/:id/channels", handlers.Group.ListChannels)
+		authorized.POST("/group/:id/channels", handlers.Group.CreateChannel)
+		authorized.PATCH("/group/:id/channels/:channel_id", handlers.Group.UpdateChannel)
+		authorized.DELETE("/group/:id/channels/:channel_id", handlers.Group.DeleteChannel)
+		authorized.POST("/group/:id/channels/:channel_id/members", handlers.Group.AddChannelMembers)
+		authorized.DELETE("/group/:id/channels/:channel_id/members/:user_id", handlers.Group.RemoveChannelMember)
+		authorized.PUT("/group/:id/channels/:channel_id/read", handlers.Group.MarkChannelRead)
+
+		// 群邀请与入群审核
+		authorized.GET("/group/:id/invites", handlers.Group.ListInviteLinks)
+		authorized.POST("/group/:id/invites", handlers.Group.CreateInviteLink)
+		authorized.DELETE("/group/:id/invites/:invite_id", handlers.Group.RevokeInvite)
+		authorized.POST("/group/:id/invitations", handlers.Group.InviteFriends)
+		authorized.POST("/group/invites/:code/accept", handlers.Group.AcceptInvite)
+		authorized.GET("/group/:id/join-requests", handlers.Group.ListJoinRequests)
+		authorized.POST("/group/:id/join-requests/:request_id/approve", handlers.Group.ApproveJoinRequest)
+		authorized.POST("/group/:id/join-requests/:request_id/reject", handlers.Group.RejectJoinRequest)
 
 		// 文件相关路由
+		authorized.POST("/file/uploadFile", handlers.File.UploadFile)
 		authorized.POST("/files", handlers.File.Upload)
 		authorized.GET("/files", handlers.File.GetUserFiles)
 		authorized.DELETE("/files/:id", handlers.File.Delete)
+		authorized.POST("/files/presign", handlers.File.PresignUpload)
+		authorized.POST("/files/uploads/:id/confirm", handlers.File.ConfirmUpload)
+		authorized.GET("/files/:id/download", handlers.File.Download)
+		authorized.POST("/files/resumable", handlers.File.InitResumableUpload)
+		authorized.GET("/files/resumable/:id", handlers.File.GetUploadProgress)
+		authorized.PUT("/files/resumable/:id/parts/:number", handlers.File.UploadPart)
+		authorized.POST("/files/resumable/:id/complete", handlers.File.CompleteResumableUpload)
+		authorized.DELETE("/files/resumable/:id", handlers.File.AbortResumableUpload)
 
 		// 通知相关路由
 		authorized.GET("/notifications", handlers.Notification.GetNotifications)
@@ -70,6 +175,22 @@ func InitRoutes(r *gin.Engine, cfg *config.Config, handlers *Handlers) {
 		authorized.GET("/online/users", handlers.Online.GetOnlineUsers)
 		authorized.GET("/online/users/:id", handlers.Online.CheckUserOnline)
 	}
+
+	// 管理员路由，协管员可以管理普通用户，修改角色和系统广播仅限管理员
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.Auth(cfg, sessions), middleware.RequireRole(model.RoleModerator, model.RoleAdmin))
+	{
+		admin.GET("/users", handlers.Admin.ListUsers)
+		admin.POST("/users/:id/unlock", handlers.Admin.UnlockUser)
+		admin.POST("/users/:id/disable", handlers.Admin.DisableUser)
+		admin.POST("/users/:id/enable", handlers.Admin.EnableUser)
+		admin.POST("/users/:id/logout", handlers.Admin.ForceLogout)
+		admin.GET("/stats", handlers.Admin.GetStats)
+
+		adminOnly := middleware.RequireRole(model.RoleAdmin)
+		admin.PUT("/users/:id/role", adminOnly, handlers.Admin.ChangeRole)
+		admin.POST("/notifications/broadcast", adminOnly, handlers.Admin.Broadcast)
+	}
 }
 
 // Handlers 结构体用于组织所有的处理器
@@ -82,4 +203,9 @@ type Handlers struct {
 	Online       *OnlineHandler
 	Message      *MessageHandler
 	Friendship   *FriendshipHandler
+	Block        *BlockHandler
+	Discovery    *DiscoveryHandler
+	Admin        *AdminHandler
+	Account      *AccountHandler
+	SSO          *SSOHandler
 }
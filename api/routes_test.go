@@ -0,0 +1,56 @@
+package api
+
+import (
+	"chatweb/config"
+	"chatweb/internal/service"
+	"chatweb/pkg/jwt"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allowAllSessions 测试用的会话校验，所有 token 都视为有效
+type allowAllSessions struct{}
+
+func (allowAllSessions) ValidateSession(ctx context.Context, userID string, tokenVersion int) error {
+	return nil
+}
+
+// TestMentionRoutes 提及接口必须挂到 MessageHandler 上，请求应由服务层处理而不是因处理器为空而 panic
+func TestMentionRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.JWT.Secret = "test-secret"
+	cfg.Security.RegisterLimitPerHour = 1
+	cfg.Contact.DiscoverLimitPerHour = 1
+	cfg.Contact.LookupLimitPerHour = 1
+
+	messageService := service.NewMessageService(nil, nil, nil, nil, nil, nil, nil, nil)
+	handlers := &Handlers{Message: NewMessageHandler(messageService)}
+
+	// 不使用 Recovery 中间件，处理器 panic 时测试直接失败
+	r := gin.New()
+	InitRoutes(r, cfg, handlers, allowAllSessions{})
+
+	// 非法的用户 ID 在访问数据库前就被服务层拒绝
+	token, err := jwt.GenerateToken("not-an-object-id", "user", 0, cfg.JWT.Secret, 1)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/messages/mentions", "/api/v1/messages/mentions/unread"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "invalid user ID") {
+			t.Errorf("GET %s: got %d %s, want the service error", path, w.Code, w.Body.String())
+		}
+	}
+}
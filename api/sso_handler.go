@@ -0,0 +1,82 @@
+package api
+
+import (
+	"chatweb/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ssoStateCookie = "oidc_state"        // 保存 state 的 Cookie，用于防止登录 CSRF
+	ssoCookiePath  = "/api/v1/auth/oidc" // Cookie 只在 SSO 路由下发送
+	ssoCookieAge   = 600                 // 与服务端 state 有效期一致（秒）
+)
+
+// SSOHandler 处理 OIDC 单点登录请求
+type SSOHandler struct {
+	ssoService *service.SSOService // SSO 服务
+}
+
+// NewSSOHandler 构造函数，初始化 SSOHandler
+func NewSSOHandler(ssoService *service.SSOService) *SSOHandler {
+	return &SSOHandler{
+		ssoService: ssoService,
+	}
+}
+
+// Login 发起 SSO 登录，返回身份提供方的授权地址，由前端跳转
+func (h *SSOHandler) Login(c *gin.Context) {
+	authURL, state, err := h.ssoService.StartLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	// state 同时写入 HttpOnly Cookie，回调时校验请求来自发起登录的同一个浏览器
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, state, ssoCookieAge, ssoCookiePath, "", c.Request.TLS != nil, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"auth_url": authURL,
+		},
+	})
+}
+
+// Callback 处理身份提供方的回调，成功后返回与密码登录相同格式的数据
+func (h *SSOHandler) Callback(c *gin.Context) {
+	// 身份提供方返回的错误（如用户拒绝授权）
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errCode, "description": c.Query("error_description")})
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state and code are required"})
+		return
+	}
+
+	cookieState, err := c.Cookie(ssoStateCookie)
+	if err != nil || cookieState != state {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid login state"})
+		return
+	}
+	c.SetCookie(ssoStateCookie, "", -1, ssoCookiePath, "", c.Request.TLS != nil, true)
+
+	token, user, err := h.ssoService.HandleCallback(c.Request.Context(), state, code, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Login successful",
+		"data":    loginData(token, user),
+	})
+}
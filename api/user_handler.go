@@ -3,19 +3,14 @@ package api
 import (
 	"chatweb/internal/model"   // 引入模型层
 	"chatweb/internal/service" // 引入服务层
-	"io"
+	"errors"
+	"math"
 	"net/http" // HTTP 状态码
+	"strconv"
 
 	"github.com/gin-gonic/gin" // Gin 框架
 )
 
-// 允许的图片格式
-var allowedExtensions = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-}
-
 // UserHandler：处理与用户相关的 API 请求
 type UserHandler struct {
 	userService *service.UserService // 引入用户服务
@@ -87,8 +82,21 @@ func (h *UserHandler) Login(c *gin.Context) {
 	}
 
 	// 调用服务层的登录方法
-	token, user, err := h.userService.Login(c.Request.Context(), req.Email, req.Password)
+	token, user, err := h.userService.Login(c.Request.Context(), req.Email, req.Password, c.ClientIP())
 	if err != nil {
+		// 失败次数过多，返回 429 并告知需要等待的时间
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       err.Error(),
+				"locked":      throttled.Locked,
+				"retry_after": retryAfter,
+			})
+			return
+		}
+
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()}) // 登录失败，返回未授权错误
 		return
 	}
@@ -97,18 +105,23 @@ func (h *UserHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Login successful",
-		"data": map[string]interface{}{
-			"token":      token,
-			"user_id":    user.ID.Hex(),
-			"username":   user.Username,
-			"email":      user.Email,
-			"phone":      user.Phone,
-			"created_at": user.CreatedAt,
-			"avatar":     user.Avatar,
-		},
+		"data":    loginData(token, user),
 	})
 }
 
+// loginData 登录成功后返回给客户端的数据，密码登录和 SSO 登录共用
+func loginData(token string, user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"token":      token,
+		"user_id":    user.ID.Hex(),
+		"username":   user.Username,
+		"email":      user.Email,
+		"phone":      user.Phone,
+		"created_at": user.CreatedAt,
+		"avatar":     user.Avatar,
+	}
+}
+
 // GetProfile：获取用户的个人资料
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID := c.GetString("userID")
@@ -142,7 +155,7 @@ func (h *UserHandler) GetUsersByIDs(c *gin.Context) {
 	}
 
 	// 调用服务层获取用户信息
-	users, failedIDs, err := h.userService.GetUsersByIDs(c.Request.Context(), req.UserIDs)
+	users, failedIDs, err := h.userService.GetUsersByIDs(c.Request.Context(), c.GetString("userID"), req.UserIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -162,7 +175,7 @@ func (h *UserHandler) GetUsersByIDs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"users": users})
 }
 
-// UpdateProfile：更新用户的个人资料
+// UpdateProfile：更新用户的个人资料，只修改请求中出现的字段
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID := c.GetString("userID")
 	if userID == "" {
@@ -170,34 +183,72 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// 请求体结构体，包含昵称、头像和状态
-	var req struct {
-		Nickname string `json:"nickname"`
-		Avatar   string `json:"avatar"`
-		Status   string `json:"status"`
+	// 绑定请求体，未知字段会被忽略
+	var req service.ProfileUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}) // 如果请求体格式不正确，返回错误
+		return
+	}
+
+	// 调用服务层校验并更新用户信息
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}) // 校验或更新失败，返回错误
+		return
+	}
+
+	// 返回更新后的资料
+	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully", "data": user})
+}
+
+// GetPrivacy：获取当前用户的隐私设置
+func (h *UserHandler) GetPrivacy(c *gin.Context) {
+	privacy, err := h.userService.GetPrivacy(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 绑定请求体
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": privacy})
+}
+
+// UpdatePrivacy：修改当前用户的隐私设置，只修改请求中出现的字段
+func (h *UserHandler) UpdatePrivacy(c *gin.Context) {
+	var req service.PrivacyUpdate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}) // 如果请求体格式不正确，返回错误
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 准备更新的数据
-	updates := map[string]interface{}{
-		"nickname": req.Nickname,
-		"avatar":   req.Avatar,
-		"status":   req.Status,
+	privacy, err := h.userService.UpdatePrivacy(c.Request.Context(), c.GetString("userID"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 调用服务层更新用户信息
-	if err := h.userService.UpdateUser(c.Request.Context(), userID, updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}) // 更新失败，返回错误
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Privacy settings updated successfully", "data": privacy})
+}
+
+// SearchDirectory：按用户名和昵称模糊搜索用户，分页返回公开资料
+func (h *UserHandler) SearchDirectory(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	entries, total, err := h.userService.SearchDirectory(c.Request.Context(), c.GetString("userID"), c.Query("q"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 返回成功响应
-	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"users": entries,
+			"total": total,
+			"page":  page,
+		},
+	})
 }
 
 // SearchUser：根据查询条件搜索用户
@@ -209,7 +260,7 @@ func (h *UserHandler) SearchUser(c *gin.Context) {
 	}
 
 	// 调用服务层进行用户搜索
-	user, err := h.userService.SearchUser(c.Request.Context(), query)
+	user, err := h.userService.SearchUser(c.Request.Context(), c.GetString("userID"), query)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"code":    404,
@@ -228,33 +279,24 @@ func (h *UserHandler) SearchUser(c *gin.Context) {
 	})
 }
 
-// 处理头像上传
+// UploadAvatar 处理头像上传，只能修改当前登录用户的头像
 func (h *UserHandler) UploadAvatar(c *gin.Context) {
-	userID := c.PostForm("userId") // 👈 现在可以正常获取
-
+	userID := c.GetString("userID")
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "userId is required"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	file, header, err := c.Request.FormFile("avatar")
+	file, err := c.FormFile("avatar")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
 		return
 	}
-	defer file.Close()
-
-	// 读取文件数据
-	fileData, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-		return
-	}
 
 	// 处理头像上传
-	fileURL, err := h.userService.UploadAvatar(c.Request.Context(), userID, header.Filename, fileData)
+	fileURL, err := h.userService.UploadAvatar(c.Request.Context(), userID, file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -2,20 +2,32 @@ package config
 
 import (
 	"log"
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	MongoDB MongoDBConfig `mapstructure:"mongodb"`
-	Redis   RedisConfig   `mapstructure:"redis"`
-	JWT     JWTConfig     `mapstructure:"jwt"`
-	MinIO   MinIOConfig   `mapstructure:"minio"`
+	Server   ServerConfig   `mapstructure:"server"`
+	MongoDB  MongoDBConfig  `mapstructure:"mongodb"`
+	Redis    RedisConfig    `mapstructure:"redis"`
+	JWT      JWTConfig      `mapstructure:"jwt"`
+	MinIO    MinIOConfig    `mapstructure:"minio"`
+	Security SecurityConfig `mapstructure:"security"`
+	Mail     MailConfig     `mapstructure:"mail"`
+	Account  AccountConfig  `mapstructure:"account"`
+	OIDC     OIDCConfig     `mapstructure:"oidc"`
+	Friend   FriendConfig   `mapstructure:"friend"`
+	Contact  ContactConfig  `mapstructure:"contact"`
+	Group    GroupConfig    `mapstructure:"group"`
+	File     FileConfig     `mapstructure:"file"`
+	Storage  StorageConfig  `mapstructure:"storage"`
 }
 
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port           string   `mapstructure:"port"`
+	Mode           string   `mapstructure:"mode"`
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // 可信反向代理的 IP 或 CIDR，为空时不信任 X-Forwarded-For，直接使用连接地址
 }
 
 type MongoDBConfig struct {
@@ -40,6 +52,82 @@ type MinIOConfig struct {
 	SecretKey string `mapstructure:"secret_key"`
 	Bucket    string `mapstructure:"bucket"`
 	UseSSL    bool   `mapstructure:"use_ssl"`
+	// PublicEndpoint 客户端访问 MinIO 的地址，预签名 URL 用它签名；为空时使用 endpoint
+	PublicEndpoint string `mapstructure:"public_endpoint"`
+	PublicUseSSL   bool   `mapstructure:"public_use_ssl"`
+	Region         string `mapstructure:"region"`
+}
+
+// SecurityConfig 登录防爆破与注册限流相关配置
+type SecurityConfig struct {
+	LoginMaxFailures     int      `mapstructure:"login_max_failures"`      // 单个账号连续失败多少次后锁定
+	LoginIPMaxFailures   int      `mapstructure:"login_ip_max_failures"`   // 单个 IP 连续失败多少次后锁定
+	LoginBackoffBase     int      `mapstructure:"login_backoff_base"`      // 失败后退避的基础时长（秒），每次失败翻倍
+	LoginLockoutMinutes  int      `mapstructure:"login_lockout_minutes"`   // 锁定时长（分钟）
+	RegisterLimitPerHour int      `mapstructure:"register_limit_per_hour"` // 单个 IP 每小时允许的注册请求数
+	AdminUserIDs         []string `mapstructure:"admin_user_ids"`          // 启动时提升为管理员的用户 ID 列表，用于初始化第一个管理员
+}
+
+// MailConfig SMTP 邮件配置，host 为空时只打印日志不发送
+type MailConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// AccountConfig 账号管理相关配置
+type AccountConfig struct {
+	EmailCodeExpireMinutes int `mapstructure:"email_code_expire_minutes"` // 邮箱验证码有效期（分钟）
+	DeletionGraceDays      int `mapstructure:"deletion_grace_days"`       // 注销冷静期（天），期间重新登录可撤销注销
+}
+
+// OIDCConfig 单点登录（OIDC 授权码 + PKCE）配置，enabled 为 false 时不注册 SSO 路由
+type OIDCConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Issuer       string   `mapstructure:"issuer"`        // 身份提供方地址，用于拼接 /.well-known/openid-configuration
+	ClientID     string   `mapstructure:"client_id"`     // 在身份提供方注册的客户端 ID
+	ClientSecret string   `mapstructure:"client_secret"` // 客户端密钥，公共客户端可留空
+	RedirectURL  string   `mapstructure:"redirect_url"`  // 回调地址，需与身份提供方中登记的一致
+	Scopes       []string `mapstructure:"scopes"`
+}
+
+// FriendConfig 好友相关配置
+type FriendConfig struct {
+	RequestExpireDays int `mapstructure:"request_expire_days"` // 好友请求有效期（天），超时未处理自动过期
+}
+
+// ContactConfig 通讯录匹配相关配置
+type ContactConfig struct {
+	HashSalt             string `mapstructure:"hash_salt"`               // 计算手机号/邮箱哈希的盐，会下发给客户端；修改后启动时自动重新计算
+	MaxHashesPerRequest  int    `mapstructure:"max_hashes_per_request"`  // 单次请求最多上传的哈希数
+	DiscoverLimitPerHour int    `mapstructure:"discover_limit_per_hour"` // 每个用户每小时允许的匹配请求数
+	LookupLimitPerHour   int    `mapstructure:"lookup_limit_per_hour"`   // 每个用户每小时允许的精确查找（/user/search）次数
+}
+
+// GroupConfig 群组相关配置
+type GroupConfig struct {
+	MaxPins               int `mapstructure:"max_pins"`                // 每个频道最多置顶的消息数
+	MaxMembers            int `mapstructure:"max_members"`             // 每个群最多的成员数，加入和邀请时校验
+	DissolveRetentionDays int `mapstructure:"dissolve_retention_days"` // 解散群组时选择清理后，消息和文件保留的天数
+}
+
+// StorageConfig 文件存储后端配置
+type StorageConfig struct {
+	Backend       string `mapstructure:"backend"`         // 存储后端：minio（MinIO/S3）或 local（本地文件系统）
+	PublicBaseURL string `mapstructure:"public_base_url"` // 文件访问地址的前缀，为空时 minio 使用 /bucket，local 使用 /uploads
+	LocalDir      string `mapstructure:"local_dir"`       // local 后端存放文件的目录，由服务以 /uploads 静态提供
+}
+
+// FileConfig 文件上传下载相关配置
+type FileConfig struct {
+	MaxUploadMB           int `mapstructure:"max_upload_mb"`           // 单个文件的最大大小（MB）
+	UploadExpireMinutes   int `mapstructure:"upload_expire_minutes"`   // 预签名上传 URL 的有效期（分钟），过期未确认的上传会被清理
+	DownloadExpireSeconds int `mapstructure:"download_expire_seconds"` // 预签名下载 URL 的有效期（秒）
+	ResumableMaxUploadMB  int `mapstructure:"resumable_max_upload_mb"` // 分片上传单个文件的最大大小（MB）
+	ResumablePartSizeMB   int `mapstructure:"resumable_part_size_mb"`  // 分片大小（MB），MinIO 要求除最后一片外不小于 5MB
+	ResumableExpireHours  int `mapstructure:"resumable_expire_hours"`  // 分片上传无进展多久后过期（小时），过期后清理已上传的分片
 }
 
 func LoadConfig() *Config {
@@ -47,6 +135,38 @@ func LoadConfig() *Config {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 
+	// 安全相关的默认值，配置文件中未填写时生效
+	viper.SetDefault("security.login_max_failures", 5)
+	viper.SetDefault("security.login_ip_max_failures", 20)
+	viper.SetDefault("security.login_backoff_base", 1)
+	viper.SetDefault("security.login_lockout_minutes", 15)
+	viper.SetDefault("security.register_limit_per_hour", 10)
+	viper.SetDefault("mail.port", 587)
+	viper.SetDefault("account.email_code_expire_minutes", 15)
+	viper.SetDefault("account.deletion_grace_days", 7)
+	viper.SetDefault("oidc.scopes", []string{"openid", "email", "profile"})
+	viper.SetDefault("friend.request_expire_days", 7)
+	viper.SetDefault("contact.max_hashes_per_request", 500)
+	viper.SetDefault("contact.discover_limit_per_hour", 10)
+	viper.SetDefault("contact.lookup_limit_per_hour", 30)
+	viper.SetDefault("group.max_pins", 10)
+	viper.SetDefault("group.max_members", 500)
+	viper.SetDefault("group.dissolve_retention_days", 30)
+	viper.SetDefault("minio.region", "us-east-1")
+	viper.SetDefault("storage.backend", "minio")
+	viper.SetDefault("storage.local_dir", "./uploads")
+	viper.SetDefault("file.max_upload_mb", 20)
+	viper.SetDefault("file.upload_expire_minutes", 15)
+	viper.SetDefault("file.download_expire_seconds", 300)
+	viper.SetDefault("file.resumable_max_upload_mb", 2048)
+	viper.SetDefault("file.resumable_part_size_mb", 8)
+	viper.SetDefault("file.resumable_expire_hours", 24)
+
+	// 通讯录哈希的盐不随配置文件分发，可以通过环境变量提供
+	if err := viper.BindEnv("contact.hash_salt", "CONTACT_HASH_SALT"); err != nil {
+		log.Fatalf("Error binding environment variables: %v", err)
+	}
+
 	if err := viper.ReadInConfig(); err != nil {
 		log.Fatalf("Error reading config file: %v", err)
 	}
@@ -56,5 +176,13 @@ func LoadConfig() *Config {
 		log.Fatalf("Error unmarshaling config: %v", err)
 	}
 
+	// 盐会下发给客户端，但各部署必须使用自己的随机值，否则哈希可以在部署之间关联
+	switch strings.TrimSpace(config.Contact.HashSalt) {
+	case "":
+		log.Fatalf("contact.hash_salt must be set in config file or the CONTACT_HASH_SALT environment variable")
+	case "change-me-contact-salt":
+		log.Fatalf("contact.hash_salt is still the example placeholder, set a random value")
+	}
+
 	return &config
 }
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditAction 定义审计操作类型
+type AuditAction string
+
+const (
+	AuditAccountLocked   AuditAction = "account_locked"   // 账号因多次登录失败被锁定
+	AuditIPLocked        AuditAction = "ip_locked"        // IP 因多次登录失败被锁定
+	AuditAccountUnlocked AuditAction = "account_unlocked" // 管理员解除账号锁定
+	AuditUserDisabled    AuditAction = "user_disabled"    // 管理员禁用账号
+	AuditUserEnabled     AuditAction = "user_enabled"     // 管理员启用账号
+	AuditForceLogout     AuditAction = "force_logout"     // 管理员强制用户下线
+	AuditRoleChanged     AuditAction = "role_changed"     // 管理员修改用户角色
+	AuditBroadcast       AuditAction = "broadcast"        // 管理员发送系统广播通知
+	AuditIdentityLinked  AuditAction = "identity_linked"  // 外部登录身份关联到账号
+)
+
+// AuditLog 定义审计日志的数据结构
+type AuditLog struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`                      // 审计日志的唯一标识符
+	Action    AuditAction        `bson:"action" json:"action"`                         // 操作类型
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`   // 被操作的用户 ID
+	ActorID   primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"` // 执行操作的用户 ID（系统操作为空）
+	IP        string             `bson:"ip,omitempty" json:"ip,omitempty"`             // 相关的客户端 IP
+	Detail    string             `bson:"detail" json:"detail"`                         // 详细说明
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`                 // 记录时间
+}
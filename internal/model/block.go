@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Block 定义用户对另一个用户的拉黑关系（单向）
+type Block struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`      // 拉黑记录的唯一标识符
+	BlockerID primitive.ObjectID `bson:"blocker_id" json:"blocker_id"` // 发起拉黑的用户 ID
+	BlockedID primitive.ObjectID `bson:"blocked_id" json:"blocked_id"` // 被拉黑的用户 ID
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"` // 拉黑时间
+}
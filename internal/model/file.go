@@ -27,3 +27,45 @@ type File struct {
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"` // 文件上传时间
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"` // 文件更新时间
 }
+
+// FileUpload 已签发预签名上传 URL、等待客户端确认的上传
+// 客户端直传 MinIO 后调用确认接口，服务端校验对象后创建 File 记录并删除本记录；过期未确认的上传会被清理
+type FileUpload struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`          // 上传的唯一标识符
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`           // 上传文件的用户 ID
+	ObjectName  string             `bson:"object_name" json:"-"`             // 存储后端中的对象名
+	Name        string             `bson:"name" json:"name"`                 // 原始文件名
+	ContentType string             `bson:"content_type" json:"content_type"` // 声明的文件 MIME 类型，上传时必须一致
+	Size        int64              `bson:"size" json:"size"`                 // 声明的文件大小（字节），上传时必须一致
+	Type        FileType           `bson:"type" json:"type"`                 // 文件类型
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"`     // 上传 URL 的过期时间
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`     // 签发时间
+}
+
+// ResumableUpload 可断点续传的分片上传，分片由服务端校验后写入存储后端的分片上传
+// 每上传一个分片都会顺延过期时间，长时间没有进展的上传会被取消并清理已上传的分片
+type ResumableUpload struct {
+	ID          primitive.ObjectID      `bson:"_id,omitempty" json:"id"`          // 上传的唯一标识符
+	UserID      primitive.ObjectID      `bson:"user_id" json:"user_id"`           // 上传文件的用户 ID
+	ObjectName  string                  `bson:"object_name" json:"-"`             // 存储后端中的对象名
+	StorageID   string                  `bson:"storage_id" json:"-"`              // 存储后端分片上传的 upload ID
+	Name        string                  `bson:"name" json:"name"`                 // 原始文件名
+	ContentType string                  `bson:"content_type" json:"content_type"` // 文件 MIME 类型
+	Size        int64                   `bson:"size" json:"size"`                 // 文件总大小（字节）
+	Type        FileType                `bson:"type" json:"type"`                 // 文件类型
+	PartSize    int64                   `bson:"part_size" json:"part_size"`       // 分片大小（字节），最后一片可以更小
+	PartCount   int                     `bson:"part_count" json:"part_count"`     // 分片总数
+	Parts       map[string]UploadedPart `bson:"parts" json:"-"`                   // 已上传的分片，键为分片序号
+	ExpiresAt   time.Time               `bson:"expires_at" json:"expires_at"`     // 过期时间
+	CreatedAt   time.Time               `bson:"created_at" json:"created_at"`     // 创建时间
+	UpdatedAt   time.Time               `bson:"updated_at" json:"updated_at"`     // 最近一次上传分片的时间
+}
+
+// UploadedPart 分片上传中已上传的一个分片
+type UploadedPart struct {
+	Number     int       `bson:"number" json:"number"`           // 分片序号，从 1 开始
+	Size       int64     `bson:"size" json:"size"`               // 分片大小（字节）
+	Checksum   string    `bson:"checksum" json:"checksum"`       // 分片内容的 SHA-256（十六进制）
+	ETag       string    `bson:"etag" json:"-"`                  // MinIO 返回的分片 ETag，合并分片时使用
+	UploadedAt time.Time `bson:"uploaded_at" json:"uploaded_at"` // 上传时间
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FriendTag 用户自定义的好友分组/标签，例如“同事”“家人”
+type FriendTag struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`      // 标签的唯一标识符
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`     // 标签所属的用户 ID
+	Name      string             `bson:"name" json:"name"`             // 标签名称，同一用户下不重复
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"` // 创建时间
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"` // 更新时间
+}
+
+// FriendMeta 用户对某个好友的私有设置，仅 OwnerID 本人可见
+// 好友关系是双向的，但备注等信息是单向的：我给你的备注与你给我的备注互不影响
+type FriendMeta struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`      // 记录的唯一标识符
+	OwnerID   primitive.ObjectID   `bson:"owner_id" json:"owner_id"`     // 设置者的用户 ID
+	FriendID  primitive.ObjectID   `bson:"friend_id" json:"friend_id"`   // 好友的用户 ID
+	Remark    string               `bson:"remark" json:"remark"`         // 备注名
+	TagIDs    []primitive.ObjectID `bson:"tag_ids" json:"tag_ids"`       // 所属标签
+	Starred   bool                 `bson:"starred" json:"starred"`       // 是否星标好友
+	Note      string               `bson:"note" json:"note"`             // 描述/备忘
+	UpdatedAt time.Time            `bson:"updated_at" json:"updated_at"` // 更新时间
+}
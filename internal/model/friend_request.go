@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FriendRequestStatus 定义好友请求的状态
+type FriendRequestStatus string
+
+const (
+	FriendRequestPending   FriendRequestStatus = "pending"   // 等待对方处理
+	FriendRequestAccepted  FriendRequestStatus = "accepted"  // 对方已同意
+	FriendRequestRejected  FriendRequestStatus = "rejected"  // 对方已拒绝
+	FriendRequestCancelled FriendRequestStatus = "cancelled" // 发送方已撤回
+	FriendRequestExpired   FriendRequestStatus = "expired"   // 超时未处理
+)
+
+// FriendRequest 定义好友请求的数据结构，只有 pending 状态的请求可以被处理
+type FriendRequest struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`                              // 好友请求的唯一标识符
+	FromUserID  primitive.ObjectID  `bson:"from_user_id" json:"from_user_id"`                     // 发送方用户 ID
+	ToUserID    primitive.ObjectID  `bson:"to_user_id" json:"to_user_id"`                         // 接收方用户 ID
+	Greeting    string              `bson:"greeting,omitempty" json:"greeting"`                   // 验证消息（打招呼）
+	Status      FriendRequestStatus `bson:"status" json:"status"`                                 // 请求状态
+	ExpiresAt   time.Time           `bson:"expires_at" json:"expires_at"`                         // 过期时间，过期后自动变为 expired
+	RespondedAt *time.Time          `bson:"responded_at,omitempty" json:"responded_at,omitempty"` // 处理（同意/拒绝/撤回）时间
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`                         // 请求创建时间
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`                         // 请求更新时间
+}
@@ -11,8 +11,18 @@ type Group struct {
 	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`  // 群组的唯一标识符
 	Name        string               `bson:"name" json:"name"`  // 群组名称
 	Description string               `bson:"description" json:"description"`  // 群组描述
+	Avatar      string               `bson:"avatar,omitempty" json:"avatar"`  // 群头像 URL
+	AvatarFileID primitive.ObjectID  `bson:"avatar_file_id,omitempty" json:"-"`  // 群头像对应的文件记录 ID，更换头像时用于删除旧文件
 	CreatorID   primitive.ObjectID   `bson:"creator_id" json:"creator_id"`  // 群组创建者的 ID
-	Members     []primitive.ObjectID `bson:"members" json:"members"`  // 群组成员列表
+	OwnerID     primitive.ObjectID   `bson:"owner_id,omitempty" json:"owner_id"`  // 群主的 ID，转让群主后与创建者不同；历史数据为空时以创建者为群主
+	MemberCount int                  `bson:"member_count" json:"member_count"`  // 群组成员数，与群组成员集合在同一事务中维护
+	ApprovalRequired bool            `bson:"approval_required" json:"approval_required"`  // 入群是否需要管理员审核
+	MuteAll     bool                 `bson:"mute_all" json:"mute_all"`  // 全员禁言（公告模式），开启后只有群主和管理员可以发言
+	SlowModeSeconds int              `bson:"slow_mode_seconds" json:"slow_mode_seconds"`  // 慢速模式的发言间隔秒数，0 表示关闭，群主和管理员不受限制
+	Pins        []GroupPin           `bson:"pins,omitempty" json:"pins"`  // 置顶消息，按置顶时间排序，数量有上限
+	DefaultChannelID primitive.ObjectID `bson:"default_channel_id,omitempty" json:"default_channel_id"`  // 默认频道（general）的 ID，未指定频道的消息和系统消息都发到这里
+	DissolvedAt *time.Time           `bson:"dissolved_at,omitempty" json:"dissolved_at,omitempty"`  // 解散时间，非空表示群组已解散，只能查看和导出历史
+	PurgeAt     *time.Time           `bson:"purge_at,omitempty" json:"purge_at,omitempty"`  // 解散后清理消息和文件的时间，为空表示不自动清理
 	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`  // 群组创建时间
 	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`  // 群组更新时间
 }
@@ -22,7 +32,10 @@ type GroupMember struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`  // 群组成员的唯一标识符
 	GroupID   primitive.ObjectID `bson:"group_id" json:"group_id"`  // 所在群组的 ID
 	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`  // 成员的用户 ID
-	Role      string             `bson:"role" json:"role"`  // 成员角色（admin 或 member）
+	Role      string             `bson:"role" json:"role"`  // 成员角色（owner、admin 或 member）
+	MutedUntil *time.Time        `bson:"muted_until,omitempty" json:"muted_until,omitempty"`  // 禁言截止时间，为空或已过期表示未被禁言
+	LastPostedAt *time.Time      `bson:"last_posted_at,omitempty" json:"-"`  // 最近一次发言时间，用于慢速模式
+	NotificationsMuted bool      `bson:"notifications_muted,omitempty" json:"notifications_muted"`  // 成员是否开启了群消息免打扰，被 @ 时仍会收到通知
 	JoinedAt  time.Time          `bson:"joined_at" json:"joined_at"`  // 成员加入群组的时间
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`  // 成员信息更新时间
 }
\ No newline at end of file
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GroupAnnouncement 群公告，由群主或管理员发布，成员阅读后需要确认
+type GroupAnnouncement struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`      // 公告的唯一标识符
+	GroupID   primitive.ObjectID     `bson:"group_id" json:"group_id"`     // 群组 ID
+	AuthorID  primitive.ObjectID     `bson:"author_id" json:"author_id"`   // 发布者 ID
+	Content   string                 `bson:"content" json:"content"`       // 公告内容
+	Acks      []GroupAnnouncementAck `bson:"acks" json:"-"`                // 已确认的成员，只有管理员可以通过已读列表接口查看
+	AckCount  int                    `bson:"ack_count" json:"ack_count"`   // 已确认的成员数
+	Acked     bool                   `bson:"-" json:"acked"`               // 当前用户是否已确认，查询时按用户填充
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"` // 发布时间
+}
+
+// GroupAnnouncementAck 成员对群公告的确认记录
+type GroupAnnouncementAck struct {
+	UserID  primitive.ObjectID `bson:"user_id" json:"user_id"`   // 确认的成员 ID
+	AckedAt time.Time          `bson:"acked_at" json:"acked_at"` // 确认时间
+}
+
+// AckedBy 判断 userID 是否已确认公告
+func (a *GroupAnnouncement) AckedBy(userID primitive.ObjectID) bool {
+	for _, ack := range a.Acks {
+		if ack.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupPin 群组中被置顶的消息，内嵌在群组文档中
+type GroupPin struct {
+	MessageID primitive.ObjectID `bson:"message_id" json:"message_id"` // 被置顶的消息 ID
+	ChannelID primitive.ObjectID `bson:"channel_id" json:"channel_id"` // 被置顶的消息所在的频道 ID，置顶数按频道限制
+	PinnedBy  primitive.ObjectID `bson:"pinned_by" json:"pinned_by"`   // 置顶操作者 ID
+	PinnedAt  time.Time          `bson:"pinned_at" json:"pinned_at"`   // 置顶时间
+	Message   *Message           `bson:"-" json:"message,omitempty"`   // 被置顶的消息内容，查询置顶列表时填充
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultGroupChannelName 每个群组的默认频道名称，不能删除、改名或设为私有
+const DefaultGroupChannelName = "general"
+
+// GroupChannel 群组内的频道，每个频道有独立的消息流
+// 公开频道所有群成员都可以读写，私有频道只有 Members 中的群成员可以读写
+type GroupChannel struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`                    // 频道的唯一标识符
+	GroupID     primitive.ObjectID   `bson:"group_id" json:"group_id"`                   // 所属群组 ID
+	Name        string               `bson:"name" json:"name"`                           // 频道名称，群组内唯一
+	Topic       string               `bson:"topic,omitempty" json:"topic"`               // 频道主题
+	Private     bool                 `bson:"private" json:"private"`                     // 是否为私有频道
+	Members     []primitive.ObjectID `bson:"members,omitempty" json:"members,omitempty"` // 私有频道的成员 ID
+	IsDefault   bool                 `bson:"is_default" json:"is_default"`               // 是否为群组的默认频道
+	CreatorID   primitive.ObjectID   `bson:"creator_id" json:"creator_id"`               // 创建者 ID
+	UnreadCount int64                `bson:"-" json:"unread_count"`                      // 当前用户在该频道的未读消息数，查询时按用户填充
+	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`               // 创建时间
+	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`               // 更新时间
+}
+
+// HasMember 判断 userID 是否为私有频道的成员
+func (c *GroupChannel) HasMember(userID primitive.ObjectID) bool {
+	for _, id := range c.Members {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadableBy 判断群成员 userID 能否读写该频道
+func (c *GroupChannel) ReadableBy(userID primitive.ObjectID) bool {
+	return !c.Private || c.HasMember(userID)
+}
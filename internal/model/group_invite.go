@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GroupInvite 群邀请：管理员创建的邀请链接，或成员直接邀请好友时生成的一次性邀请
+type GroupInvite struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`                          // 邀请的唯一标识符
+	GroupID   primitive.ObjectID `bson:"group_id" json:"group_id"`                         // 群组 ID
+	Code      string             `bson:"code" json:"code"`                                 // 邀请码，出现在邀请链接中
+	CreatorID primitive.ObjectID `bson:"creator_id" json:"creator_id"`                     // 创建邀请的用户 ID
+	InviteeID primitive.ObjectID `bson:"invitee_id,omitempty" json:"invitee_id,omitempty"` // 直接邀请的好友 ID，为空表示邀请链接
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` // 过期时间，为空表示永不过期
+	MaxUses   int                `bson:"max_uses" json:"max_uses"`                         // 最多使用次数，0 表示不限
+	Uses      int                `bson:"uses" json:"uses"`                                 // 已使用次数
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"` // 撤销时间，非空表示已撤销
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`                     // 创建时间
+}
+
+// Usable 判断邀请当前是否可用：未撤销、未过期且未用完
+func (i *GroupInvite) Usable(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// GroupJoinRequestStatus 入群申请的状态
+type GroupJoinRequestStatus string
+
+const (
+	GroupJoinPending  GroupJoinRequestStatus = "pending"  // 等待管理员审核
+	GroupJoinApproved GroupJoinRequestStatus = "approved" // 已通过
+	GroupJoinRejected GroupJoinRequestStatus = "rejected" // 已拒绝
+)
+
+// GroupJoinRequest 入群申请，群组开启“入群需审核”后由申请加入或被普通成员邀请产生
+type GroupJoinRequest struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"id"`                            // 申请的唯一标识符
+	GroupID    primitive.ObjectID     `bson:"group_id" json:"group_id"`                           // 群组 ID
+	UserID     primitive.ObjectID     `bson:"user_id" json:"user_id"`                             // 申请加入的用户 ID
+	InviterID  primitive.ObjectID     `bson:"inviter_id,omitempty" json:"inviter_id,omitempty"`   // 邀请人 ID，自行申请时为空
+	Status     GroupJoinRequestStatus `bson:"status" json:"status"`                               // 申请状态
+	ReviewerID primitive.ObjectID     `bson:"reviewer_id,omitempty" json:"reviewer_id,omitempty"` // 审核人 ID
+	ReviewedAt *time.Time             `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"` // 审核时间
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`                       // 申请时间
+	UpdatedAt  time.Time              `bson:"updated_at" json:"updated_at"`                       // 更新时间
+}
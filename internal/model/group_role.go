@@ -0,0 +1,117 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// 群成员角色
+const (
+	GroupRoleOwner  = "owner"  // 群主，每个群只有一个
+	GroupRoleAdmin  = "admin"  // 管理员
+	GroupRoleMember = "member" // 普通成员
+)
+
+// GroupPermission 群组内的操作权限
+type GroupPermission string
+
+const (
+	GroupPermInvite         GroupPermission = "invite"          // 邀请/拉人进群
+	GroupPermRemoveMember   GroupPermission = "remove_member"   // 移除成员
+	GroupPermEditInfo       GroupPermission = "edit_info"       // 修改群名称、描述等群资料
+	GroupPermPin            GroupPermission = "pin"             // 置顶消息
+	GroupPermMute           GroupPermission = "mute"            // 禁言成员
+	GroupPermManageAdmins   GroupPermission = "manage_admins"   // 设置/取消管理员、转让群主
+	GroupPermManageJoins    GroupPermission = "manage_joins"    // 创建/撤销邀请链接、审核入群申请
+	GroupPermAnnounce       GroupPermission = "announce"        // 发布/删除群公告、查看公告已读列表
+	GroupPermMentionAll     GroupPermission = "mention_all"     // 在群消息中 @all
+	GroupPermManageChannels GroupPermission = "manage_channels" // 创建/修改/删除频道、管理私有频道成员
+)
+
+// groupRolePermissions 各角色拥有的权限
+var groupRolePermissions = map[string]map[GroupPermission]bool{
+	GroupRoleOwner: {
+		GroupPermInvite:         true,
+		GroupPermRemoveMember:   true,
+		GroupPermEditInfo:       true,
+		GroupPermPin:            true,
+		GroupPermMute:           true,
+		GroupPermManageAdmins:   true,
+		GroupPermManageJoins:    true,
+		GroupPermAnnounce:       true,
+		GroupPermMentionAll:     true,
+		GroupPermManageChannels: true,
+	},
+	GroupRoleAdmin: {
+		GroupPermInvite:         true,
+		GroupPermRemoveMember:   true,
+		GroupPermEditInfo:       true,
+		GroupPermPin:            true,
+		GroupPermMute:           true,
+		GroupPermManageJoins:    true,
+		GroupPermAnnounce:       true,
+		GroupPermMentionAll:     true,
+		GroupPermManageChannels: true,
+	},
+	GroupRoleMember: {
+		GroupPermInvite: true,
+	},
+}
+
+// groupRoleRanks 群角色的等级，数值越大权限越高
+var groupRoleRanks = map[string]int{
+	GroupRoleMember: 0,
+	GroupRoleAdmin:  1,
+	GroupRoleOwner:  2,
+}
+
+// GroupRoleCan 判断角色是否拥有某项权限
+func GroupRoleCan(role string, perm GroupPermission) bool {
+	return groupRolePermissions[role][perm]
+}
+
+// GroupRoleOutranks 判断角色 role 是否高于 other，移除、禁言等操作只能作用于角色更低的成员
+func GroupRoleOutranks(role, other string) bool {
+	return groupRoleRanks[role] > groupRoleRanks[other]
+}
+
+// Dissolved 判断群组是否已解散
+func (g *Group) Dissolved() bool {
+	return g.DissolvedAt != nil
+}
+
+// GetOwnerID 返回群主 ID，历史数据中没有群主字段的群组以创建者为群主
+func (g *Group) GetOwnerID() primitive.ObjectID {
+	if g.OwnerID.IsZero() {
+		return g.CreatorID
+	}
+	return g.OwnerID
+}
+
+// 群组事件（系统消息）的动作类型
+const (
+	GroupActionMemberAdded         = "member_added"         // 成员被拉进群
+	GroupActionMemberLeft          = "member_left"          // 成员退出群组
+	GroupActionMemberRemoved       = "member_removed"       // 成员被移出群组
+	GroupActionAdminPromoted       = "admin_promoted"       // 成员被设为管理员
+	GroupActionAdminDemoted        = "admin_demoted"        // 管理员被取消
+	GroupActionOwnerTransferred    = "owner_transferred"    // 群主转让
+	GroupActionInfoUpdated         = "info_updated"         // 群资料被修改
+	GroupActionAvatarUpdated       = "avatar_updated"       // 群头像被修改或清除
+	GroupActionSettingsUpdated     = "settings_updated"     // 全员禁言、慢速模式等群设置被修改
+	GroupActionMemberMuted         = "member_muted"         // 成员被禁言
+	GroupActionMemberUnmuted       = "member_unmuted"       // 成员被解除禁言
+	GroupActionAnnouncementPosted  = "announcement_posted"  // 发布群公告
+	GroupActionAnnouncementDeleted = "announcement_deleted" // 删除群公告
+	GroupActionMessagePinned       = "message_pinned"       // 置顶消息
+	GroupActionMessageUnpinned     = "message_unpinned"     // 取消置顶消息
+	GroupActionDissolved           = "group_dissolved"      // 群主解散群组
+	GroupActionChannelCreated      = "channel_created"      // 创建频道
+	GroupActionChannelUpdated      = "channel_updated"      // 修改频道名称或主题
+	GroupActionChannelDeleted      = "channel_deleted"      // 删除频道
+)
+
+// GroupSystemContent 群组系统消息的内容，序列化为 JSON 存入消息的 content，由客户端渲染成文字
+type GroupSystemContent struct {
+	Action   string `json:"action"`              // 动作类型
+	ActorID  string `json:"actor_id"`            // 执行操作的用户 ID
+	TargetID string `json:"target_id,omitempty"` // 被操作的成员 ID，公告、置顶和频道操作中为公告、消息或频道 ID
+	Role     string `json:"role,omitempty"`      // 操作后被操作成员的角色
+}
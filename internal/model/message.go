@@ -12,6 +12,8 @@ const (
 	TextMessage  MessageType = "text"  // 文本消息
 	ImageMessage MessageType = "image" // 图片消息
 	FileMessage  MessageType = "file"  // 文件消息
+
+	SystemMessage MessageType = "system" // 系统消息（群成员变动等），content 为 JSON
 )
 
 // ReplyMessage 定义引用消息的数据结构
@@ -25,20 +27,24 @@ type ReplyMessage struct {
 
 // Message 定义消息的数据结构
 type Message struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`            // 消息的唯一标识符
-	Type       MessageType        `bson:"type" json:"type"`                   // 消息类型（文本、图片、文件）
-	Content    string             `bson:"content" json:"content"`             // 消息内容
-	SenderID   primitive.ObjectID `bson:"sender_id" json:"sender_id"`         // 发送者的用户 ID
-	ReceiverID primitive.ObjectID `bson:"receiver_id" json:"receiver_id"`     // 接收者的用户 ID
-	Sender     string             `bson:"sender" json:"sender"`               // 发送者 name
-	Receiver   string             `bson:"receiver" json:"receiverer"`         // 接收者 name
-	GroupID    primitive.ObjectID `bson:"group_id,omitempty" json:"group_id"` // 群组 ID（如果是群消息）
-	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`       // 消息发送时间
-	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`       // 消息更新时间
-	Status     string             `bson:"status" json:"status"`               // 消息状态（sent, delivered, read）
-	ReadBy     []ReadReceipt      `bson:"read_by" json:"read_by"`             // 读取消息的用户列表
-	FileName   string             `bson:"filename" json:"filename"`           // 文件名称
-	Reply      []ReplyMessage     `bson:"reply" json:"reply"`                 // 被引用的消息列表（数组）
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`                            // 消息的唯一标识符
+	Type       MessageType          `bson:"type" json:"type"`                                   // 消息类型（文本、图片、文件）
+	Content    string               `bson:"content" json:"content"`                             // 消息内容
+	SenderID   primitive.ObjectID   `bson:"sender_id" json:"sender_id"`                         // 发送者的用户 ID
+	ReceiverID primitive.ObjectID   `bson:"receiver_id" json:"receiver_id"`                     // 接收者的用户 ID
+	Sender     string               `bson:"sender" json:"sender"`                               // 发送者 name
+	Receiver   string               `bson:"receiver" json:"receiverer"`                         // 接收者 name
+	GroupID    primitive.ObjectID   `bson:"group_id,omitempty" json:"group_id"`                 // 群组 ID（如果是群消息）
+	ChannelID  primitive.ObjectID   `bson:"channel_id,omitempty" json:"channel_id,omitempty"`   // 群消息所属的频道 ID
+	CreatedAt  time.Time            `bson:"created_at" json:"created_at"`                       // 消息发送时间
+	UpdatedAt  time.Time            `bson:"updated_at" json:"updated_at"`                       // 消息更新时间
+	Status     string               `bson:"status" json:"status"`                               // 消息状态（sent, delivered, read）
+	ReadBy     []ReadReceipt        `bson:"read_by" json:"read_by"`                             // 读取消息的用户列表
+	FileName   string               `bson:"filename" json:"filename"`                           // 文件名称
+	FileID     primitive.ObjectID   `bson:"file_id,omitempty" json:"file_id,omitempty"`         // 图片和文件消息引用的文件 ID，发送时校验为发送者本人上传的文件
+	Reply      []ReplyMessage       `bson:"reply" json:"reply"`                                 // 被引用的消息列表（数组）
+	Mentions   []primitive.ObjectID `bson:"mentions,omitempty" json:"mentions,omitempty"`       // 消息中 @ 的群成员 ID，由服务端解析
+	MentionAll bool                 `bson:"mention_all,omitempty" json:"mention_all,omitempty"` // 消息中是否 @all
 }
 
 // ReadReceipt 定义消息已读回执
@@ -13,18 +13,27 @@ const (
 	MessageNotification NotificationType = "message" // 消息通知
 	GroupNotification   NotificationType = "group"   // 群组通知
 	SystemNotification  NotificationType = "system"  // 系统通知
+	FriendNotification  NotificationType = "friend"  // 好友请求通知
+	MentionNotification NotificationType = "mention" // 群消息中被 @ 的通知
 )
 
+// NotificationPriorityHigh 高优先级通知，客户端应即时提醒，不受群消息免打扰影响
+const NotificationPriorityHigh = "high"
+
 // Notification 定义通知的数据结构
 type Notification struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`                        // 通知的唯一标识符
-	Type      NotificationType   `bson:"type" json:"type"`                               // 通知类型
-	Title     string             `bson:"title" json:"title"`                             // 通知标题
-	Content   string             `bson:"content" json:"content"`                         // 通知内容
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`                         // 用户 ID
-	SenderID  primitive.ObjectID `bson:"sender_id,omitempty" json:"sender_id,omitempty"` // 发送者 ID
-	GroupID   primitive.ObjectID `bson:"group_id,omitempty" json:"group_id,omitempty"`   // 群组 ID
-	IsRead    bool               `bson:"is_read" json:"is_read"`                         // 是否已读
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`                   // 通知创建时间
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`                   // 通知更新时间
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`                            // 通知的唯一标识符
+	Type       NotificationType   `bson:"type" json:"type"`                                   // 通知类型
+	Title      string             `bson:"title" json:"title"`                                 // 通知标题
+	Content    string             `bson:"content" json:"content"`                             // 通知内容
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`                             // 用户 ID
+	SenderID   primitive.ObjectID `bson:"sender_id,omitempty" json:"sender_id,omitempty"`     // 发送者 ID
+	GroupID    primitive.ObjectID `bson:"group_id,omitempty" json:"group_id,omitempty"`       // 群组 ID
+	RequestID  primitive.ObjectID `bson:"request_id,omitempty" json:"request_id,omitempty"`   // 关联的好友请求或入群申请 ID
+	InviteCode string             `bson:"invite_code,omitempty" json:"invite_code,omitempty"` // 群邀请码，收到群邀请时用于接受邀请
+	MessageID  primitive.ObjectID `bson:"message_id,omitempty" json:"message_id,omitempty"`   // 关联的消息 ID，被 @ 时指向该消息
+	Priority   string             `bson:"priority,omitempty" json:"priority,omitempty"`       // 通知优先级，为空表示普通
+	IsRead     bool               `bson:"is_read" json:"is_read"`                             // 是否已读
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`                       // 通知创建时间
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`                       // 通知更新时间
 }
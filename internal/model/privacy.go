@@ -0,0 +1,70 @@
+package model
+
+// PrivacyAudience 隐私设置的可见范围
+type PrivacyAudience string
+
+const (
+	AudienceEveryone PrivacyAudience = "everyone" // 所有人
+	AudienceFriends  PrivacyAudience = "friends"  // 仅好友
+	AudienceNobody   PrivacyAudience = "nobody"   // 任何人都不可以
+)
+
+// ValidAudience 判断可见范围取值是否合法
+func ValidAudience(a PrivacyAudience) bool {
+	return a == AudienceEveryone || a == AudienceFriends || a == AudienceNobody
+}
+
+// PrivacySettings 用户的隐私设置，字段为空时使用默认值
+type PrivacySettings struct {
+	DiscoverableByPhone *bool `bson:"discoverable_by_phone,omitempty" json:"discoverable_by_phone,omitempty"` // 是否允许他人通过手机号（通讯录匹配）找到我
+	DiscoverableByEmail *bool `bson:"discoverable_by_email,omitempty" json:"discoverable_by_email,omitempty"` // 是否允许他人通过邮箱（通讯录匹配）找到我
+
+	LastSeen      PrivacyAudience `bson:"last_seen,omitempty" json:"last_seen,omitempty"`           // 谁可以看到我的在线状态和最后在线时间
+	Profile       PrivacyAudience `bson:"profile,omitempty" json:"profile,omitempty"`               // 谁可以看到我的头像和个人资料
+	GroupAdd      PrivacyAudience `bson:"group_add,omitempty" json:"group_add,omitempty"`           // 谁可以把我拉进群组
+	DirectMessage PrivacyAudience `bson:"direct_message,omitempty" json:"direct_message,omitempty"` // 谁可以给我发私聊消息
+	ReadReceipts  *bool           `bson:"read_receipts,omitempty" json:"read_receipts,omitempty"`   // 是否发送已读回执
+}
+
+// IsDiscoverableByPhone 是否允许通过手机号被发现，默认允许
+func (p PrivacySettings) IsDiscoverableByPhone() bool {
+	return p.DiscoverableByPhone == nil || *p.DiscoverableByPhone
+}
+
+// IsDiscoverableByEmail 是否允许通过邮箱被发现，默认允许
+func (p PrivacySettings) IsDiscoverableByEmail() bool {
+	return p.DiscoverableByEmail == nil || *p.DiscoverableByEmail
+}
+
+// LastSeenAudience 在线状态和最后在线时间的可见范围，默认所有人
+func (p PrivacySettings) LastSeenAudience() PrivacyAudience {
+	return orEveryone(p.LastSeen)
+}
+
+// ProfileAudience 头像和个人资料的可见范围，默认所有人
+func (p PrivacySettings) ProfileAudience() PrivacyAudience {
+	return orEveryone(p.Profile)
+}
+
+// GroupAddAudience 允许拉我进群的范围，默认所有人
+func (p PrivacySettings) GroupAddAudience() PrivacyAudience {
+	return orEveryone(p.GroupAdd)
+}
+
+// DirectMessageAudience 允许给我发私聊消息的范围，默认所有人
+func (p PrivacySettings) DirectMessageAudience() PrivacyAudience {
+	return orEveryone(p.DirectMessage)
+}
+
+// SendsReadReceipts 是否发送已读回执，默认发送
+func (p PrivacySettings) SendsReadReceipts() bool {
+	return p.ReadReceipts == nil || *p.ReadReceipts
+}
+
+// orEveryone 未设置的可见范围视为所有人
+func orEveryone(a PrivacyAudience) PrivacyAudience {
+	if a == "" {
+		return AudienceEveryone
+	}
+	return a
+}
@@ -6,6 +6,32 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// UserRole 定义用户角色
+type UserRole string
+
+const (
+	RoleUser      UserRole = "user"      // 普通用户
+	RoleModerator UserRole = "moderator" // 协管员，可以管理用户账号
+	RoleAdmin     UserRole = "admin"     // 管理员，拥有全部权限
+)
+
+// ValidRole 判断角色取值是否合法
+func ValidRole(role UserRole) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+// roleRanks 角色的权限等级，数值越大权限越高
+var roleRanks = map[UserRole]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// Outranks 判断角色 r 的权限是否高于 other，管理操作只能作用于权限更低的用户
+func (r UserRole) Outranks(other UserRole) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
 // User 定义用户的数据结构
 type User struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`      // 用户的唯一标识符
@@ -16,4 +42,54 @@ type User struct {
 	Avatar    string             `bson:"avatar" json:"avatar"`         // 头像url
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"` // 用户注册时间
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"` // 用户信息更新时间
+
+	Nickname   string `bson:"nickname,omitempty" json:"nickname"`       // 昵称
+	Bio        string `bson:"bio,omitempty" json:"bio"`                 // 个人简介
+	StatusText string `bson:"status_text,omitempty" json:"status_text"` // 个性签名/状态文字
+	Gender     string `bson:"gender,omitempty" json:"gender"`           // 性别（male、female、other，空表示未填写）
+	Birthday   string `bson:"birthday,omitempty" json:"birthday"`       // 生日（YYYY-MM-DD）
+	Region     string `bson:"region,omitempty" json:"region"`           // 地区
+	Locale     string `bson:"locale,omitempty" json:"locale"`           // 语言偏好（如 zh-CN）
+
+	Role           UserRole   `bson:"role,omitempty" json:"role"`                                 // 用户角色，为空视为普通用户
+	Disabled       bool       `bson:"disabled,omitempty" json:"disabled"`                         // 是否被管理员禁用
+	DisabledReason string     `bson:"disabled_reason,omitempty" json:"disabled_reason,omitempty"` // 禁用原因
+	DisabledAt     *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`         // 禁用时间
+
+	LastSeen *time.Time `bson:"last_seen,omitempty" json:"last_seen,omitempty"` // 最后在线时间，由上线/下线时更新
+
+	Privacy        PrivacySettings `bson:"privacy,omitempty" json:"-"`          // 隐私设置，只能通过隐私设置接口查看和修改
+	PhoneHash      string          `bson:"phone_hash,omitempty" json:"-"`       // 加盐后的手机号哈希，用于通讯录匹配
+	EmailHash      string          `bson:"email_hash,omitempty" json:"-"`       // 加盐后的邮箱哈希，用于通讯录匹配
+	ContactHashKey string          `bson:"contact_hash_key,omitempty" json:"-"` // 计算上述哈希时所用盐的指纹，盐更换后据此重新计算
+
+	TokenVersion int `bson:"token_version" json:"-"` // token 版本，递增后旧 token 全部失效
+
+	Identities []ExternalIdentity `bson:"identities,omitempty" json:"-"` // 已关联的外部登录身份（SSO）
+
+	PendingEmail        string     `bson:"pending_email,omitempty" json:"pending_email,omitempty"` // 待验证的新邮箱
+	EmailCodeHash       string     `bson:"email_code_hash,omitempty" json:"-"`                     // 新邮箱验证码的哈希
+	EmailCodeExpiresAt  *time.Time `bson:"email_code_expires_at,omitempty" json:"-"`               // 验证码过期时间
+	EmailCodeAttempts   int        `bson:"email_code_attempts,omitempty" json:"-"`                 // 验证码已尝试次数
+	ReauthCodeHash      string     `bson:"reauth_code_hash,omitempty" json:"-"`                    // 身份确认验证码的哈希，没有密码的（SSO）用户修改邮箱、注销账号时使用
+	ReauthCodeExpiresAt *time.Time `bson:"reauth_code_expires_at,omitempty" json:"-"`              // 身份确认验证码过期时间
+	ReauthCodeAttempts  int        `bson:"reauth_code_attempts,omitempty" json:"-"`                // 身份确认验证码已尝试次数
+	DeletionScheduledAt *time.Time `bson:"deletion_scheduled_at,omitempty" json:"-"`               // 计划注销时间，冷静期结束后清理数据
+	DeletedAt           *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`       // 注销完成时间，非空表示账号已注销
+}
+
+// ExternalIdentity 外部身份提供方（OIDC）中的用户身份，由 Provider + Subject 唯一确定
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`   // 身份提供方的 issuer
+	Subject  string    `bson:"subject" json:"subject"`     // 身份提供方中的用户唯一标识（sub）
+	Email    string    `bson:"email" json:"email"`         // 关联时身份提供方返回的邮箱
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"` // 关联时间
+}
+
+// GetRole 返回用户角色，历史数据中没有角色字段的用户视为普通用户
+func (u *User) GetRole() UserRole {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
 }
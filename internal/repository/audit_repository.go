@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuditRepository 是审计日志的仓库结构体
+type AuditRepository struct {
+	collection *mongo.Collection // MongoDB 中的审计日志集合
+}
+
+// NewAuditRepository 返回一个新的 AuditRepository 实例
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{
+		collection: mongodb.GetAuditCollection(),
+	}
+}
+
+// Create 写入一条审计日志
+func (r *AuditRepository) Create(ctx context.Context, entry *model.AuditLog) error {
+	entry.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
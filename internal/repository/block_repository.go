@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BlockRepository 是黑名单操作的仓库结构体
+type BlockRepository struct {
+	collection *mongo.Collection // MongoDB 中的黑名单集合
+}
+
+// NewBlockRepository 返回一个新的 BlockRepository 实例
+func NewBlockRepository() *BlockRepository {
+	return &BlockRepository{
+		collection: mongodb.GetBlockCollection(),
+	}
+}
+
+// Create 拉黑用户，重复拉黑不会产生多条记录
+func (r *BlockRepository) Create(ctx context.Context, blockerID, blockedID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"blocker_id": blockerID, "blocked_id": blockedID},
+		bson.M{"$setOnInsert": bson.M{
+			"blocker_id": blockerID,
+			"blocked_id": blockedID,
+			"created_at": time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Delete 取消拉黑，记录不存在时返回 mongo.ErrNoDocuments
+func (r *BlockRepository) Delete(ctx context.Context, blockerID, blockedID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"blocker_id": blockerID, "blocked_id": blockedID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ListByBlocker 获取用户的黑名单，按拉黑时间倒序
+func (r *BlockRepository) ListByBlocker(ctx context.Context, blockerID primitive.ObjectID) ([]*model.Block, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"blocker_id": blockerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []*model.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// ExistsEither 判断两个用户之间是否存在任一方向的拉黑
+func (r *BlockRepository) ExistsEither(ctx context.Context, a, b primitive.ObjectID) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"$or": []bson.M{
+			{"blocker_id": a, "blocked_id": b},
+			{"blocker_id": b, "blocked_id": a},
+		},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindRelatedIDs 返回与用户存在任一方向拉黑关系的所有用户 ID
+func (r *BlockRepository) FindRelatedIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"blocker_id": userID},
+			{"blocked_id": userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []*model.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(blocks))
+	for _, block := range blocks {
+		if block.BlockerID == userID {
+			ids = append(ids, block.BlockedID)
+		} else {
+			ids = append(ids, block.BlockerID)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteAllByUserID 删除用户发起和涉及该用户的所有拉黑记录
+func (r *BlockRepository) DeleteAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"$or": []bson.M{
+			{"blocker_id": userID},
+			{"blocked_id": userID},
+		},
+	})
+	return err
+}
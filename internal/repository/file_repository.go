@@ -74,3 +74,18 @@ func (r *FileRepository) Delete(ctx context.Context, id primitive.ObjectID) erro
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err // 返回删除操作的错误（如果有）
 }
+
+// GetByURLs 根据文件 URL 批量查询文件记录
+func (r *FileRepository) GetByURLs(ctx context.Context, urls []string) ([]*model.File, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"url": bson.M{"$in": urls}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*model.File
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FileUploadRepository 是待确认文件上传的仓库结构体
+type FileUploadRepository struct {
+	collection *mongo.Collection // MongoDB 中的待确认上传集合
+}
+
+// NewFileUploadRepository 返回一个新的 FileUploadRepository 实例
+func NewFileUploadRepository() *FileUploadRepository {
+	return &FileUploadRepository{
+		collection: mongodb.GetFileUploadCollection(),
+	}
+}
+
+// Create 记录一次已签发预签名 URL 的上传
+func (r *FileUploadRepository) Create(ctx context.Context, upload *model.FileUpload) error {
+	if upload.ID.IsZero() {
+		upload.ID = primitive.NewObjectID()
+	}
+	upload.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, upload)
+	return err
+}
+
+// FindByID 查找一次上传，不存在时返回 mongo.ErrNoDocuments
+func (r *FileUploadRepository) FindByID(ctx context.Context, uploadID primitive.ObjectID) (*model.FileUpload, error) {
+	var upload model.FileUpload
+	if err := r.collection.FindOne(ctx, bson.M{"_id": uploadID}).Decode(&upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Delete 删除上传记录，记录不存在时返回 mongo.ErrNoDocuments，用于防止同一上传被重复确认
+func (r *FileUploadRepository) Delete(ctx context.Context, uploadID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": uploadID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindExpired 查找在 before 之前过期的上传，最多返回 limit 条
+func (r *FileUploadRepository) FindExpired(ctx context.Context, before time.Time, limit int64) ([]*model.FileUpload, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"expires_at": bson.M{"$lt": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var uploads []*model.FileUpload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
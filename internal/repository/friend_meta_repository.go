@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FriendMetaRepository 是好友备注操作的仓库结构体
+type FriendMetaRepository struct {
+	collection *mongo.Collection // MongoDB 中的好友备注集合
+}
+
+// NewFriendMetaRepository 返回一个新的 FriendMetaRepository 实例
+func NewFriendMetaRepository() *FriendMetaRepository {
+	return &FriendMetaRepository{
+		collection: mongodb.GetFriendMetaCollection(),
+	}
+}
+
+// Upsert 更新 ownerID 对 friendID 的设置，记录不存在时创建，返回更新后的记录
+func (r *FriendMetaRepository) Upsert(ctx context.Context, ownerID, friendID primitive.ObjectID, updates bson.M) (*model.FriendMeta, error) {
+	set := bson.M{"updated_at": time.Now()}
+	for key, value := range updates {
+		set[key] = value
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var meta model.FriendMeta
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"owner_id": ownerID, "friend_id": friendID},
+		bson.M{"$set": set},
+		opts,
+	).Decode(&meta)
+	if err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// ListByOwner 获取用户对所有好友的设置
+func (r *FriendMetaRepository) ListByOwner(ctx context.Context, ownerID primitive.ObjectID) ([]*model.FriendMeta, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"owner_id": ownerID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var metas []*model.FriendMeta
+	if err := cursor.All(ctx, &metas); err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+// PullTag 从用户的所有好友设置中移除某个标签
+func (r *FriendMetaRepository) PullTag(ctx context.Context, ownerID, tagID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"owner_id": ownerID, "tag_ids": tagID},
+		bson.M{"$pull": bson.M{"tag_ids": tagID}},
+	)
+	return err
+}
+
+// DeleteBetween 删除两个用户互相之间的设置，解除好友关系时调用
+func (r *FriendMetaRepository) DeleteBetween(ctx context.Context, a, b primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"$or": []bson.M{
+			{"owner_id": a, "friend_id": b},
+			{"owner_id": b, "friend_id": a},
+		},
+	})
+	return err
+}
+
+// DeleteAllByUserID 删除用户设置的以及与该用户相关的所有记录
+func (r *FriendMetaRepository) DeleteAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"$or": []bson.M{
+			{"owner_id": userID},
+			{"friend_id": userID},
+		},
+	})
+	return err
+}
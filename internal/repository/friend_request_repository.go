@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrFriendRequestNotPending 好友请求已被处理或已过期，状态不能再变更
+var ErrFriendRequestNotPending = errors.New("friend request is no longer pending")
+
+// FriendRequestRepository 是好友请求操作的仓库结构体
+type FriendRequestRepository struct {
+	collection *mongo.Collection // MongoDB 中的好友请求集合
+}
+
+// NewFriendRequestRepository 返回一个新的 FriendRequestRepository 实例
+func NewFriendRequestRepository() *FriendRequestRepository {
+	return &FriendRequestRepository{
+		collection: mongodb.GetFriendRequestCollection(),
+	}
+}
+
+// Create 创建一条好友请求
+func (r *FriendRequestRepository) Create(ctx context.Context, request *model.FriendRequest) error {
+	request.CreatedAt = time.Now()
+	request.UpdatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	request.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID 根据 ID 查找好友请求
+func (r *FriendRequestRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*model.FriendRequest, error) {
+	var request model.FriendRequest
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// FindPending 查找 from 发给 to 的、尚未过期的待处理请求
+func (r *FriendRequestRepository) FindPending(ctx context.Context, fromUserID, toUserID primitive.ObjectID) (*model.FriendRequest, error) {
+	var request model.FriendRequest
+	err := r.collection.FindOne(ctx, bson.M{
+		"from_user_id": fromUserID,
+		"to_user_id":   toUserID,
+		"status":       model.FriendRequestPending,
+		"expires_at":   bson.M{"$gt": time.Now()},
+	}).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// FindPendingCounterparties 返回与用户之间存在未过期待处理请求（任一方向）的所有用户 ID
+func (r *FriendRequestRepository) FindPendingCounterparties(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"from_user_id": userID},
+			{"to_user_id": userID},
+		},
+		"status":     model.FriendRequestPending,
+		"expires_at": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []*model.FriendRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(requests))
+	for _, request := range requests {
+		if request.FromUserID == userID {
+			ids = append(ids, request.ToUserID)
+		} else {
+			ids = append(ids, request.FromUserID)
+		}
+	}
+	return ids, nil
+}
+
+// ListByUser 查询用户收到（field 为 to_user_id）或发出（field 为 from_user_id）的请求，status 为空表示全部状态
+func (r *FriendRequestRepository) ListByUser(ctx context.Context, field string, userID primitive.ObjectID, status model.FriendRequestStatus) ([]*model.FriendRequest, error) {
+	filter := bson.M{field: userID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []*model.FriendRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Transition 将待处理的请求变更为新状态，请求已不是 pending 或已过期时返回 ErrFriendRequestNotPending
+// 以 status 作为更新条件，保证并发的同意/拒绝/撤回只有一个能成功
+func (r *FriendRequestRepository) Transition(ctx context.Context, id primitive.ObjectID, status model.FriendRequestStatus) (*model.FriendRequest, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var request model.FriendRequest
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":        id,
+		"status":     model.FriendRequestPending,
+		"expires_at": bson.M{"$gt": now},
+	}, bson.M{
+		"$set": bson.M{"status": status, "responded_at": now, "updated_at": now},
+	}, opts).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrFriendRequestNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ExpirePending 将已超过有效期的待处理请求标记为 expired
+func (r *FriendRequestRepository) ExpirePending(ctx context.Context, now time.Time) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{
+		"status":     model.FriendRequestPending,
+		"expires_at": bson.M{"$lte": now},
+	}, bson.M{
+		"$set": bson.M{"status": model.FriendRequestExpired, "updated_at": now},
+	})
+	return err
+}
+
+// DeleteAllByUserID 删除用户发出和收到的所有好友请求
+func (r *FriendRequestRepository) DeleteAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"$or": []bson.M{
+			{"from_user_id": userID},
+			{"to_user_id": userID},
+		},
+	})
+	return err
+}
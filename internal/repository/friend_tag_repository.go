@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FriendTagRepository 是好友标签操作的仓库结构体
+type FriendTagRepository struct {
+	collection *mongo.Collection // MongoDB 中的好友标签集合
+}
+
+// NewFriendTagRepository 返回一个新的 FriendTagRepository 实例
+func NewFriendTagRepository() *FriendTagRepository {
+	return &FriendTagRepository{
+		collection: mongodb.GetFriendTagCollection(),
+	}
+}
+
+// Create 创建一个好友标签
+func (r *FriendTagRepository) Create(ctx context.Context, tag *model.FriendTag) error {
+	tag.CreatedAt = time.Now()
+	tag.UpdatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	tag.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID 查找属于 ownerID 的标签
+func (r *FriendTagRepository) FindByID(ctx context.Context, ownerID, id primitive.ObjectID) (*model.FriendTag, error) {
+	var tag model.FriendTag
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id, "owner_id": ownerID}).Decode(&tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// FindByName 按名称查找属于 ownerID 的标签
+func (r *FriendTagRepository) FindByName(ctx context.Context, ownerID primitive.ObjectID, name string) (*model.FriendTag, error) {
+	var tag model.FriendTag
+	if err := r.collection.FindOne(ctx, bson.M{"owner_id": ownerID, "name": name}).Decode(&tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ListByOwner 获取用户的所有标签，按创建时间排序
+func (r *FriendTagRepository) ListByOwner(ctx context.Context, ownerID primitive.ObjectID) ([]*model.FriendTag, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tags []*model.FriendTag
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// CountByOwner 统计用户的标签数量
+func (r *FriendTagRepository) CountByOwner(ctx context.Context, ownerID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"owner_id": ownerID})
+}
+
+// Rename 修改标签名称，标签不存在时返回 mongo.ErrNoDocuments
+func (r *FriendTagRepository) Rename(ctx context.Context, ownerID, id primitive.ObjectID, name string) (*model.FriendTag, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var tag model.FriendTag
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "owner_id": ownerID},
+		bson.M{"$set": bson.M{"name": name, "updated_at": time.Now()}},
+		opts,
+	).Decode(&tag)
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// Delete 删除标签，标签不存在时返回 mongo.ErrNoDocuments
+func (r *FriendTagRepository) Delete(ctx context.Context, ownerID, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "owner_id": ownerID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteAllByOwner 删除用户的所有标签
+func (r *FriendTagRepository) DeleteAllByOwner(ctx context.Context, ownerID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"owner_id": ownerID})
+	return err
+}
@@ -12,6 +12,12 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// CandidateCount 推荐好友聚合结果：候选用户及其得分（共同好友数或共同群组数）
+type CandidateCount struct {
+	UserID primitive.ObjectID `bson:"_id"`
+	Count  int                `bson:"count"`
+}
+
 // FriendshipRepository 是好友关系操作的仓库结构体，包含对好友集合的操作
 type FriendshipRepository struct {
 	collection *mongo.Collection // MongoDB 中的好友集合
@@ -87,3 +93,65 @@ func (r *FriendshipRepository) Delete(ctx context.Context, userID, friendID prim
 
 	return nil
 }
+
+// DeleteAllByUserID 删除用户的所有好友关系
+func (r *FriendshipRepository) DeleteAllByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"$or": []bson.M{
+			{"user_id": userID},
+			{"friend_id": userID},
+		},
+	})
+	return err
+}
+
+// Exists 判断两个用户之间是否已经是好友
+func (r *FriendshipRepository) Exists(ctx context.Context, userID, friendID primitive.ObjectID) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"$or": []bson.M{
+			{"user_id": userID, "friend_id": friendID},
+			{"user_id": friendID, "friend_id": userID},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MutualFriendCounts 统计 friendIDs 的好友（即好友的好友）中每个候选用户与当前用户的共同好友数
+// exclude 中的用户（自己、已有好友等）不参与统计，结果按共同好友数倒序，最多返回 limit 条
+func (r *FriendshipRepository) MutualFriendCounts(ctx context.Context, friendIDs, exclude []primitive.ObjectID, limit int64) ([]*CandidateCount, error) {
+	if len(friendIDs) == 0 {
+		return []*CandidateCount{}, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		// 1. 找出所有涉及当前用户好友的好友关系
+		{{Key: "$match", Value: bson.M{"$or": []bson.M{
+			{"user_id": bson.M{"$in": friendIDs}},
+			{"friend_id": bson.M{"$in": friendIDs}},
+		}}}},
+		// 2. 取关系中的另一方作为候选人
+		{{Key: "$project", Value: bson.M{"candidate": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{"$user_id", friendIDs}}, "$friend_id", "$user_id",
+		}}}}},
+		{{Key: "$match", Value: bson.M{"candidate": bson.M{"$nin": exclude}}}},
+		// 3. 每出现一次代表一个共同好友
+		{{Key: "$group", Value: bson.M{"_id": "$candidate", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []*CandidateCount
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GroupAnnouncementRepository 是群公告操作的仓库结构体
+type GroupAnnouncementRepository struct {
+	collection *mongo.Collection // MongoDB 中的群公告集合
+}
+
+// NewGroupAnnouncementRepository 返回一个新的 GroupAnnouncementRepository 实例
+func NewGroupAnnouncementRepository() *GroupAnnouncementRepository {
+	return &GroupAnnouncementRepository{
+		collection: mongodb.GetGroupAnnouncementCollection(),
+	}
+}
+
+// Create 发布一条群公告
+func (r *GroupAnnouncementRepository) Create(ctx context.Context, announcement *model.GroupAnnouncement) error {
+	announcement.CreatedAt = time.Now()
+	announcement.Acks = []model.GroupAnnouncementAck{}
+
+	result, err := r.collection.InsertOne(ctx, announcement)
+	if err != nil {
+		return err
+	}
+
+	announcement.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID 查找群组中的一条公告，不存在时返回 mongo.ErrNoDocuments
+func (r *GroupAnnouncementRepository) FindByID(ctx context.Context, groupID, announcementID primitive.ObjectID) (*model.GroupAnnouncement, error) {
+	var announcement model.GroupAnnouncement
+	err := r.collection.FindOne(ctx, bson.M{"_id": announcementID, "group_id": groupID}).Decode(&announcement)
+	if err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// ListByGroup 获取群组的公告，最新发布的在前，最多返回 limit 条，limit 为 0 表示不限
+func (r *GroupAnnouncementRepository) ListByGroup(ctx context.Context, groupID primitive.ObjectID, limit int64) ([]*model.GroupAnnouncement, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	cursor, err := r.collection.Find(ctx, bson.M{"group_id": groupID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	announcements := []*model.GroupAnnouncement{}
+	if err := cursor.All(ctx, &announcements); err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// Ack 记录成员已确认公告，重复确认不会重复记录；返回是否为首次确认
+func (r *GroupAnnouncementRepository) Ack(ctx context.Context, groupID, announcementID, userID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": announcementID, "group_id": groupID, "acks.user_id": bson.M{"$ne": userID}},
+		bson.M{
+			"$push": bson.M{"acks": model.GroupAnnouncementAck{UserID: userID, AckedAt: time.Now()}},
+			"$inc":  bson.M{"ack_count": 1},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Delete 删除群组中的一条公告，不存在时返回 mongo.ErrNoDocuments
+func (r *GroupAnnouncementRepository) Delete(ctx context.Context, groupID, announcementID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": announcementID, "group_id": groupID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteByGroup 删除群组的所有公告
+func (r *GroupAnnouncementRepository) DeleteByGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"group_id": groupID})
+	return err
+}
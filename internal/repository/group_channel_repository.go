@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrChannelNameTaken 群组中已有同名频道
+var ErrChannelNameTaken = errors.New("channel name already exists in this group")
+
+// GroupChannelRepository 是群频道操作的仓库结构体
+type GroupChannelRepository struct {
+	collection *mongo.Collection // MongoDB 中的群频道集合
+}
+
+// NewGroupChannelRepository 返回一个新的 GroupChannelRepository 实例
+func NewGroupChannelRepository() *GroupChannelRepository {
+	return &GroupChannelRepository{
+		collection: mongodb.GetGroupChannelCollection(),
+	}
+}
+
+// EnsureIndexes 创建 (group_id, name) 唯一索引，保证群组内频道名称不重复
+func (r *GroupChannelRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Create 创建频道，同名频道已存在时返回 ErrChannelNameTaken
+func (r *GroupChannelRepository) Create(ctx context.Context, channel *model.GroupChannel) error {
+	channel.CreatedAt = time.Now()
+	channel.UpdatedAt = channel.CreatedAt
+
+	result, err := r.collection.InsertOne(ctx, channel)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrChannelNameTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	channel.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByID 查找群组中的频道
+func (r *GroupChannelRepository) FindByID(ctx context.Context, groupID, channelID primitive.ObjectID) (*model.GroupChannel, error) {
+	var channel model.GroupChannel
+	if err := r.collection.FindOne(ctx, bson.M{"_id": channelID, "group_id": groupID}).Decode(&channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// FindByName 根据名称查找群组中的频道
+func (r *GroupChannelRepository) FindByName(ctx context.Context, groupID primitive.ObjectID, name string) (*model.GroupChannel, error) {
+	var channel model.GroupChannel
+	if err := r.collection.FindOne(ctx, bson.M{"group_id": groupID, "name": name}).Decode(&channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// ListReadable 获取 groupIDs 中 userID 可以读写的频道：公开频道和 userID 所在的私有频道
+// 默认频道排在最前，其余按创建时间排序
+func (r *GroupChannelRepository) ListReadable(ctx context.Context, groupIDs []primitive.ObjectID, userID primitive.ObjectID) ([]*model.GroupChannel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "is_default", Value: -1}, {Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"group_id": bson.M{"$in": groupIDs},
+		"$or": []bson.M{
+			{"private": false},
+			{"members": userID},
+		},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	channels := []*model.GroupChannel{}
+	if err := cursor.All(ctx, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// CountByGroup 统计群组的频道数
+func (r *GroupChannelRepository) CountByGroup(ctx context.Context, groupID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"group_id": groupID})
+}
+
+// Update 修改频道信息，频道不存在时返回 mongo.ErrNoDocuments，改名与已有频道重名时返回 ErrChannelNameTaken
+func (r *GroupChannelRepository) Update(ctx context.Context, groupID, channelID primitive.ObjectID, updates bson.M) error {
+	updates["updated_at"] = time.Now()
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": channelID, "group_id": groupID}, bson.M{"$set": updates})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrChannelNameTaken
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// AddMembers 将用户加入私有频道，已是成员的用户会被忽略
+func (r *GroupChannelRepository) AddMembers(ctx context.Context, groupID, channelID primitive.ObjectID, userIDs []primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": channelID, "group_id": groupID, "private": true},
+		bson.M{
+			"$addToSet": bson.M{"members": bson.M{"$each": userIDs}},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RemoveMember 将用户移出私有频道，用户不是频道成员时返回 mongo.ErrNoDocuments
+func (r *GroupChannelRepository) RemoveMember(ctx context.Context, groupID, channelID, userID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": channelID, "group_id": groupID, "private": true, "members": userID},
+		bson.M{
+			"$pull": bson.M{"members": userID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RemoveUserFromGroup 将用户移出群组中的所有私有频道，成员退群或被移出时调用
+func (r *GroupChannelRepository) RemoveUserFromGroup(ctx context.Context, groupID, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"group_id": groupID, "members": userID},
+		bson.M{"$pull": bson.M{"members": userID}},
+	)
+	return err
+}
+
+// RemoveUserFromAll 将用户移出所有群组的私有频道，注销账号时调用
+func (r *GroupChannelRepository) RemoveUserFromAll(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"members": userID},
+		bson.M{"$pull": bson.M{"members": userID}},
+	)
+	return err
+}
+
+// Delete 删除群组中的非默认频道，频道不存在或是默认频道时返回 mongo.ErrNoDocuments
+func (r *GroupChannelRepository) Delete(ctx context.Context, groupID, channelID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": channelID, "group_id": groupID, "is_default": false})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrGroupInviteUnavailable 邀请已撤销、已过期或已用完
+var ErrGroupInviteUnavailable = errors.New("invite is no longer valid")
+
+// GroupInviteRepository 是群邀请操作的仓库结构体
+type GroupInviteRepository struct {
+	collection *mongo.Collection // MongoDB 中的群邀请集合
+}
+
+// NewGroupInviteRepository 返回一个新的 GroupInviteRepository 实例
+func NewGroupInviteRepository() *GroupInviteRepository {
+	return &GroupInviteRepository{
+		collection: mongodb.GetGroupInviteCollection(),
+	}
+}
+
+// EnsureIndexes 创建邀请码唯一索引，按邀请码查找邀请时使用
+func (r *GroupInviteRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Create 创建一条群邀请
+func (r *GroupInviteRepository) Create(ctx context.Context, invite *model.GroupInvite) error {
+	invite.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, invite)
+	if err != nil {
+		return err
+	}
+
+	invite.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindByCode 根据邀请码查找邀请
+func (r *GroupInviteRepository) FindByCode(ctx context.Context, code string) (*model.GroupInvite, error) {
+	var invite model.GroupInvite
+	if err := r.collection.FindOne(ctx, bson.M{"code": code}).Decode(&invite); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListLinksByGroup 获取群组中未撤销的邀请链接（不含直接邀请），按创建时间倒序
+func (r *GroupInviteRepository) ListLinksByGroup(ctx context.Context, groupID primitive.ObjectID) ([]*model.GroupInvite, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"group_id":   groupID,
+		"invitee_id": bson.M{"$exists": false},
+		"revoked_at": bson.M{"$exists": false},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var invites []*model.GroupInvite
+	if err := cursor.All(ctx, &invites); err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+// Revoke 撤销群组中的某个邀请，邀请不存在或已撤销时返回 mongo.ErrNoDocuments
+func (r *GroupInviteRepository) Revoke(ctx context.Context, groupID, inviteID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": inviteID, "group_id": groupID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Consume 原子地将邀请的使用次数加一，邀请已撤销、已过期或已用完时返回 ErrGroupInviteUnavailable
+func (r *GroupInviteRepository) Consume(ctx context.Context, inviteID primitive.ObjectID) error {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(ctx, bson.M{
+		"_id":        inviteID,
+		"revoked_at": bson.M{"$exists": false},
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"expires_at": bson.M{"$exists": false}},
+				{"expires_at": bson.M{"$gt": now}},
+			}},
+			{"$or": []bson.M{
+				{"max_uses": 0},
+				{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}},
+			}},
+		},
+	}, bson.M{"$inc": bson.M{"uses": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrGroupInviteUnavailable
+	}
+	return nil
+}
+
+// Release 归还一次使用次数，用于消耗邀请后入群失败的情况
+func (r *GroupInviteRepository) Release(ctx context.Context, inviteID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": inviteID, "uses": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"uses": -1}},
+	)
+	return err
+}
+
+// DeleteByUser 删除用户创建的邀请和直接邀请该用户的邀请，注销账号时调用
+func (r *GroupInviteRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"$or": []bson.M{
+		{"creator_id": userID},
+		{"invitee_id": userID},
+	}})
+	return err
+}
+
+// DeleteByGroup 删除群组的所有邀请
+func (r *GroupInviteRepository) DeleteByGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"group_id": groupID})
+	return err
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrGroupJoinNotPending 入群申请已被审核，状态不能再变更
+var ErrGroupJoinNotPending = errors.New("join request is no longer pending")
+
+// GroupJoinRepository 是入群申请操作的仓库结构体
+type GroupJoinRepository struct {
+	collection *mongo.Collection // MongoDB 中的入群申请集合
+}
+
+// NewGroupJoinRepository 返回一个新的 GroupJoinRepository 实例
+func NewGroupJoinRepository() *GroupJoinRepository {
+	return &GroupJoinRepository{
+		collection: mongodb.GetGroupJoinCollection(),
+	}
+}
+
+// Create 创建一条入群申请
+func (r *GroupJoinRepository) Create(ctx context.Context, request *model.GroupJoinRequest) error {
+	request.CreatedAt = time.Now()
+	request.UpdatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	request.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindPending 查找用户在某个群组中待审核的申请
+func (r *GroupJoinRepository) FindPending(ctx context.Context, groupID, userID primitive.ObjectID) (*model.GroupJoinRequest, error) {
+	var request model.GroupJoinRequest
+	err := r.collection.FindOne(ctx, bson.M{
+		"group_id": groupID,
+		"user_id":  userID,
+		"status":   model.GroupJoinPending,
+	}).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ListPending 获取群组中所有待审核的申请，按申请时间排序
+func (r *GroupJoinRepository) ListPending(ctx context.Context, groupID primitive.ObjectID) ([]*model.GroupJoinRequest, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"group_id": groupID, "status": model.GroupJoinPending}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []*model.GroupJoinRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Review 原子地将待审核的申请变更为 status，申请不存在或已被审核时返回 ErrGroupJoinNotPending
+func (r *GroupJoinRepository) Review(ctx context.Context, groupID, requestID, reviewerID primitive.ObjectID, status model.GroupJoinRequestStatus) (*model.GroupJoinRequest, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var request model.GroupJoinRequest
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":      requestID,
+		"group_id": groupID,
+		"status":   model.GroupJoinPending,
+	}, bson.M{
+		"$set": bson.M{"status": status, "reviewer_id": reviewerID, "reviewed_at": now, "updated_at": now},
+	}, opts).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrGroupJoinNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// DeleteByUser 删除用户提交的所有入群申请，注销账号时调用
+func (r *GroupJoinRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// DeleteByGroup 删除群组的所有入群申请
+func (r *GroupJoinRepository) DeleteByGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"group_id": groupID})
+	return err
+}
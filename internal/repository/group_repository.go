@@ -4,95 +4,139 @@ import (
 	"chatweb/internal/model"              // 引入数据模型
 	"chatweb/internal/repository/mongodb" // 引入 MongoDB 相关的代码
 	"context"
+	"errors"
+	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrAlreadyGroupMember 用户已经是群组成员
+var ErrAlreadyGroupMember = errors.New("user is already a member of this group")
+
+// ErrGroupFull 群组成员数已达上限
+var ErrGroupFull = errors.New("group has reached its member limit")
+
+// ErrAlreadyPinned 消息已经被置顶
+var ErrAlreadyPinned = errors.New("message is already pinned")
+
+// ErrPinLimitReached 群组的置顶消息数已达上限
+var ErrPinLimitReached = errors.New("pinned message limit reached")
+
+// membershipIndexName 群组成员集合上 (group_id, user_id) 唯一索引的名称
+const membershipIndexName = "group_id_1_user_id_1"
+
 // GroupRepository 是群组操作的仓库结构体，包含对群组和成员集合的操作
 type GroupRepository struct {
-	collection       *mongo.Collection // 群组集合
-	memberCollection *mongo.Collection // 群组成员集合
+	collection        *mongo.Collection // 群组集合
+	memberCollection  *mongo.Collection // 群组成员集合
+	channelCollection *mongo.Collection // 群频道集合，创建和删除群组时在同一事务中维护默认频道
 }
 
-// NewGroupRepository 返回一个新的 GroupRepository 实例，初始化时获取群组集合和群组成员集合
+// NewGroupRepository 返回一个新的 GroupRepository 实例，初始化时获取群组集合、群组成员集合和群频道集合
 func NewGroupRepository() *GroupRepository {
 	return &GroupRepository{
-		collection:       mongodb.GetGroupCollection(),           // 获取 MongoDB 中的群组集合
-		memberCollection: mongodb.DB.Collection("group_members"), // 获取 MongoDB 中的群组成员集合
+		collection:        mongodb.GetGroupCollection(),        // 获取 MongoDB 中的群组集合
+		memberCollection:  mongodb.GetGroupMemberCollection(),  // 获取 MongoDB 中的群组成员集合
+		channelCollection: mongodb.GetGroupChannelCollection(), // 获取 MongoDB 中的群频道集合
 	}
 }
 
-// Create 创建一个新的群组记录，并将群组信息插入到 MongoDB 中
-func (r *GroupRepository) Create(ctx context.Context, group *model.Group) error {
+// Create 创建一个新的群组记录，并在同一事务中写入 owner 成员记录和默认频道，群组的成员数为 1
+func (r *GroupRepository) Create(ctx context.Context, group *model.Group, owner *model.GroupMember) error {
 	// 设置群组的创建时间和更新时间
 	group.CreatedAt = time.Now()
 	group.UpdatedAt = time.Now()
+	group.MemberCount = 1
+	group.DefaultChannelID = primitive.NewObjectID()
 
-	// 将群组插入到群组集合中
-	result, err := r.collection.InsertOne(ctx, group)
-	if err != nil {
-		return err // 如果插入失败，返回错误
-	}
+	return mongodb.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		// 将群组插入到群组集合中
+		result, err := r.collection.InsertOne(sc, group)
+		if err != nil {
+			return err
+		}
 
-	// 将插入后返回的群组 ID 更新到 group 结构体中
-	group.ID = result.InsertedID.(primitive.ObjectID)
-	return nil
+		// 将插入后返回的群组 ID 更新到 group 结构体中
+		group.ID = result.InsertedID.(primitive.ObjectID)
+		owner.GroupID = group.ID
+		owner.JoinedAt = group.CreatedAt
+		owner.UpdatedAt = group.CreatedAt
+		if _, err = r.memberCollection.InsertOne(sc, owner); err != nil {
+			return err
+		}
+
+		_, err = r.channelCollection.InsertOne(sc, &model.GroupChannel{
+			ID:        group.DefaultChannelID,
+			GroupID:   group.ID,
+			Name:      model.DefaultGroupChannelName,
+			IsDefault: true,
+			CreatorID: group.CreatorID,
+			CreatedAt: group.CreatedAt,
+			UpdatedAt: group.CreatedAt,
+		})
+		return err
+	})
 }
 
-// AddMember 添加群组成员
-func (r *GroupRepository) AddMember(ctx context.Context, member *model.GroupMember) error {
+// AddMember 添加群组成员，并在同一事务中将群组的成员数加一
+// 群组成员数已达 maxMembers 时返回 ErrGroupFull；依赖 (group_id, user_id) 唯一索引，用户已经是成员时返回 ErrAlreadyGroupMember
+func (r *GroupRepository) AddMember(ctx context.Context, member *model.GroupMember, maxMembers int) error {
 	// 设置成员加入时间和更新时间
 	member.JoinedAt = time.Now()
 	member.UpdatedAt = time.Now()
 
-	// 将群组成员插入到群组成员集合中
-	_, err := r.memberCollection.InsertOne(ctx, member)
-	return err // 返回操作的错误（如果有）
+	err := mongodb.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		// 先占用名额，成员数已满时不会命中
+		result, err := r.collection.UpdateOne(sc,
+			bson.M{"_id": member.GroupID, "member_count": bson.M{"$lt": maxMembers}},
+			bson.M{"$inc": bson.M{"member_count": 1}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrGroupFull
+		}
+		_, err = r.memberCollection.InsertOne(sc, member)
+		return err
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyGroupMember
+	}
+	return err
 }
 
-// GetGroupsByUserID 获取指定用户所在的所有群组
+// GetGroupsByUserID 获取指定用户所在的所有群组，按加入时间排序
 func (r *GroupRepository) GetGroupsByUserID(ctx context.Context, userID primitive.ObjectID) ([]*model.Group, error) {
-	// 查找用户所在的所有群组成员记录
-	cursor, err := r.memberCollection.Find(ctx, bson.M{"user_id": userID})
-	if err != nil {
-		return nil, err // 如果查询失败，返回错误
-	}
-	defer cursor.Close(ctx) // 确保查询游标关闭
-
-	var members []model.GroupMember
-	// 将查询结果填充到成员切片中
-	if err := cursor.All(ctx, &members); err != nil {
-		return nil, err // 如果填充失败，返回错误
-	}
-
-	// 如果没有成员，返回空的群组列表
-	if len(members) == 0 {
-		return []*model.Group{}, nil
-	}
-
-	// 获取所有群组的 ID
-	var groupIDs []primitive.ObjectID
-	for _, member := range members {
-		groupIDs = append(groupIDs, member.GroupID)
+	pipeline := mongo.Pipeline{
+		// 1. 用户的成员记录
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$sort", Value: bson.D{{Key: "joined_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		// 2. 关联出对应的群组，群组已不存在的成员记录会被丢弃
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.collection.Name(),
+			"localField":   "group_id",
+			"foreignField": "_id",
+			"as":           "group",
+		}}},
+		{{Key: "$unwind", Value: "$group"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$group"}}},
 	}
 
-	// 查找群组详情
-	groupCursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": groupIDs}})
+	cursor, err := r.memberCollection.Aggregate(ctx, pipeline)
 	if err != nil {
-		return nil, err // 如果查询群组失败，返回错误
+		return nil, err // 如果查询失败，返回错误
 	}
-	defer groupCursor.Close(ctx) // 确保查询游标关闭
+	defer cursor.Close(ctx) // 确保查询游标关闭
 
-	var groups []*model.Group
-	// 将查询结果填充到群组切片中
-	if err := groupCursor.All(ctx, &groups); err != nil {
+	groups := []*model.Group{}
+	if err := cursor.All(ctx, &groups); err != nil {
 		return nil, err // 如果填充失败，返回错误
 	}
-
-	// 返回该用户所在的所有群组
 	return groups, nil
 }
 
@@ -126,14 +170,209 @@ func (r *GroupRepository) GetGroupMembers(ctx context.Context, groupID primitive
 	return members, nil
 }
 
-// RemoveMember 移除群组成员
+// GetMember 获取指定群组中某个用户的成员记录，不是成员时返回 mongo.ErrNoDocuments
+func (r *GroupRepository) GetMember(ctx context.Context, groupID, userID primitive.ObjectID) (*model.GroupMember, error) {
+	var member model.GroupMember
+	err := r.memberCollection.FindOne(ctx, bson.M{"group_id": groupID, "user_id": userID}).Decode(&member)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// UpdateMemberRole 修改群组成员的角色
+func (r *GroupRepository) UpdateMemberRole(ctx context.Context, groupID, userID primitive.ObjectID, role string) error {
+	result, err := r.memberCollection.UpdateOne(ctx,
+		bson.M{"group_id": groupID, "user_id": userID},
+		bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// TransferOwnership 在一个事务中把群主转让给 toID，原群主 fromID 降为管理员
+func (r *GroupRepository) TransferOwnership(ctx context.Context, groupID, fromID, toID primitive.ObjectID) error {
+	return mongodb.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := r.UpdateMemberRole(sc, groupID, toID, model.GroupRoleOwner); err != nil {
+			return err
+		}
+		if err := r.UpdateGroup(sc, groupID, map[string]interface{}{"owner_id": toID}); err != nil {
+			return err
+		}
+		return r.UpdateMemberRole(sc, groupID, fromID, model.GroupRoleAdmin)
+	})
+}
+
+// SetMemberMute 设置成员的禁言截止时间，until 为 nil 时解除禁言
+func (r *GroupRepository) SetMemberMute(ctx context.Context, groupID, userID primitive.ObjectID, until *time.Time) error {
+	update := bson.M{"$set": bson.M{"muted_until": until, "updated_at": time.Now()}}
+	if until == nil {
+		update = bson.M{"$unset": bson.M{"muted_until": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+	result, err := r.memberCollection.UpdateOne(ctx, bson.M{"group_id": groupID, "user_id": userID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// TryPost 慢速模式下原子地记录成员的发言时间
+// 距上次发言不足 interval 时不修改并返回 false
+func (r *GroupRepository) TryPost(ctx context.Context, groupID, userID primitive.ObjectID, interval time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := r.memberCollection.UpdateOne(ctx,
+		bson.M{
+			"group_id": groupID,
+			"user_id":  userID,
+			"$or": []bson.M{
+				{"last_posted_at": bson.M{"$exists": false}},
+				{"last_posted_at": bson.M{"$lte": now.Add(-interval)}},
+			},
+		},
+		bson.M{"$set": bson.M{"last_posted_at": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// AddPin 原子地向群组添加一条置顶消息，消息已置顶时返回 ErrAlreadyPinned，pin.ChannelID 频道的置顶数已达 max 时返回 ErrPinLimitReached
+func (r *GroupRepository) AddPin(ctx context.Context, groupID primitive.ObjectID, pin model.GroupPin, max int) error {
+	if max < 1 {
+		return ErrPinLimitReached
+	}
+	pin.PinnedAt = time.Now()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{
+			"_id":             groupID,
+			"pins.message_id": bson.M{"$ne": pin.MessageID},
+			"$expr": bson.M{"$lt": bson.A{
+				bson.M{"$size": bson.M{"$filter": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$pins", bson.A{}}},
+					"cond":  bson.M{"$eq": bson.A{"$$this.channel_id", pin.ChannelID}},
+				}}},
+				max,
+			}},
+		},
+		bson.M{"$push": bson.M{"pins": pin}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 1 {
+		return nil
+	}
+
+	// 更新未命中时区分失败原因
+	group, err := r.GetGroupByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	for _, p := range group.Pins {
+		if p.MessageID == pin.MessageID {
+			return ErrAlreadyPinned
+		}
+	}
+	return ErrPinLimitReached
+}
+
+// SetAvatar 更新群头像，返回更新前的头像文件 ID，avatar 为 nil 时清除头像
+func (r *GroupRepository) SetAvatar(ctx context.Context, groupID primitive.ObjectID, avatar *model.File) (primitive.ObjectID, error) {
+	update := bson.M{"$unset": bson.M{"avatar": "", "avatar_file_id": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	if avatar != nil {
+		update = bson.M{"$set": bson.M{"avatar": avatar.URL, "avatar_file_id": avatar.ID, "updated_at": time.Now()}}
+	}
+
+	var before model.Group
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": groupID}, update,
+		options.FindOneAndUpdate().SetProjection(bson.M{"avatar_file_id": 1}),
+	).Decode(&before)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return before.AvatarFileID, nil
+}
+
+// RemovePin 取消群组中的一条置顶消息，消息未被置顶时返回 mongo.ErrNoDocuments
+func (r *GroupRepository) RemovePin(ctx context.Context, groupID, messageID primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": groupID, "pins.message_id": messageID},
+		bson.M{"$pull": bson.M{"pins": bson.M{"message_id": messageID}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FilterMembers 返回 userIDs 中属于群组成员的用户
+func (r *GroupRepository) FilterMembers(ctx context.Context, groupID primitive.ObjectID, userIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	values, err := r.memberCollection.Distinct(ctx, "user_id", bson.M{"group_id": groupID, "user_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			members = append(members, id)
+		}
+	}
+	return members, nil
+}
+
+// SetNotificationsMuted 设置成员的群消息免打扰
+func (r *GroupRepository) SetNotificationsMuted(ctx context.Context, groupID, userID primitive.ObjectID, muted bool) error {
+	result, err := r.memberCollection.UpdateOne(ctx,
+		bson.M{"group_id": groupID, "user_id": userID},
+		bson.M{"$set": bson.M{"notifications_muted": muted, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RemoveMember 移除群组成员，并在同一事务中将群组的成员数减一，用户不是成员时返回 mongo.ErrNoDocuments
 func (r *GroupRepository) RemoveMember(ctx context.Context, groupID, userID primitive.ObjectID) error {
-	// 从群组成员集合中删除指定群组和用户的记录
-	_, err := r.memberCollection.DeleteOne(ctx, bson.M{
-		"group_id": groupID,
-		"user_id":  userID,
+	return mongodb.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		// 从群组成员集合中删除指定群组和用户的记录
+		result, err := r.memberCollection.DeleteOne(sc, bson.M{
+			"group_id": groupID,
+			"user_id":  userID,
+		})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		return r.incMemberCount(sc, []primitive.ObjectID{groupID}, -1)
 	})
-	return err // 返回删除操作的错误（如果有）
+}
+
+// incMemberCount 将 groupIDs 中每个群组的成员数加上 delta
+func (r *GroupRepository) incMemberCount(ctx context.Context, groupIDs []primitive.ObjectID, delta int) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": groupIDs}},
+		bson.M{"$inc": bson.M{"member_count": delta}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
 }
 
 // UpdateGroup 更新群组信息
@@ -148,3 +387,340 @@ func (r *GroupRepository) UpdateGroup(ctx context.Context, groupID primitive.Obj
 	)
 	return err // 返回更新操作的错误（如果有）
 }
+
+// Dissolve 将群组标记为已解散，purgeAt 不为空时到期后清理群组数据；群组已解散时返回 mongo.ErrNoDocuments
+func (r *GroupRepository) Dissolve(ctx context.Context, groupID primitive.ObjectID, purgeAt *time.Time) error {
+	set := bson.M{"dissolved_at": time.Now(), "updated_at": time.Now()}
+	if purgeAt != nil {
+		set["purge_at"] = *purgeAt
+	}
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": groupID, "dissolved_at": bson.M{"$exists": false}},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindDueForPurge 查找需要清理的群组：已解散且到了清理时间的群组，以及已经没有成员的群组
+func (r *GroupRepository) FindDueForPurge(ctx context.Context, now time.Time) ([]*model.Group, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"$or": []bson.M{
+		{"purge_at": bson.M{"$lte": now}},
+		{"member_count": bson.M{"$lte": 0}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []*model.Group
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ExistsByAvatarFileID 判断文件是否为某个群组的头像
+func (r *GroupRepository) ExistsByAvatarFileID(ctx context.Context, fileID primitive.ObjectID) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"avatar_file_id": fileID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindWithoutDefaultChannel 查找还没有默认频道的群组，用于迁移到频道结构
+func (r *GroupRepository) FindWithoutDefaultChannel(ctx context.Context) ([]*model.Group, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"default_channel_id": bson.M{"$exists": false}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []*model.Group
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Delete 在同一事务中删除群组及其所有成员记录和频道
+func (r *GroupRepository) Delete(ctx context.Context, groupID primitive.ObjectID) error {
+	return mongodb.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := r.memberCollection.DeleteMany(sc, bson.M{"group_id": groupID}); err != nil {
+			return err
+		}
+		if _, err := r.channelCollection.DeleteMany(sc, bson.M{"group_id": groupID}); err != nil {
+			return err
+		}
+		_, err := r.collection.DeleteOne(sc, bson.M{"_id": groupID})
+		return err
+	})
+}
+
+// RemoveUserFromAllGroups 将用户从所有群组中移除，并同步各群组的成员数
+// 用户是群主的群组在同一事务中转让给最早加入的管理员，没有管理员时转让给最早加入的成员
+func (r *GroupRepository) RemoveUserFromAllGroups(ctx context.Context, userID primitive.ObjectID) error {
+	return mongodb.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		groupIDs, err := r.memberCollection.Distinct(sc, "group_id", bson.M{"user_id": userID})
+		if err != nil {
+			return err
+		}
+		if len(groupIDs) == 0 {
+			return nil
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(groupIDs))
+		for _, id := range groupIDs {
+			if objID, ok := id.(primitive.ObjectID); ok {
+				ids = append(ids, objID)
+			}
+		}
+
+		owned, err := r.memberCollection.Distinct(sc, "group_id", bson.M{"user_id": userID, "role": model.GroupRoleOwner})
+		if err != nil {
+			return err
+		}
+		for _, id := range owned {
+			groupID, ok := id.(primitive.ObjectID)
+			if !ok {
+				continue
+			}
+			if err := r.handOverOwnership(sc, groupID, userID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := r.memberCollection.DeleteMany(sc, bson.M{"user_id": userID}); err != nil {
+			return err
+		}
+		return r.incMemberCount(sc, ids, -1)
+	})
+}
+
+// handOverOwnership 群主 ownerID 被移除前把群主转让给最早加入的管理员，没有管理员时转让给最早加入的成员
+// 群里没有其他成员时不做处理，空群组由清理任务删除
+func (r *GroupRepository) handOverOwnership(sc mongo.SessionContext, groupID, ownerID primitive.ObjectID) error {
+	opts := options.FindOne().SetSort(bson.D{{Key: "joined_at", Value: 1}})
+	for _, filter := range []bson.M{
+		{"group_id": groupID, "user_id": bson.M{"$ne": ownerID}, "role": model.GroupRoleAdmin},
+		{"group_id": groupID, "user_id": bson.M{"$ne": ownerID}},
+	} {
+		var successor model.GroupMember
+		err := r.memberCollection.FindOne(sc, filter, opts).Decode(&successor)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := r.UpdateMemberRole(sc, groupID, successor.UserID, model.GroupRoleOwner); err != nil {
+			return err
+		}
+		return r.UpdateGroup(sc, groupID, map[string]interface{}{"owner_id": successor.UserID})
+	}
+	return nil
+}
+
+// MigrateMembership 将群组成员关系统一到群组成员集合，并创建 (group_id, user_id) 唯一索引
+// 唯一索引已存在时视为已迁移，直接返回；否则依次：
+//  1. 把旧群组文档 members 数组中缺失的成员补写到成员集合，并删除 members 字段
+//  2. 删除重复的成员记录，每个用户在每个群组只保留最早加入的一条
+//  3. 按成员集合重新计算每个群组的 member_count
+func (r *GroupRepository) MigrateMembership(ctx context.Context) error {
+	migrated, err := r.hasMembershipIndex(ctx)
+	if err != nil || migrated {
+		return err
+	}
+
+	if err := r.migrateLegacyMembers(ctx); err != nil {
+		return err
+	}
+	if err := r.dedupeMembers(ctx); err != nil {
+		return err
+	}
+	if err := r.recountMembers(ctx); err != nil {
+		return err
+	}
+
+	_, err = r.memberCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetName(membershipIndexName).SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "joined_at", Value: 1}}},
+	})
+	return err
+}
+
+// hasMembershipIndex 判断成员集合上是否已经有 (group_id, user_id) 唯一索引
+func (r *GroupRepository) hasMembershipIndex(ctx context.Context) (bool, error) {
+	cursor, err := r.memberCollection.Indexes().List(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []struct {
+		Name string `bson:"name"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return false, err
+	}
+	for _, index := range indexes {
+		if index.Name == membershipIndexName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// migrateLegacyMembers 把群组文档 members 数组中的成员补写到成员集合，然后删除 members 字段
+func (r *GroupRepository) migrateLegacyMembers(ctx context.Context) error {
+	cursor, err := r.collection.Find(ctx, bson.M{"members": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var legacy struct {
+			model.Group `bson:",inline"`
+			Members     []primitive.ObjectID `bson:"members"`
+		}
+		if err := cursor.Decode(&legacy); err != nil {
+			return err
+		}
+
+		for _, userID := range legacy.Members {
+			role := model.GroupRoleMember
+			if userID == legacy.GetOwnerID() {
+				role = model.GroupRoleOwner
+			}
+			_, err := r.memberCollection.UpdateOne(ctx,
+				bson.M{"group_id": legacy.ID, "user_id": userID},
+				bson.M{"$setOnInsert": bson.M{"role": role, "joined_at": legacy.CreatedAt, "updated_at": time.Now()}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": legacy.ID}, bson.M{"$unset": bson.M{"members": ""}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// dedupeMembers 删除重复的成员记录，每个用户在每个群组只保留最早加入的一条
+func (r *GroupRepository) dedupeMembers(ctx context.Context) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "joined_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"group_id": "$group_id", "user_id": "$user_id"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+	cursor, err := r.memberCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	removed := 0
+	for cursor.Next(ctx) {
+		var dup struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&dup); err != nil {
+			return err
+		}
+		result, err := r.memberCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": dup.IDs[1:]}})
+		if err != nil {
+			return err
+		}
+		removed += int(result.DeletedCount)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d duplicate group member records", removed)
+	}
+	return cursor.Err()
+}
+
+// recountMembers 按成员集合重新计算每个群组的 member_count
+func (r *GroupRepository) recountMembers(ctx context.Context) error {
+	cursor, err := r.memberCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$group_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		GroupID primitive.ObjectID `bson:"_id"`
+		Count   int                `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return err
+	}
+
+	// 先清零，再写入有成员的群组，没有成员记录的群组成员数为 0
+	if _, err := r.collection.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"member_count": 0}}); err != nil {
+		return err
+	}
+	for _, c := range counts {
+		if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": c.GroupID}, bson.M{"$set": bson.M{"member_count": c.Count}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count 统计满足条件的群组数量
+func (r *GroupRepository) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+// SharedGroupCounts 统计与 userID 同在一个群组的其他用户及共同群组数
+// exclude 中的用户不参与统计，结果按共同群组数倒序，最多返回 limit 条
+func (r *GroupRepository) SharedGroupCounts(ctx context.Context, userID primitive.ObjectID, exclude []primitive.ObjectID, limit int64) ([]*CandidateCount, error) {
+	pipeline := mongo.Pipeline{
+		// 1. 当前用户所在的群组
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		// 2. 关联出这些群组的所有成员
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.memberCollection.Name(),
+			"localField":   "group_id",
+			"foreignField": "group_id",
+			"as":           "members",
+		}}},
+		{{Key: "$unwind", Value: "$members"}},
+		{{Key: "$match", Value: bson.M{"members.user_id": bson.M{"$nin": exclude}}}},
+		// 3. 按成员统计共同群组数
+		{{Key: "$group", Value: bson.M{"_id": "$members.user_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.memberCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []*CandidateCount
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
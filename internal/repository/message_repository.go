@@ -91,7 +91,7 @@ func (r *MessageRepository) GetAllLastMessages(ctx context.Context, userId primi
 
 	// 4. 按时间倒序排序
 	sortStage := bson.D{
-		{Key: "$sort", Value: bson.D{{"created_at", -1}}},
+		{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}},
 	}
 
 	// 执行聚合查询
@@ -109,6 +109,52 @@ func (r *MessageRepository) GetAllLastMessages(ctx context.Context, userId primi
 	return messages, nil
 }
 
+// LastDirectMessages 批量获取 userID 与每个 peerIDs 之间最新的一条私聊消息，key 为对方的用户 ID
+func (r *MessageRepository) LastDirectMessages(ctx context.Context, userID primitive.ObjectID, peerIDs []primitive.ObjectID) (map[primitive.ObjectID]*model.Message, error) {
+	result := make(map[primitive.ObjectID]*model.Message)
+	if len(peerIDs) == 0 {
+		return result, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		// 1. 双方之间的私聊消息（排除群消息）
+		{{Key: "$match", Value: bson.M{
+			"group_id": nil,
+			"$or": []bson.M{
+				{"sender_id": userID, "receiver_id": bson.M{"$in": peerIDs}},
+				{"sender_id": bson.M{"$in": peerIDs}, "receiver_id": userID},
+			},
+		}}},
+		// 2. 先按时间倒序，分组后取第一条即为最新消息
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$sender_id", userID}}, "$receiver_id", "$sender_id",
+			}},
+			"message": bson.M{"$first": "$$ROOT"},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		PeerID  primitive.ObjectID `bson:"_id"`
+		Message *model.Message     `bson:"message"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.PeerID] = row.Message
+	}
+	return result, nil
+}
+
 // DeleteMessageById 根据 userId, otherId 和 messageId 删除特定的消息
 func (r *MessageRepository) DeleteMessageById(ctx context.Context, filter bson.M) error {
 	// 删除消息
@@ -125,6 +171,216 @@ func (r *MessageRepository) DeleteMessageById(ctx context.Context, filter bson.M
 	return nil
 }
 
+// GroupFileIDs 获取群组消息引用的文件 ID
+func (r *MessageRepository) GroupFileIDs(ctx context.Context, groupID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return r.fileIDs(ctx, bson.M{"group_id": groupID})
+}
+
+// FileReferencedOutsideGroup 判断文件是否还被群组以外的消息引用
+func (r *MessageRepository) FileReferencedOutsideGroup(ctx context.Context, fileID, groupID primitive.ObjectID) (bool, error) {
+	return r.fileReferenced(ctx, bson.M{"file_id": fileID, "group_id": bson.M{"$ne": groupID}})
+}
+
+// ChannelFileIDs 获取群频道消息引用的文件 ID
+func (r *MessageRepository) ChannelFileIDs(ctx context.Context, channelID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return r.fileIDs(ctx, bson.M{"channel_id": channelID})
+}
+
+// FileReferencedOutsideChannel 判断文件是否还被群频道以外的消息引用
+func (r *MessageRepository) FileReferencedOutsideChannel(ctx context.Context, fileID, channelID primitive.ObjectID) (bool, error) {
+	return r.fileReferenced(ctx, bson.M{"file_id": fileID, "channel_id": bson.M{"$ne": channelID}})
+}
+
+// fileIDs 获取满足 filter 的消息引用的文件 ID
+func (r *MessageRepository) fileIDs(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	filter["file_id"] = bson.M{"$exists": true, "$ne": primitive.NilObjectID}
+	values, err := r.collection.Distinct(ctx, "file_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// fileReferenced 判断是否存在满足 filter 的消息
+func (r *MessageRepository) fileReferenced(ctx context.Context, filter bson.M) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindByFileID 查找引用了文件的消息，用于判断用户能否下载该文件
+func (r *MessageRepository) FindByFileID(ctx context.Context, fileID primitive.ObjectID) ([]*model.Message, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"file_id": fileID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*model.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// FindLegacyFileMessages 查找还没有记录文件 ID 的图片和文件消息，最多返回 limit 条
+func (r *MessageRepository) FindLegacyFileMessages(ctx context.Context, limit int64) ([]*model.Message, error) {
+	filter := bson.M{
+		"type":    bson.M{"$in": []model.MessageType{model.ImageMessage, model.FileMessage}},
+		"file_id": bson.M{"$exists": false},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*model.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// SetFileID 记录消息引用的文件 ID，找不到文件时记录空 ID，避免重复处理
+func (r *MessageRepository) SetFileID(ctx context.Context, messageID, fileID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": messageID}, bson.M{"$set": bson.M{"file_id": fileID}})
+	return err
+}
+
+// DeleteByGroupID 删除群组的所有消息
+func (r *MessageRepository) DeleteByGroupID(ctx context.Context, groupID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"group_id": groupID})
+	return err
+}
+
+// DeleteByChannelID 删除群频道的所有消息
+func (r *MessageRepository) DeleteByChannelID(ctx context.Context, channelID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"channel_id": channelID})
+	return err
+}
+
+// AssignChannel 将群组中还没有频道的消息归入 channelID，用于迁移到频道结构
+func (r *MessageRepository) AssignChannel(ctx context.Context, groupID, channelID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"group_id": groupID, "channel_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"channel_id": channelID}},
+	)
+	return err
+}
+
+// ChannelUnreadCount 某个频道中当前用户的未读消息数
+type ChannelUnreadCount struct {
+	ChannelID primitive.ObjectID `bson:"_id"`
+	Count     int64              `bson:"count"`
+}
+
+// CountUnreadByChannel 按频道统计 userID 尚未读的消息数，不含自己发送的消息；没有未读消息的频道不返回
+func (r *MessageRepository) CountUnreadByChannel(ctx context.Context, channelIDs []primitive.ObjectID, userID primitive.ObjectID) ([]*ChannelUnreadCount, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"channel_id":      bson.M{"$in": channelIDs},
+			"sender_id":       bson.M{"$ne": userID},
+			"read_by.user_id": bson.M{"$ne": userID},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": "$channel_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []*ChannelUnreadCount
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// MarkChannelAsRead 将频道中 userID 尚未读的消息全部标记为已读，返回标记的消息数
+func (r *MessageRepository) MarkChannelAsRead(ctx context.Context, channelID, userID primitive.ObjectID) (int64, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"channel_id": channelID, "read_by.user_id": bson.M{"$ne": userID}},
+		bson.M{
+			"$push": bson.M{"read_by": model.ReadReceipt{UserID: userID, ReadAt: now, Timestamp: now}},
+			"$set":  bson.M{"updated_at": now},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// MentionCount 某个群组中提及当前用户的未读消息数
+type MentionCount struct {
+	GroupID primitive.ObjectID `bson:"_id" json:"group_id"`
+	Count   int                `bson:"count" json:"count"`
+}
+
+// mentionFilter 提及 userID 的群消息：直接 @ 了该用户，或在其可读的频道中 @all；不含自己发送的消息
+func mentionFilter(userID primitive.ObjectID, channelIDs []primitive.ObjectID) bson.M {
+	return bson.M{
+		"sender_id":  bson.M{"$ne": userID},
+		"channel_id": bson.M{"$in": channelIDs},
+		"$or": []bson.M{
+			{"mentions": userID},
+			{"mention_all": true},
+		},
+	}
+}
+
+// FindMentions 分页获取 channelIDs 中提及 userID 的群消息，最新的在前
+func (r *MessageRepository) FindMentions(ctx context.Context, userID primitive.ObjectID, channelIDs []primitive.ObjectID, skip, limit int64) ([]*model.Message, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, mentionFilter(userID, channelIDs), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []*model.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// CountUnreadMentions 按群组统计 channelIDs 中提及 userID 且其尚未读的消息数
+func (r *MessageRepository) CountUnreadMentions(ctx context.Context, userID primitive.ObjectID, channelIDs []primitive.ObjectID) ([]*MentionCount, error) {
+	match := mentionFilter(userID, channelIDs)
+	match["read_by.user_id"] = bson.M{"$ne": userID}
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$group_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := []*MentionCount{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
 func (r *MessageRepository) UpdateStatus(ctx context.Context, messageID primitive.ObjectID, status string) error {
 	update := bson.M{
 		"$set": bson.M{
@@ -204,9 +460,11 @@ func (r *MessageRepository) GetUnreadMessages(ctx context.Context, userID primit
 	return messages, nil
 }
 
-func (r *MessageRepository) GetGroupUnreadMessages(ctx context.Context, groupID primitive.ObjectID, userID primitive.ObjectID) ([]*model.Message, error) {
+// GetGroupUnreadMessages 获取群组 channelIDs 频道中 userID 未读的消息
+func (r *MessageRepository) GetGroupUnreadMessages(ctx context.Context, groupID primitive.ObjectID, channelIDs []primitive.ObjectID, userID primitive.ObjectID) ([]*model.Message, error) {
 	filter := bson.M{
-		"group_id": groupID,
+		"group_id":   groupID,
+		"channel_id": bson.M{"$in": channelIDs},
 		"read_by.user_id": bson.M{
 			"$ne": userID,
 		},
@@ -226,9 +484,11 @@ func (r *MessageRepository) GetGroupUnreadMessages(ctx context.Context, groupID
 	return messages, nil
 }
 
-func (r *MessageRepository) GetGroupUnreadCount(ctx context.Context, groupID primitive.ObjectID, userID primitive.ObjectID) (int64, error) {
+// GetGroupUnreadCount 统计群组 channelIDs 频道中 userID 未读的消息数
+func (r *MessageRepository) GetGroupUnreadCount(ctx context.Context, groupID primitive.ObjectID, channelIDs []primitive.ObjectID, userID primitive.ObjectID) (int64, error) {
 	filter := bson.M{
-		"group_id": groupID,
+		"group_id":   groupID,
+		"channel_id": bson.M{"$in": channelIDs},
 		"read_by.user_id": bson.M{
 			"$ne": userID,
 		},
@@ -248,3 +508,38 @@ func (r *MessageRepository) GetByID(ctx context.Context, id primitive.ObjectID)
 	}
 	return &message, nil
 }
+
+// AnonymizeUser 将用户在历史消息中的名称替换为占位名称
+func (r *MessageRepository) AnonymizeUser(ctx context.Context, userID primitive.ObjectID, username, placeholder string) error {
+	if _, err := r.collection.UpdateMany(ctx,
+		bson.M{"sender_id": userID},
+		bson.M{"$set": bson.M{"sender": placeholder}},
+	); err != nil {
+		return err
+	}
+
+	if _, err := r.collection.UpdateMany(ctx,
+		bson.M{"receiver_id": userID},
+		bson.M{"$set": bson.M{"receiver": placeholder}},
+	); err != nil {
+		return err
+	}
+
+	// 引用消息里只保存了发送者名称，按名称匹配替换
+	if username == "" {
+		return nil
+	}
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"reply.sender": username},
+		bson.M{"$set": bson.M{"reply.$[r].sender": placeholder}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"r.sender": username}},
+		}),
+	)
+	return err
+}
+
+// Count 统计满足条件的消息数量
+func (r *MessageRepository) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return r.collection.CountDocuments(ctx, filter)
+}
@@ -2,8 +2,10 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"log"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -13,12 +15,24 @@ var DB *mongo.Database
 
 // 定义一些常量，表示数据库中各个集合的名称
 const (
-	UserCollection         = "CHATROOM_DB_users"         // 用户集合
-	MessageCollection      = "CHATROOM_DB_messages"      // 消息集合
-	GroupCollection        = "CHATROOM_DB_groups"        // 群组集合
-	FileCollection         = "CHATROOM_DB_files"         // 文件集合
-	NotificationCollection = "CHATROOM_DB_notifications" // 通知集合
-	FriendshipCollection   = "CHATROOM_DB_friendships"   // 好友关系集合
+	UserCollection              = "CHATROOM_DB_users"               // 用户集合
+	MessageCollection           = "CHATROOM_DB_messages"            // 消息集合
+	GroupCollection             = "CHATROOM_DB_groups"              // 群组集合
+	FileCollection              = "CHATROOM_DB_files"               // 文件集合
+	NotificationCollection      = "CHATROOM_DB_notifications"       // 通知集合
+	FriendshipCollection        = "CHATROOM_DB_friendships"         // 好友关系集合
+	AuditCollection             = "CHATROOM_DB_audit_logs"          // 审计日志集合
+	FriendRequestCollection     = "CHATROOM_DB_friend_requests"     // 好友请求集合
+	BlockCollection             = "CHATROOM_DB_blocks"              // 黑名单集合
+	FriendTagCollection         = "CHATROOM_DB_friend_tags"         // 好友标签集合
+	FriendMetaCollection        = "CHATROOM_DB_friend_metas"        // 好友备注集合
+	GroupInviteCollection       = "CHATROOM_DB_group_invites"       // 群邀请集合
+	GroupJoinCollection         = "CHATROOM_DB_group_joins"         // 入群申请集合
+	GroupMemberCollection       = "group_members"                   // 群组成员集合，群成员关系以此为准
+	GroupAnnouncementCollection = "CHATROOM_DB_group_announcements" // 群公告集合
+	GroupChannelCollection      = "CHATROOM_DB_group_channels"      // 群频道集合
+	FileUploadCollection        = "CHATROOM_DB_file_uploads"        // 待确认的文件上传集合
+	ResumableUploadCollection   = "CHATROOM_DB_resumable_uploads"   // 分片上传集合
 )
 
 // InitMongoDB 用于初始化 MongoDB 连接
@@ -34,11 +48,32 @@ func InitMongoDB(uri, dbName string) {
 		log.Fatalf("Failed to ping MongoDB: %v", err) // 如果连接失败，日志并退出
 	}
 
+	// 群成员增删、转让群主等操作依赖事务，连接到不支持事务的单机 mongod 时直接退出
+	if err = checkTransactionSupport(client); err != nil {
+		log.Fatalf("MongoDB does not support transactions: %v. "+
+			"Run mongod as a replica set (a single-node replica set is fine for development) and add replicaSet=<name> to mongodb.uri", err)
+	}
+
 	// 获取指定数据库
 	DB = client.Database(dbName)
 	log.Println("Successfully connected to MongoDB") // 连接成功，输出日志
 }
 
+// checkTransactionSupport 判断连接的 MongoDB 是否支持事务：副本集成员或分片集群的 mongos
+func checkTransactionSupport(client *mongo.Client) error {
+	var reply struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(context.Background(), bson.D{{Key: "isMaster", Value: 1}}).Decode(&reply); err != nil {
+		return err
+	}
+	if reply.SetName == "" && reply.Msg != "isdbgrid" {
+		return errors.New("server is a standalone mongod")
+	}
+	return nil
+}
+
 // 以下是一些辅助函数，用于获取 MongoDB 数据库中的集合
 // 这些函数会返回对应集合的 *mongo.Collection 对象，可以用来执行数据库操作
 
@@ -71,3 +106,78 @@ func GetNotificationCollection() *mongo.Collection {
 func GetFriendshipCollection() *mongo.Collection {
 	return DB.Collection(FriendshipCollection)
 }
+
+// GetAuditCollection 获取审计日志集合
+func GetAuditCollection() *mongo.Collection {
+	return DB.Collection(AuditCollection)
+}
+
+// GetFriendRequestCollection 获取好友请求集合
+func GetFriendRequestCollection() *mongo.Collection {
+	return DB.Collection(FriendRequestCollection)
+}
+
+// GetBlockCollection 获取黑名单集合
+func GetBlockCollection() *mongo.Collection {
+	return DB.Collection(BlockCollection)
+}
+
+// GetFriendTagCollection 获取好友标签集合
+func GetFriendTagCollection() *mongo.Collection {
+	return DB.Collection(FriendTagCollection)
+}
+
+// GetFriendMetaCollection 获取好友备注集合
+func GetFriendMetaCollection() *mongo.Collection {
+	return DB.Collection(FriendMetaCollection)
+}
+
+// GetGroupMemberCollection 获取群组成员集合
+func GetGroupMemberCollection() *mongo.Collection {
+	return DB.Collection(GroupMemberCollection)
+}
+
+// WithTransaction 在事务中执行 fn，fn 中的数据库操作必须使用传入的 SessionContext
+// MongoDB 只在副本集或分片集群上支持事务，单机部署需要以单节点副本集方式启动
+func WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := DB.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// GetGroupInviteCollection 获取群邀请集合
+func GetGroupInviteCollection() *mongo.Collection {
+	return DB.Collection(GroupInviteCollection)
+}
+
+// GetGroupAnnouncementCollection 获取群公告集合
+func GetGroupAnnouncementCollection() *mongo.Collection {
+	return DB.Collection(GroupAnnouncementCollection)
+}
+
+// GetGroupJoinCollection 获取入群申请集合
+func GetGroupJoinCollection() *mongo.Collection {
+	return DB.Collection(GroupJoinCollection)
+}
+
+// GetGroupChannelCollection 获取群频道集合
+func GetGroupChannelCollection() *mongo.Collection {
+	return DB.Collection(GroupChannelCollection)
+}
+
+// GetFileUploadCollection 获取待确认的文件上传集合
+func GetFileUploadCollection() *mongo.Collection {
+	return DB.Collection(FileUploadCollection)
+}
+
+// GetResumableUploadCollection 获取分片上传集合
+func GetResumableUploadCollection() *mongo.Collection {
+	return DB.Collection(ResumableUploadCollection)
+}
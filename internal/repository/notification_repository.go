@@ -103,3 +103,29 @@ func (r *NotificationRepository) DeleteAllByUserID(ctx context.Context, userID p
 	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
 	return err
 }
+
+// CreateMany 批量写入通知，用于系统广播
+func (r *NotificationRepository) CreateMany(ctx context.Context, notifications []*model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, len(notifications))
+	for i, notification := range notifications {
+		notification.CreatedAt = now
+		notification.UpdatedAt = now
+		notification.IsRead = false
+		docs[i] = notification
+	}
+
+	result, err := r.collection.InsertMany(ctx, docs)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range result.InsertedIDs {
+		notifications[i].ID = id.(primitive.ObjectID)
+	}
+	return nil
+}
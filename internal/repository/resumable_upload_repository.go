@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository/mongodb"
+	"context"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResumableUploadRepository 是分片上传的仓库结构体
+type ResumableUploadRepository struct {
+	collection *mongo.Collection // MongoDB 中的分片上传集合
+}
+
+// NewResumableUploadRepository 返回一个新的 ResumableUploadRepository 实例
+func NewResumableUploadRepository() *ResumableUploadRepository {
+	return &ResumableUploadRepository{
+		collection: mongodb.GetResumableUploadCollection(),
+	}
+}
+
+// Create 记录一次新的分片上传
+func (r *ResumableUploadRepository) Create(ctx context.Context, upload *model.ResumableUpload) error {
+	if upload.ID.IsZero() {
+		upload.ID = primitive.NewObjectID()
+	}
+	upload.CreatedAt = time.Now()
+	upload.UpdatedAt = upload.CreatedAt
+	upload.Parts = map[string]model.UploadedPart{}
+
+	_, err := r.collection.InsertOne(ctx, upload)
+	return err
+}
+
+// FindByID 查找一次分片上传，不存在时返回 mongo.ErrNoDocuments
+func (r *ResumableUploadRepository) FindByID(ctx context.Context, uploadID primitive.ObjectID) (*model.ResumableUpload, error) {
+	var upload model.ResumableUpload
+	if err := r.collection.FindOne(ctx, bson.M{"_id": uploadID}).Decode(&upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// SetPart 记录已上传的分片（重传同一序号时覆盖）并顺延过期时间，上传已不存在时返回 mongo.ErrNoDocuments
+func (r *ResumableUploadRepository) SetPart(ctx context.Context, uploadID primitive.ObjectID, part model.UploadedPart, expiresAt time.Time) error {
+	update := bson.M{"$set": bson.M{
+		"parts." + strconv.Itoa(part.Number): part,
+		"expires_at":                         expiresAt,
+		"updated_at":                         part.UploadedAt,
+	}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": uploadID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete 删除分片上传记录，记录不存在时返回 mongo.ErrNoDocuments
+func (r *ResumableUploadRepository) Delete(ctx context.Context, uploadID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": uploadID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FindExpired 查找在 before 之前过期的分片上传，最多返回 limit 条
+func (r *ResumableUploadRepository) FindExpired(ctx context.Context, before time.Time, limit int64) ([]*model.ResumableUpload, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"expires_at": bson.M{"$lt": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var uploads []*model.ResumableUpload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
@@ -4,15 +4,38 @@ import (
 	"chatweb/internal/model"
 	"chatweb/internal/repository/mongodb"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var (
+	// ErrEmailTaken 邮箱已被其他账号使用
+	ErrEmailTaken = errors.New("email already exists")
+	// ErrUsernameTaken 用户名已被其他账号使用
+	ErrUsernameTaken = errors.New("username already exists")
+	// ErrPhoneTaken 手机号已被其他账号使用
+	ErrPhoneTaken = errors.New("phone number already exists")
+)
+
+// uniqueUserFields 用户集合中需要唯一的字段及冲突时返回的错误
+var uniqueUserFields = []struct {
+	field string
+	err   error
+}{
+	{"email", ErrEmailTaken},
+	{"username", ErrUsernameTaken},
+	{"phone", ErrPhoneTaken},
+}
+
 type UserRepository struct {
 	collection *mongo.Collection
 }
@@ -23,13 +46,43 @@ func NewUserRepository() *UserRepository {
 	}
 }
 
+// EnsureIndexes 创建邮箱、用户名和手机号的唯一索引；已注销账号和 SSO 账号的邮箱或手机号为空，不参与唯一性约束
+func (r *UserRepository) EnsureIndexes(ctx context.Context) error {
+	models := make([]mongo.IndexModel, 0, len(uniqueUserFields))
+	for _, unique := range uniqueUserFields {
+		models = append(models, mongo.IndexModel{
+			Keys: bson.D{{Key: unique.field, Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{unique.field: bson.M{"$gt": ""}}),
+		})
+	}
+	_, err := r.collection.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// uniqueFieldError 把唯一索引冲突转换为对应字段的错误，其他错误原样返回
+func uniqueFieldError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	for _, unique := range uniqueUserFields {
+		// 索引名为默认的 <字段>_1，出现在冲突错误信息中
+		if strings.Contains(err.Error(), " "+unique.field+"_1 ") {
+			return unique.err
+		}
+	}
+	return err
+}
+
+// Create 创建用户，邮箱、用户名或手机号已被使用时返回 ErrEmailTaken、ErrUsernameTaken 或 ErrPhoneTaken
 func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
 	result, err := r.collection.InsertOne(ctx, user)
 	if err != nil {
-		return err
+		return uniqueFieldError(err)
 	}
 
 	user.ID = result.InsertedID.(primitive.ObjectID)
@@ -54,6 +107,16 @@ func (r *UserRepository) FindByPhone(ctx context.Context, phone string) (*model.
 	return &user, nil
 }
 
+// FindByUsername 根据用户名查找用户
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	var user model.User
+	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
 	log.Print("findByiD", id)
 	var user model.User
@@ -64,6 +127,7 @@ func (r *UserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*
 	return &user, nil
 }
 
+// Update 更新用户字段，用户名或手机号已被其他账号使用时返回 ErrUsernameTaken 或 ErrPhoneTaken
 func (r *UserRepository) Update(ctx context.Context, id primitive.ObjectID, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
 	_, err := r.collection.UpdateOne(
@@ -71,7 +135,7 @@ func (r *UserRepository) Update(ctx context.Context, id primitive.ObjectID, upda
 		bson.M{"_id": id},
 		bson.M{"$set": updates},
 	)
-	return err
+	return uniqueFieldError(err)
 }
 
 // SearchUserByIdentifier 通过标识符（手机号、邮箱或用户名）搜索用户
@@ -127,6 +191,21 @@ func (r *UserRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID
 	return users, nil
 }
 
+// FindByUsernames 根据用户名批量查询用户
+func (r *UserRepository) FindByUsernames(ctx context.Context, usernames []string) ([]*model.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"username": bson.M{"$in": usernames}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // UpdateUserAvatar 更新用户头像 URL
 func (r *UserRepository) UpdateUserAvatar(ctx context.Context, userID string, avatarURL string) error {
 	log.Print("userId", userID)
@@ -143,3 +222,483 @@ func (r *UserRepository) UpdateUserAvatar(ctx context.Context, userID string, av
 	_, err = r.collection.UpdateOne(ctx, filter, update)
 	return err
 }
+
+// UpdatePassword 更新密码并递增 token 版本，返回新的版本号
+func (r *UserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) (int, error) {
+	update := bson.M{
+		"$set": bson.M{"password": hashedPassword, "updated_at": time.Now()},
+		"$inc": bson.M{"token_version": 1},
+	}
+	return r.updateTokenVersion(ctx, id, update)
+}
+
+// IncrementTokenVersion 递增 token 版本，使该用户之前签发的 token 全部失效，返回新的版本号
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, id primitive.ObjectID) (int, error) {
+	update := bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$inc": bson.M{"token_version": 1},
+	}
+	return r.updateTokenVersion(ctx, id, update)
+}
+
+// updateTokenVersion 执行包含 token_version 自增的更新并返回更新后的版本号
+func (r *UserRepository) updateTokenVersion(ctx context.Context, id primitive.ObjectID, update bson.M) (int, error) {
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"token_version": 1})
+
+	var user model.User
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&user); err != nil {
+		return 0, err
+	}
+	return user.TokenVersion, nil
+}
+
+// SetPendingEmail 记录待验证的新邮箱及验证码
+func (r *UserRepository) SetPendingEmail(ctx context.Context, id primitive.ObjectID, email, codeHash string, expiresAt time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"pending_email":         email,
+			"email_code_hash":       codeHash,
+			"email_code_expires_at": expiresAt,
+			"email_code_attempts":   0,
+			"updated_at":            time.Now(),
+		},
+	})
+	return err
+}
+
+// IncrementEmailCodeAttempts 记录一次验证码校验失败
+func (r *UserRepository) IncrementEmailCodeAttempts(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"email_code_attempts": 1},
+	})
+	return err
+}
+
+// SetReauthCode 记录身份确认验证码，覆盖之前未使用的验证码
+func (r *UserRepository) SetReauthCode(ctx context.Context, id primitive.ObjectID, codeHash string, expiresAt time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"reauth_code_hash":       codeHash,
+			"reauth_code_expires_at": expiresAt,
+			"reauth_code_attempts":   0,
+			"updated_at":             time.Now(),
+		},
+	})
+	return err
+}
+
+// IncrementReauthCodeAttempts 记录一次身份确认验证码校验失败
+func (r *UserRepository) IncrementReauthCodeAttempts(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"reauth_code_attempts": 1},
+	})
+	return err
+}
+
+// ConsumeReauthCode 清除身份确认验证码，验证码已被并发请求使用时返回 mongo.ErrNoDocuments
+func (r *UserRepository) ConsumeReauthCode(ctx context.Context, id primitive.ObjectID, codeHash string) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "reauth_code_hash": codeHash}, bson.M{
+		"$unset": bson.M{
+			"reauth_code_hash":       "",
+			"reauth_code_expires_at": "",
+			"reauth_code_attempts":   "",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ConfirmEmail 将邮箱更新为新邮箱，并清除待验证信息；邮箱已被其他账号使用时返回 ErrEmailTaken
+func (r *UserRepository) ConfirmEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"email": email, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"pending_email":         "",
+			"email_code_hash":       "",
+			"email_code_expires_at": "",
+			"email_code_attempts":   "",
+		},
+	})
+	return uniqueFieldError(err)
+}
+
+// ScheduleDeletion 设置计划注销时间并使所有 token 失效
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"deletion_scheduled_at": at, "updated_at": time.Now()},
+		"$inc": bson.M{"token_version": 1},
+	})
+	return err
+}
+
+// CancelDeletion 撤销计划中的注销
+func (r *UserRepository) CancelDeletion(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{"deletion_scheduled_at": ""},
+	})
+	return err
+}
+
+// FindDueForDeletion 查找冷静期已结束、等待清理的账号
+func (r *UserRepository) FindDueForDeletion(ctx context.Context, now time.Time) ([]*model.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"deletion_scheduled_at": bson.M{"$lte": now},
+		"deleted_at":            bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Anonymize 抹去用户的个人信息，只保留一条占位记录，保证历史消息中的引用仍然有效
+func (r *UserRepository) Anonymize(ctx context.Context, id primitive.ObjectID, placeholderName string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"username":   placeholderName,
+			"email":      "",
+			"phone":      "",
+			"avatar":     "",
+			"password":   "",
+			"deleted_at": now,
+			"updated_at": now,
+		},
+		"$unset": bson.M{
+			"phone_hash":             "",
+			"email_hash":             "",
+			"contact_hash_key":       "",
+			"deletion_scheduled_at":  "",
+			"pending_email":          "",
+			"email_code_hash":        "",
+			"email_code_expires_at":  "",
+			"email_code_attempts":    "",
+			"reauth_code_hash":       "",
+			"reauth_code_expires_at": "",
+			"reauth_code_attempts":   "",
+		},
+		"$inc": bson.M{"token_version": 1},
+	})
+	return err
+}
+
+// UserListFilter 管理后台查询用户列表的过滤条件
+type UserListFilter struct {
+	Query    string         // 按用户名、邮箱、手机号模糊匹配
+	Role     model.UserRole // 按角色过滤，为空表示不过滤
+	Disabled *bool          // 按禁用状态过滤，为 nil 表示不过滤
+}
+
+// ListUsers 分页查询用户列表（不含已注销账号），返回当前页的用户和总数
+func (r *UserRepository) ListUsers(ctx context.Context, f UserListFilter, skip, limit int64) ([]*model.User, int64, error) {
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
+	if f.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(f.Query), Options: "i"}
+		filter["$or"] = []bson.M{
+			{"username": pattern},
+			{"email": pattern},
+			{"phone": pattern},
+		}
+	}
+	if f.Role == model.RoleUser {
+		// 历史数据没有角色字段，同样视为普通用户
+		filter["role"] = bson.M{"$in": []interface{}{model.RoleUser, nil}}
+	} else if f.Role != "" {
+		filter["role"] = f.Role
+	}
+	if f.Disabled != nil {
+		if *f.Disabled {
+			filter["disabled"] = true
+		} else {
+			filter["disabled"] = bson.M{"$ne": true}
+		}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// Count 统计满足条件的用户数量
+func (r *UserRepository) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+// FindActiveIDs 返回所有未注销、未禁用用户的 ID
+func (r *UserRepository) FindActiveIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	return r.activeIDs(ctx, bson.M{})
+}
+
+// FilterActiveIDs 返回 ids 中未注销、未禁用用户的 ID，顺序不保证与 ids 相同
+func (r *UserRepository) FilterActiveIDs(ctx context.Context, ids []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return r.activeIDs(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+// activeIDs 返回满足 filter 的未注销、未禁用用户的 ID
+func (r *UserRepository) activeIDs(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	filter["disabled"] = bson.M{"$ne": true}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ids []primitive.ObjectID
+	for cursor.Next(ctx) {
+		var user model.User
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// Disable 禁用账号并使所有 token 失效
+func (r *UserRepository) Disable(ctx context.Context, id primitive.ObjectID, reason string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"disabled":        true,
+			"disabled_reason": reason,
+			"disabled_at":     now,
+			"updated_at":      now,
+		},
+		"$inc": bson.M{"token_version": 1},
+	})
+	return err
+}
+
+// Enable 解除账号禁用
+func (r *UserRepository) Enable(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{"disabled": "", "disabled_reason": "", "disabled_at": ""},
+	})
+	return err
+}
+
+// UpdateRole 修改用户角色并递增 token 版本，使携带旧角色的 token 失效
+func (r *UserRepository) UpdateRole(ctx context.Context, id primitive.ObjectID, role model.UserRole) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"role": role, "updated_at": time.Now()},
+		"$inc": bson.M{"token_version": 1},
+	})
+	return err
+}
+
+// PromoteToAdmin 将指定用户提升为管理员，已是管理员的用户不受影响，返回实际修改的数量
+func (r *UserRepository) PromoteToAdmin(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx, bson.M{
+		"_id":  bson.M{"$in": ids},
+		"role": bson.M{"$ne": model.RoleAdmin},
+	}, bson.M{
+		"$set": bson.M{"role": model.RoleAdmin, "updated_at": time.Now()},
+		"$inc": bson.M{"token_version": 1},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// FindByIdentity 根据外部身份（身份提供方 + subject）查找用户
+func (r *UserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*model.User, error) {
+	var user model.User
+	err := r.collection.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}},
+	}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AddIdentity 为用户关联一个外部身份，已关联过的身份不会重复添加
+func (r *UserRepository) AddIdentity(ctx context.Context, id primitive.ObjectID, identity model.ExternalIdentity) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{
+		"_id": id,
+		"identities": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"provider": identity.Provider,
+			"subject":  identity.Subject,
+		}}},
+	}, bson.M{
+		"$push": bson.M{"identities": identity},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+// SetContactHashes 保存用户手机号和邮箱的哈希以及所用盐的指纹
+func (r *UserRepository) SetContactHashes(ctx context.Context, id primitive.ObjectID, phoneHash, emailHash, key string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"phone_hash": phoneHash, "email_hash": emailHash, "contact_hash_key": key},
+	})
+	return err
+}
+
+// FindStaleContactHashes 查询哈希缺失或由其他盐计算的用户（不含已注销账号），最多返回 limit 条
+func (r *UserRepository) FindStaleContactHashes(ctx context.Context, key string, limit int64) ([]*model.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"contact_hash_key": bson.M{"$ne": key},
+		"deleted_at":       bson.M{"$exists": false},
+	}, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FindByContactHashes 查询手机号或邮箱哈希在给定列表中的正常用户
+func (r *UserRepository) FindByContactHashes(ctx context.Context, hashes []string) ([]*model.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"phone_hash": bson.M{"$in": hashes}},
+			{"email_hash": bson.M{"$in": hashes}},
+		},
+		"deleted_at": bson.M{"$exists": false},
+		"disabled":   bson.M{"$ne": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdatePrivacy 更新隐私设置中的部分字段，updates 的 key 为 privacy 下的字段名
+func (r *UserRepository) UpdatePrivacy(ctx context.Context, id primitive.ObjectID, updates bson.M) error {
+	set := bson.M{"updated_at": time.Now()}
+	for key, value := range updates {
+		set["privacy."+key] = value
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+// DirectorySearch 用户目录搜索条件
+type DirectorySearch struct {
+	Query        string               // 搜索关键字，按用户名和昵称做前缀和模糊匹配
+	Exclude      []primitive.ObjectID // 不出现在结果中的用户（自己、拉黑等）
+	FriendIDs    []primitive.ObjectID // 当前用户的好友，排在最前
+	GroupPeerIDs []primitive.ObjectID // 与当前用户同在某个群组的用户，排在好友之后
+}
+
+// DirectoryRow 用户目录搜索结果
+type DirectoryRow struct {
+	model.User  `bson:",inline"`
+	IsFriend    bool `bson:"is_friend"`    // 是否为好友
+	SharedGroup bool `bson:"shared_group"` // 是否有共同群组
+}
+
+// SearchDirectory 按用户名和昵称搜索用户（不含已注销和被禁用的账号）
+// 排序依次为：好友、共同群组成员、前缀匹配、模糊匹配，同一级内按用户名排序；返回当前页和总数
+func (r *UserRepository) SearchDirectory(ctx context.Context, s DirectorySearch, skip, limit int64) ([]*DirectoryRow, int64, error) {
+	// 模糊匹配：关键字中的字符按顺序出现即可，例如 "zs" 可以匹配 "zhangsan"
+	runes := []rune(s.Query)
+	parts := make([]string, len(runes))
+	for i, c := range runes {
+		parts[i] = regexp.QuoteMeta(string(c))
+	}
+	fuzzy := primitive.Regex{Pattern: strings.Join(parts, ".*"), Options: "i"}
+	prefix := "^" + regexp.QuoteMeta(s.Query)
+
+	prefixMatch := func(field string) bson.M {
+		return bson.M{"$regexMatch": bson.M{
+			"input":   bson.M{"$ifNull": bson.A{field, ""}},
+			"regex":   prefix,
+			"options": "i",
+		}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"_id":        bson.M{"$nin": s.Exclude},
+			"deleted_at": bson.M{"$exists": false},
+			"disabled":   bson.M{"$ne": true},
+			"$or":        []bson.M{{"username": fuzzy}, {"nickname": fuzzy}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"is_friend":    bson.M{"$in": bson.A{"$_id", s.FriendIDs}},
+			"shared_group": bson.M{"$in": bson.A{"$_id", s.GroupPeerIDs}},
+			"prefix_match": bson.M{"$or": bson.A{prefixMatch("$username"), prefixMatch("$nickname")}},
+		}}},
+		{{Key: "$sort", Value: bson.D{
+			{Key: "is_friend", Value: -1},
+			{Key: "shared_group", Value: -1},
+			{Key: "prefix_match", Value: -1},
+			{Key: "username", Value: 1},
+		}}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"users": bson.A{bson.M{"$skip": skip}, bson.M{"$limit": limit}},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Users []*DirectoryRow `bson:"users"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	if len(results) == 0 || len(results[0].Total) == 0 {
+		return []*DirectoryRow{}, 0, nil
+	}
+	return results[0].Users, results[0].Total[0].Count, nil
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"chatweb/pkg/event"
+	"chatweb/pkg/mail"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxEmailCodeAttempts = 5       // 邮箱验证码最多尝试次数，超过后需重新申请
+	deletedUserName      = "已注销用户" // 注销后历史消息中显示的名称
+)
+
+// AccountService 处理修改密码、修改邮箱和注销账号等账号安全操作
+type AccountService struct {
+	userRepo            *repository.UserRepository          // 用户存储库
+	messageRepo         *repository.MessageRepository       // 消息存储库，注销时匿名化消息
+	friendshipRepo      *repository.FriendshipRepository    // 好友关系存储库，注销时删除好友关系
+	friendRequestRepo   *repository.FriendRequestRepository // 好友请求存储库，注销时删除好友请求
+	blockRepo           *repository.BlockRepository         // 黑名单存储库，注销时删除拉黑记录
+	friendTagRepo       *repository.FriendTagRepository     // 好友标签存储库，注销时删除标签
+	friendMetaRepo      *repository.FriendMetaRepository    // 好友备注存储库，注销时删除备注
+	groupRepo           *repository.GroupRepository         // 群组存储库，注销时退出所有群组
+	groupChannelRepo    *repository.GroupChannelRepository  // 群频道存储库，注销时退出私有频道
+	groupInviteRepo     *repository.GroupInviteRepository   // 群邀请存储库，注销时删除相关邀请
+	groupJoinRepo       *repository.GroupJoinRepository     // 入群申请存储库，注销时删除入群申请
+	notificationRepo    *repository.NotificationRepository  // 通知存储库，注销时清理通知
+	userService         *UserService                        // 用户服务，用于签发新 token
+	fileService         *FileService                        // 文件服务，注销时删除用户文件
+	notificationService *NotificationService                // 通知服务，用于发送安全提醒
+	mailer              mail.Sender                         // 邮件发送器，用于发送邮箱验证码
+	eventBus            *event.EventBus                     // 事件总线，会话失效时断开 WebSocket 连接
+	emailCodeTTL        time.Duration                       // 邮箱验证码有效期
+	deletionGrace       time.Duration                       // 注销冷静期
+}
+
+// NewAccountService 创建一个新的 AccountService 实例
+func NewAccountService(
+	userRepo *repository.UserRepository,
+	messageRepo *repository.MessageRepository,
+	friendshipRepo *repository.FriendshipRepository,
+	friendRequestRepo *repository.FriendRequestRepository,
+	blockRepo *repository.BlockRepository,
+	friendTagRepo *repository.FriendTagRepository,
+	friendMetaRepo *repository.FriendMetaRepository,
+	groupRepo *repository.GroupRepository,
+	groupChannelRepo *repository.GroupChannelRepository,
+	groupInviteRepo *repository.GroupInviteRepository,
+	groupJoinRepo *repository.GroupJoinRepository,
+	notificationRepo *repository.NotificationRepository,
+	userService *UserService,
+	fileService *FileService,
+	notificationService *NotificationService,
+	mailer mail.Sender,
+	eventBus *event.EventBus,
+	emailCodeTTL time.Duration,
+	deletionGrace time.Duration,
+) *AccountService {
+	return &AccountService{
+		userRepo:            userRepo,
+		messageRepo:         messageRepo,
+		friendshipRepo:      friendshipRepo,
+		friendRequestRepo:   friendRequestRepo,
+		blockRepo:           blockRepo,
+		friendTagRepo:       friendTagRepo,
+		friendMetaRepo:      friendMetaRepo,
+		groupRepo:           groupRepo,
+		groupChannelRepo:    groupChannelRepo,
+		groupInviteRepo:     groupInviteRepo,
+		groupJoinRepo:       groupJoinRepo,
+		notificationRepo:    notificationRepo,
+		userService:         userService,
+		fileService:         fileService,
+		notificationService: notificationService,
+		mailer:              mailer,
+		eventBus:            eventBus,
+		emailCodeTTL:        emailCodeTTL,
+		deletionGrace:       deletionGrace,
+	}
+}
+
+// ChangePassword 校验旧密码后修改密码，其他会话全部失效，返回当前会话使用的新 token
+func (s *AccountService) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) (string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
+		return "", errors.New("old password is incorrect")
+	}
+
+	if oldPassword == newPassword {
+		return "", errors.New("new password must be different from the old one")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	// 更新密码的同时递增 token 版本，其他设备上的 token 随之失效
+	version, err := s.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword))
+	if err != nil {
+		return "", err
+	}
+	user.TokenVersion = version
+	s.revokeSessions(user.ID, "password_changed")
+
+	if err := s.notificationService.CreateSystemNotification(ctx, user.ID, "密码已修改",
+		"您的登录密码已修改，其他设备上的登录已失效。如果不是您本人操作，请立即联系管理员。"); err != nil {
+		log.Printf("Failed to send password change notification: %v", err)
+	}
+
+	return s.userService.IssueToken(user)
+}
+
+// RequestReauthCode 向没有密码的（SSO）用户当前邮箱发送身份确认验证码，用于修改邮箱和注销账号
+func (s *AccountService) RequestReauthCode(ctx context.Context, userID string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Password != "" {
+		return errors.New("account has a password, confirm with the password instead")
+	}
+	if user.Email == "" {
+		return errors.New("account has no email to send the code to")
+	}
+
+	code, err := generateEmailCode()
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.SetReauthCode(ctx, user.ID, hashEmailCode(code), time.Now().Add(s.emailCodeTTL)); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("您正在确认 ChatWeb 账号的身份以修改邮箱或注销账号，验证码为 %s，%d 分钟内有效。如果不是您本人操作，请忽略本邮件。",
+		code, int(s.emailCodeTTL.Minutes()))
+	if err := s.mailer.Send(user.Email, "ChatWeb 身份确认", body); err != nil {
+		return fmt.Errorf("failed to send verification email: %v", err)
+	}
+	return nil
+}
+
+// verifyIdentity 敏感操作前再次确认身份：有密码的用户校验密码，没有密码的（SSO）用户校验身份确认验证码
+// 验证码校验通过后立即失效
+func (s *AccountService) verifyIdentity(ctx context.Context, user *model.User, password, code string) error {
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+			return errors.New("password is incorrect")
+		}
+		return nil
+	}
+
+	if code == "" {
+		return errors.New("verification code is required, request one first")
+	}
+	if user.ReauthCodeHash == "" || user.ReauthCodeExpiresAt == nil ||
+		time.Now().After(*user.ReauthCodeExpiresAt) || user.ReauthCodeAttempts >= maxEmailCodeAttempts {
+		return errors.New("verification code expired, please request a new one")
+	}
+	codeHash := hashEmailCode(code)
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(user.ReauthCodeHash)) != 1 {
+		if err := s.userRepo.IncrementReauthCodeAttempts(ctx, user.ID); err != nil {
+			log.Printf("Failed to record reauth code attempt: %v", err)
+		}
+		return errors.New("invalid verification code")
+	}
+	if err := s.userRepo.ConsumeReauthCode(ctx, user.ID, codeHash); err != nil {
+		return errors.New("verification code expired, please request a new one")
+	}
+	return nil
+}
+
+// RequestEmailChange 确认身份后向新邮箱发送验证码，验证通过前邮箱不会变更
+// 有密码的用户提供 password，没有密码的（SSO）用户提供通过 RequestReauthCode 获取的 reauthCode
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID, password, reauthCode, newEmail string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyIdentity(ctx, user, password, reauthCode); err != nil {
+		return err
+	}
+
+	newEmail = strings.TrimSpace(newEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return errors.New("new email is the same as the current one")
+	}
+
+	if _, err := s.userRepo.FindByEmail(ctx, newEmail); err == nil {
+		return errors.New("email already exists")
+	}
+
+	code, err := generateEmailCode()
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetPendingEmail(ctx, user.ID, newEmail, hashEmailCode(code), time.Now().Add(s.emailCodeTTL)); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("您正在将 ChatWeb 账号的邮箱修改为 %s，验证码为 %s，%d 分钟内有效。如果不是您本人操作，请忽略本邮件。",
+		newEmail, code, int(s.emailCodeTTL.Minutes()))
+	if err := s.mailer.Send(newEmail, "ChatWeb 邮箱验证", body); err != nil {
+		return fmt.Errorf("failed to send verification email: %v", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange 校验验证码并将邮箱更新为待验证的新邮箱
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, userID, code string) (*model.User, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PendingEmail == "" || user.EmailCodeExpiresAt == nil {
+		return nil, errors.New("no pending email change")
+	}
+
+	if time.Now().After(*user.EmailCodeExpiresAt) || user.EmailCodeAttempts >= maxEmailCodeAttempts {
+		return nil, errors.New("verification code expired, please request a new one")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashEmailCode(code)), []byte(user.EmailCodeHash)) != 1 {
+		if err := s.userRepo.IncrementEmailCodeAttempts(ctx, user.ID); err != nil {
+			log.Printf("Failed to record email code attempt: %v", err)
+		}
+		return nil, errors.New("invalid verification code")
+	}
+
+	// 发送验证码后可能已有其他账号注册了该邮箱
+	if _, err := s.userRepo.FindByEmail(ctx, user.PendingEmail); err == nil {
+		return nil, errors.New("email already exists")
+	}
+
+	oldEmail := user.Email
+	if err := s.userRepo.ConfirmEmail(ctx, user.ID, user.PendingEmail); err != nil {
+		return nil, err
+	}
+
+	// 通知旧邮箱，便于账号被盗时及时发现
+	if err := s.mailer.Send(oldEmail, "ChatWeb 邮箱已变更",
+		fmt.Sprintf("您的 ChatWeb 账号邮箱已修改为 %s。如果不是您本人操作，请立即联系管理员。", user.PendingEmail)); err != nil {
+		log.Printf("Failed to notify old email: %v", err)
+	}
+
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	s.userService.RefreshContactHashes(ctx, user)
+	return user, nil
+}
+
+// ScheduleDeletion 确认身份后安排注销账号，冷静期结束后才会真正清理数据，期间重新登录即可撤销
+// 身份确认方式与 RequestEmailChange 相同
+func (s *AccountService) ScheduleDeletion(ctx context.Context, userID, password, reauthCode string) (time.Time, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.verifyIdentity(ctx, user, password, reauthCode); err != nil {
+		return time.Time{}, err
+	}
+
+	// 安排注销的同时使所有 token 失效
+	deleteAt := time.Now().Add(s.deletionGrace)
+	if err := s.userRepo.ScheduleDeletion(ctx, user.ID, deleteAt); err != nil {
+		return time.Time{}, err
+	}
+	s.revokeSessions(user.ID, "account_deletion_scheduled")
+
+	return deleteAt, nil
+}
+
+// RunDeletionWorker 定期清理冷静期已结束的账号，应在独立的 goroutine 中运行
+func (s *AccountService) RunDeletionWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.PurgeDueAccounts(context.Background())
+	}
+}
+
+// PurgeDueAccounts 清理所有冷静期已结束的账号
+func (s *AccountService) PurgeDueAccounts(ctx context.Context) {
+	users, err := s.userRepo.FindDueForDeletion(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to find accounts due for deletion: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		if err := s.purgeAccount(ctx, user); err != nil {
+			// 失败的账号保留计划注销时间，下一轮会重试
+			log.Printf("Failed to purge account %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Account %s purged", user.ID.Hex())
+	}
+}
+
+// purgeAccount 匿名化消息、删除好友关系和群成员身份、删除文件，最后抹去用户信息
+func (s *AccountService) purgeAccount(ctx context.Context, user *model.User) error {
+	if err := s.messageRepo.AnonymizeUser(ctx, user.ID, user.Username, deletedUserName); err != nil {
+		return fmt.Errorf("anonymize messages: %v", err)
+	}
+
+	if err := s.friendshipRepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete friendships: %v", err)
+	}
+
+	if err := s.friendRequestRepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete friend requests: %v", err)
+	}
+
+	if err := s.blockRepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete blocks: %v", err)
+	}
+
+	if err := s.friendTagRepo.DeleteAllByOwner(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete friend tags: %v", err)
+	}
+
+	if err := s.friendMetaRepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete friend remarks: %v", err)
+	}
+
+	if err := s.groupRepo.RemoveUserFromAllGroups(ctx, user.ID); err != nil {
+		return fmt.Errorf("leave groups: %v", err)
+	}
+
+	// 不依赖本次移出的群组列表，清理失败重试时也能移出所有私有频道
+	if err := s.groupChannelRepo.RemoveUserFromAll(ctx, user.ID); err != nil {
+		return fmt.Errorf("leave channels: %v", err)
+	}
+
+	if err := s.groupJoinRepo.DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete group join requests: %v", err)
+	}
+
+	if err := s.groupInviteRepo.DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete group invites: %v", err)
+	}
+
+	if err := s.fileService.DeleteUserFiles(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete files: %v", err)
+	}
+
+	if err := s.notificationRepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete notifications: %v", err)
+	}
+
+	// 用户名加上 ID 后缀保证唯一
+	return s.userRepo.Anonymize(ctx, user.ID, "deleted_"+user.ID.Hex())
+}
+
+// revokeSessions 发布会话撤销事件，由 WebSocket Hub 断开该用户已建立的连接
+func (s *AccountService) revokeSessions(userID primitive.ObjectID, reason string) {
+	s.eventBus.Publish(event.Event{
+		Type: event.SessionRevoked,
+		Content: event.SessionRevokedContent{
+			UserID: userID.Hex(),
+			Reason: reason,
+		},
+	})
+}
+
+// findUser 根据字符串 ID 查找用户
+func (s *AccountService) findUser(ctx context.Context, userID string) (*model.User, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %v", err)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, objID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// generateEmailCode 生成 6 位数字验证码
+func generateEmailCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashEmailCode 计算验证码的哈希，数据库中只保存哈希
+func hashEmailCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"chatweb/pkg/event"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxAdminPageSize = 100 // 管理后台用户列表每页最多返回的数量
+
+// SystemStats 管理后台展示的系统统计数据
+type SystemStats struct {
+	TotalUsers    int64 `json:"total_users"`     // 用户总数（不含已注销）
+	DisabledUsers int64 `json:"disabled_users"`  // 被禁用的用户数
+	NewUsersToday int64 `json:"new_users_today"` // 今日新注册用户数
+	OnlineUsers   int   `json:"online_users"`    // 当前在线用户数
+	TotalMessages int64 `json:"total_messages"`  // 消息总数
+	MessagesToday int64 `json:"messages_today"`  // 今日消息数
+	TotalGroups   int64 `json:"total_groups"`    // 群组总数
+}
+
+// AdminService 提供管理后台的用户管理、统计和广播功能
+type AdminService struct {
+	userRepo            *repository.UserRepository    // 用户存储库
+	messageRepo         *repository.MessageRepository // 消息存储库，用于统计
+	groupRepo           *repository.GroupRepository   // 群组存储库，用于统计
+	onlineService       *OnlineService                // 在线状态服务，用于统计在线人数
+	notificationService *NotificationService          // 通知服务，用于发送广播
+	auditService        *AuditService                 // 审计服务，记录管理操作
+	eventBus            *event.EventBus               // 事件总线，用于断开被下线用户的连接
+}
+
+// NewAdminService 创建一个新的 AdminService 实例
+func NewAdminService(
+	userRepo *repository.UserRepository,
+	messageRepo *repository.MessageRepository,
+	groupRepo *repository.GroupRepository,
+	onlineService *OnlineService,
+	notificationService *NotificationService,
+	auditService *AuditService,
+	eventBus *event.EventBus,
+) *AdminService {
+	return &AdminService{
+		userRepo:            userRepo,
+		messageRepo:         messageRepo,
+		groupRepo:           groupRepo,
+		onlineService:       onlineService,
+		notificationService: notificationService,
+		auditService:        auditService,
+		eventBus:            eventBus,
+	}
+}
+
+// ListUsers 分页查询用户列表，page 从 1 开始
+func (s *AdminService) ListUsers(ctx context.Context, filter repository.UserListFilter, page, pageSize int) ([]*model.User, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxAdminPageSize {
+		pageSize = maxAdminPageSize
+	}
+	if filter.Role != "" && !model.ValidRole(filter.Role) {
+		return nil, 0, errors.New("invalid role")
+	}
+
+	return s.userRepo.ListUsers(ctx, filter, int64((page-1)*pageSize), int64(pageSize))
+}
+
+// DisableUser 禁用账号，被禁用的用户立即下线且无法再登录
+func (s *AdminService) DisableUser(ctx context.Context, actorID string, actorRole model.UserRole, userID, reason, ip string) error {
+	actor, target, err := s.loadTarget(ctx, actorID, actorRole, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Disable(ctx, target.ID, reason); err != nil {
+		return err
+	}
+
+	s.revokeSessions(target.ID, "disabled")
+	s.auditService.Record(ctx, model.AuditUserDisabled, target.ID, actor, ip, reason)
+	return nil
+}
+
+// EnableUser 解除账号禁用
+func (s *AdminService) EnableUser(ctx context.Context, actorID string, actorRole model.UserRole, userID, ip string) error {
+	actor, target, err := s.loadTarget(ctx, actorID, actorRole, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Enable(ctx, target.ID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, model.AuditUserEnabled, target.ID, actor, ip, "account enabled by admin")
+	return nil
+}
+
+// ForceLogout 使用户所有已签发的 token 失效并断开其 WebSocket 连接
+func (s *AdminService) ForceLogout(ctx context.Context, actorID string, actorRole model.UserRole, userID, ip string) error {
+	actor, target, err := s.loadTarget(ctx, actorID, actorRole, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.IncrementTokenVersion(ctx, target.ID); err != nil {
+		return err
+	}
+
+	s.revokeSessions(target.ID, "force_logout")
+	s.auditService.Record(ctx, model.AuditForceLogout, target.ID, actor, ip, "all sessions revoked by admin")
+	return nil
+}
+
+// ChangeRole 修改用户角色，角色变更后该用户需要重新登录
+func (s *AdminService) ChangeRole(ctx context.Context, actorID string, actorRole model.UserRole, userID string, role model.UserRole, ip string) error {
+	if !model.ValidRole(role) {
+		return errors.New("invalid role")
+	}
+	// 管理员可以授予任意角色，其他角色只能授予比自己低的角色
+	if actorRole != model.RoleAdmin && !actorRole.Outranks(role) {
+		return errors.New("cannot grant a role equal to or higher than your own")
+	}
+
+	actor, target, err := s.loadTarget(ctx, actorID, actorRole, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateRole(ctx, target.ID, role); err != nil {
+		return err
+	}
+
+	s.revokeSessions(target.ID, "role_changed")
+	s.auditService.Record(ctx, model.AuditRoleChanged, target.ID, actor, ip,
+		fmt.Sprintf("role changed from %s to %s", target.GetRole(), role))
+	return nil
+}
+
+// GetStats 统计用户、消息、群组和在线人数
+func (s *AdminService) GetStats(ctx context.Context) (*SystemStats, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	notDeleted := bson.M{"deleted_at": bson.M{"$exists": false}}
+
+	var stats SystemStats
+	var err error
+
+	if stats.TotalUsers, err = s.userRepo.Count(ctx, notDeleted); err != nil {
+		return nil, err
+	}
+	if stats.DisabledUsers, err = s.userRepo.Count(ctx, bson.M{"disabled": true, "deleted_at": bson.M{"$exists": false}}); err != nil {
+		return nil, err
+	}
+	if stats.NewUsersToday, err = s.userRepo.Count(ctx, bson.M{"created_at": bson.M{"$gte": today}}); err != nil {
+		return nil, err
+	}
+	if stats.TotalMessages, err = s.messageRepo.Count(ctx, bson.M{}); err != nil {
+		return nil, err
+	}
+	if stats.MessagesToday, err = s.messageRepo.Count(ctx, bson.M{"created_at": bson.M{"$gte": today}}); err != nil {
+		return nil, err
+	}
+	if stats.TotalGroups, err = s.groupRepo.Count(ctx, bson.M{}); err != nil {
+		return nil, err
+	}
+
+	onlineUsers, err := s.onlineService.GetOnlineUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.OnlineUsers = len(onlineUsers)
+
+	return &stats, nil
+}
+
+// Broadcast 向指定用户发送系统通知，userIDs 为空时发送给所有正常账号，返回发送的数量
+func (s *AdminService) Broadcast(ctx context.Context, actorID string, userIDs []string, title, content, ip string) (int, error) {
+	actor, err := primitive.ObjectIDFromHex(actorID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid admin ID: %v", err)
+	}
+
+	var recipients []primitive.ObjectID
+	if len(userIDs) == 0 {
+		if recipients, err = s.userRepo.FindActiveIDs(ctx); err != nil {
+			return 0, err
+		}
+	} else {
+		for _, id := range userIDs {
+			objID, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return 0, fmt.Errorf("invalid user ID %q", id)
+			}
+			recipients = append(recipients, objID)
+		}
+	}
+
+	sent, err := s.notificationService.BroadcastSystemNotification(ctx, recipients, title, content)
+	if err != nil {
+		return 0, err
+	}
+
+	s.auditService.Record(ctx, model.AuditBroadcast, primitive.NilObjectID, actor, ip,
+		fmt.Sprintf("broadcast %q to %d user(s)", title, sent))
+	return sent, nil
+}
+
+// loadTarget 解析操作者和被操作用户，操作者只能管理权限低于自己的用户，且不能操作自己
+func (s *AdminService) loadTarget(ctx context.Context, actorID string, actorRole model.UserRole, userID string) (primitive.ObjectID, *model.User, error) {
+	actor, err := primitive.ObjectIDFromHex(actorID)
+	if err != nil {
+		return primitive.NilObjectID, nil, fmt.Errorf("invalid admin ID: %v", err)
+	}
+
+	targetID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, nil, fmt.Errorf("invalid user ID: %v", err)
+	}
+
+	if actor == targetID {
+		return primitive.NilObjectID, nil, errors.New("cannot perform this action on yourself")
+	}
+
+	target, err := s.userRepo.FindByID(ctx, targetID)
+	if err != nil || target.DeletedAt != nil {
+		return primitive.NilObjectID, nil, errors.New("user not found")
+	}
+
+	if !actorRole.Outranks(target.GetRole()) {
+		return primitive.NilObjectID, nil, errors.New("insufficient permission to manage this user")
+	}
+
+	return actor, target, nil
+}
+
+// revokeSessions 发布会话撤销事件，由 WebSocket Hub 断开该用户的连接
+func (s *AdminService) revokeSessions(userID primitive.ObjectID, reason string) {
+	s.eventBus.Publish(event.Event{
+		Type: event.SessionRevoked,
+		Content: event.SessionRevokedContent{
+			UserID: userID.Hex(),
+			Reason: reason,
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditService 负责记录安全相关的审计日志
+type AuditService struct {
+	auditRepo *repository.AuditRepository // 审计日志存储库
+}
+
+// NewAuditService 创建一个新的 AuditService 实例
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record 写入一条审计日志，写入失败只记录日志，不影响主流程
+func (s *AuditService) Record(ctx context.Context, action model.AuditAction, userID, actorID primitive.ObjectID, ip, detail string) {
+	entry := &model.AuditLog{
+		Action:  action,
+		UserID:  userID,
+		ActorID: actorID,
+		IP:      ip,
+		Detail:  detail,
+	}
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		log.Printf("Failed to write audit log %s: %v", action, err)
+	}
+}
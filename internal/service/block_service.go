@@ -0,0 +1,151 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrActionNotAllowed 双方存在拉黑关系时返回的通用错误，不向对方透露被拉黑的事实
+var ErrActionNotAllowed = errors.New("unable to complete this action")
+
+// BlockedUser 黑名单中的一项
+type BlockedUser struct {
+	User      *model.User `json:"user"`       // 被拉黑用户的信息
+	BlockedAt time.Time   `json:"blocked_at"` // 拉黑时间
+}
+
+// BlockService 管理用户黑名单，并为其他服务提供拉黑关系的判断
+type BlockService struct {
+	blockRepo *repository.BlockRepository // 黑名单存储库
+	userRepo  *repository.UserRepository  // 用户存储库
+}
+
+// NewBlockService 创建一个新的 BlockService 实例
+func NewBlockService(blockRepo *repository.BlockRepository, userRepo *repository.UserRepository) *BlockService {
+	return &BlockService{
+		blockRepo: blockRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// Block 将 targetID 加入 userID 的黑名单，重复拉黑视为成功
+func (s *BlockService) Block(ctx context.Context, userID, targetID string) error {
+	userObjID, targetObjID, err := parseUserPair(userID, targetID)
+	if err != nil {
+		return err
+	}
+	if userObjID == targetObjID {
+		return errors.New("cannot block yourself")
+	}
+
+	target, err := s.userRepo.FindByID(ctx, targetObjID)
+	if err != nil || target.DeletedAt != nil {
+		return errors.New("user not found")
+	}
+
+	return s.blockRepo.Create(ctx, userObjID, targetObjID)
+}
+
+// Unblock 将 targetID 移出 userID 的黑名单
+func (s *BlockService) Unblock(ctx context.Context, userID, targetID string) error {
+	userObjID, targetObjID, err := parseUserPair(userID, targetID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.blockRepo.Delete(ctx, userObjID, targetObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("user is not blocked")
+		}
+		return err
+	}
+	return nil
+}
+
+// ListBlocked 获取 userID 的黑名单及被拉黑用户的信息
+func (s *BlockService) ListBlocked(ctx context.Context, userID string) ([]*BlockedUser, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	blocks, err := s.blockRepo.ListByBlocker(ctx, userObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(blocks))
+	for _, block := range blocks {
+		ids = append(ids, block.BlockedID)
+	}
+	users := make(map[primitive.ObjectID]*model.User)
+	if len(ids) > 0 {
+		found, err := s.userRepo.FindByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	result := make([]*BlockedUser, 0, len(blocks))
+	for _, block := range blocks {
+		user, ok := users[block.BlockedID]
+		if !ok {
+			continue // 用户已被清理
+		}
+		result = append(result, &BlockedUser{User: user, BlockedAt: block.CreatedAt})
+	}
+	return result, nil
+}
+
+// IsBlocked 判断两个用户之间是否存在任一方向的拉黑
+// 查询失败时按已拉黑处理，宁可拒绝一次操作也不能绕过黑名单
+func (s *BlockService) IsBlocked(ctx context.Context, a, b primitive.ObjectID) bool {
+	blocked, err := s.blockRepo.ExistsEither(ctx, a, b)
+	if err != nil {
+		log.Printf("Failed to check block between %s and %s: %v", a.Hex(), b.Hex(), err)
+		return true
+	}
+	return blocked
+}
+
+// BlockedSet 返回与 userID 存在任一方向拉黑关系的用户 ID 集合（十六进制字符串）
+func (s *BlockService) BlockedSet(ctx context.Context, userID string) (map[string]bool, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	ids, err := s.blockRepo.FindRelatedIDs(ctx, userObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id.Hex()] = true
+	}
+	return set, nil
+}
+
+// parseUserPair 将两个字符串用户 ID 转换为 ObjectID
+func parseUserPair(userID, targetID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid user ID")
+	}
+	targetObjID, err := primitive.ObjectIDFromHex(targetID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid target user ID")
+	}
+	return userObjID, targetObjID, nil
+}
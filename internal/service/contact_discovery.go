@@ -0,0 +1,211 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// contactBackfillBatch 启动时重新计算通讯录哈希的批大小
+const contactBackfillBatch = 500
+
+// ContactHasher 计算通讯录匹配用的加盐哈希：hex(sha256(salt + 规范化后的手机号或邮箱))
+// 盐会下发给客户端，客户端必须按相同规则规范化后计算哈希
+type ContactHasher struct {
+	salt string // 服务端的盐，不同部署使用不同的盐，避免哈希在服务之间被关联
+	key  string // 盐的指纹，记录在用户文档中，盐更换后据此识别需要重新计算的哈希
+}
+
+// NewContactHasher 创建一个新的 ContactHasher 实例
+func NewContactHasher(salt string) *ContactHasher {
+	sum := sha256.Sum256([]byte("contact-hash-key:" + salt))
+	return &ContactHasher{
+		salt: salt,
+		key:  hex.EncodeToString(sum[:8]),
+	}
+}
+
+// Salt 返回下发给客户端的盐
+func (h *ContactHasher) Salt() string {
+	return h.salt
+}
+
+// Key 返回盐的指纹
+func (h *ContactHasher) Key() string {
+	return h.key
+}
+
+// HashPhone 计算手机号的哈希，手机号为空时返回空字符串
+func (h *ContactHasher) HashPhone(phone string) string {
+	return h.hash(NormalizePhone(phone))
+}
+
+// HashEmail 计算邮箱的哈希，邮箱为空时返回空字符串
+func (h *ContactHasher) HashEmail(email string) string {
+	return h.hash(NormalizeEmail(email))
+}
+
+func (h *ContactHasher) hash(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(h.salt + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizePhone 规范化手机号：去掉空格、中划线和括号，保留开头的 + 号和数字
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// NormalizeEmail 规范化邮箱：去掉首尾空白并转为小写
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// DiscoveredContact 通讯录匹配结果，只包含公开的资料，不返回对方的手机号和邮箱
+type DiscoveredContact struct {
+	Hash     string             `json:"hash"`    // 客户端上传的哈希，便于客户端对应回通讯录条目
+	UserID   primitive.ObjectID `json:"user_id"` // 匹配到的用户 ID
+	Username string             `json:"username"`
+	Nickname string             `json:"nickname"`
+	Avatar   string             `json:"avatar"`
+}
+
+// DiscoveryService 根据客户端上传的通讯录哈希查找已注册的用户
+type DiscoveryService struct {
+	userRepo     *repository.UserRepository // 用户存储库
+	blockService *BlockService              // 黑名单服务，拉黑双方互相不可见
+	privacy      *PrivacyService            // 隐私服务，按对方的资料可见范围隐藏头像
+	hasher       *ContactHasher             // 通讯录哈希计算
+	maxHashes    int                        // 单次请求最多上传的哈希数
+}
+
+// NewDiscoveryService 创建一个新的 DiscoveryService 实例
+func NewDiscoveryService(userRepo *repository.UserRepository, blockService *BlockService, privacy *PrivacyService, hasher *ContactHasher, maxHashes int) *DiscoveryService {
+	return &DiscoveryService{
+		userRepo:     userRepo,
+		blockService: blockService,
+		privacy:      privacy,
+		hasher:       hasher,
+		maxHashes:    maxHashes,
+	}
+}
+
+// Salt 返回客户端计算哈希所需的盐
+func (s *DiscoveryService) Salt() string {
+	return s.hasher.Salt()
+}
+
+// Discover 返回通讯录中已注册的用户
+// 对方关闭了对应方式的“可被发现”、与当前用户存在拉黑关系或就是当前用户本人时不返回，头像按对方的资料可见范围隐藏
+func (s *DiscoveryService) Discover(ctx context.Context, userID string, hashes []string) ([]*DiscoveredContact, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if len(hashes) > s.maxHashes {
+		return nil, errors.New("too many contacts in one request")
+	}
+
+	// 去重并统一为小写十六进制，格式不对的直接忽略
+	wanted := make(map[string]bool, len(hashes))
+	unique := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if len(hash) != sha256.Size*2 || wanted[hash] {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			continue
+		}
+		wanted[hash] = true
+		unique = append(unique, hash)
+	}
+
+	result := make([]*DiscoveredContact, 0)
+	if len(unique) == 0 {
+		return result, nil
+	}
+
+	users, err := s.userRepo.FindByContactHashes(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := s.blockService.BlockedSet(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]*model.User, 0, len(users))
+	for _, user := range users {
+		if user.ID == userObjID || blocked[user.ID.Hex()] || user.ContactHashKey != s.hasher.Key() {
+			continue
+		}
+		visible = append(visible, user)
+	}
+	if _, err := s.privacy.Redact(ctx, userObjID, visible); err != nil {
+		return nil, err
+	}
+
+	for _, user := range visible {
+		if user.PhoneHash != "" && wanted[user.PhoneHash] && user.Privacy.IsDiscoverableByPhone() {
+			result = append(result, newDiscoveredContact(user.PhoneHash, user))
+		}
+		if user.EmailHash != "" && wanted[user.EmailHash] && user.Privacy.IsDiscoverableByEmail() {
+			result = append(result, newDiscoveredContact(user.EmailHash, user))
+		}
+	}
+	return result, nil
+}
+
+func newDiscoveredContact(hash string, user *model.User) *DiscoveredContact {
+	return &DiscoveredContact{
+		Hash:     hash,
+		UserID:   user.ID,
+		Username: user.Username,
+		Nickname: user.Nickname,
+		Avatar:   user.Avatar,
+	}
+}
+
+// BackfillHashes 为哈希缺失或由旧盐计算的用户重新计算哈希，启动时在后台运行
+func (s *DiscoveryService) BackfillHashes(ctx context.Context) {
+	updated := 0
+	for {
+		users, err := s.userRepo.FindStaleContactHashes(ctx, s.hasher.Key(), contactBackfillBatch)
+		if err != nil {
+			log.Printf("Failed to load users for contact hash backfill: %v", err)
+			return
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			if err := s.userRepo.SetContactHashes(ctx, user.ID, s.hasher.HashPhone(user.Phone), s.hasher.HashEmail(user.Email), s.hasher.Key()); err != nil {
+				log.Printf("Failed to backfill contact hashes for user %s: %v", user.ID.Hex(), err)
+				return
+			}
+			updated++
+		}
+	}
+
+	if updated > 0 {
+		log.Printf("Backfilled contact hashes for %d users", updated)
+	}
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxUploadFileNameLength = 255              // 上传文件名的最大字符数
+	uploadConfirmGrace      = 10 * time.Minute // 上传 URL 过期后仍允许确认的时间，覆盖过期前开始的慢速上传
+	uploadCleanupBatchSize  = 100              // 每轮清理的过期上传数
+)
+
+var (
+	ErrFileTooLarge       = errors.New("file too large")
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+	ErrUploadNotFound     = errors.New("upload not found")
+	ErrUploadExpired      = errors.New("upload expired")
+	ErrUploadIncomplete   = errors.New("file has not been uploaded yet")
+	ErrUploadMismatch     = errors.New("uploaded file does not match the declared size or content type")
+	ErrFileAccessDenied   = errors.New("no access to this file")
+	ErrStorageUnsupported = errors.New("not supported by the configured storage backend")
+)
+
+// uploadContentTypes 允许上传的扩展名及其对应的 MIME 类型，第一个为默认类型
+var uploadContentTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+	".mp4":  {"video/mp4"},
+	".avi":  {"video/x-msvideo", "video/avi"},
+	".mov":  {"video/quicktime"},
+	".mp3":  {"audio/mpeg"},
+	".wav":  {"audio/wav", "audio/x-wav", "audio/wave"},
+	".ogg":  {"audio/ogg"},
+	".doc":  {"application/msword"},
+	".docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	".pdf":  {"application/pdf"},
+}
+
+// PresignedUpload 预签名上传的结果，客户端用 Method 和 Headers 向 URL 上传文件，完成后用 UploadID 确认
+type PresignedUpload struct {
+	UploadID  string            `json:"upload_id"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// PresignUpload 校验文件名、类型和大小后签发预签名 PUT URL，并记录一次待确认的上传
+// contentType 为空时按扩展名推断
+func (s *FileService) PresignUpload(ctx context.Context, userID, name, contentType string, size int64) (*PresignedUpload, error) {
+	presigner, ok := s.backend.(storage.Presigner)
+	if !ok {
+		return nil, ErrStorageUnsupported
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	name = strings.TrimSpace(filepath.Base(name))
+	if name == "" || name == "." || name == "/" || utf8.RuneCountInString(name) > maxUploadFileNameLength {
+		return nil, errors.New("invalid file name")
+	}
+	if size <= 0 {
+		return nil, errors.New("file size must be positive")
+	}
+	if size > s.maxUploadSize {
+		return nil, ErrFileTooLarge
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	contentType, err = resolveUploadContentType(ext, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &model.FileUpload{
+		ID:          primitive.NewObjectID(),
+		UserID:      userObjID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		Type:        s.determineFileType(ext),
+		ExpiresAt:   time.Now().Add(s.uploadExpire),
+	}
+	// 对象名不使用原始文件名，避免重名覆盖和路径注入
+	upload.ObjectName = fmt.Sprintf("%s/%s%s", userObjID.Hex(), upload.ID.Hex(), ext)
+
+	url, headers, err := presigner.PresignPut(ctx, upload.ObjectName, contentType, size, s.uploadExpire)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.uploadRepo.Create(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	result := &PresignedUpload{
+		UploadID:  upload.ID.Hex(),
+		URL:       url,
+		Method:    http.MethodPut,
+		Headers:   make(map[string]string, len(headers)),
+		ExpiresAt: upload.ExpiresAt,
+	}
+	for key := range headers {
+		result.Headers[key] = headers.Get(key)
+	}
+	return result, nil
+}
+
+// resolveUploadContentType 校验扩展名和 MIME 类型是否匹配，contentType 为空时返回扩展名的默认类型
+func resolveUploadContentType(ext, contentType string) (string, error) {
+	allowed, ok := uploadContentTypes[ext]
+	if !ok {
+		return "", ErrFileTypeNotAllowed
+	}
+	if contentType == "" {
+		return allowed[0], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrFileTypeNotAllowed
+	}
+	for _, t := range allowed {
+		if mediaType == t {
+			return mediaType, nil
+		}
+	}
+	return "", ErrFileTypeNotAllowed
+}
+
+// ConfirmUpload 确认客户端已完成直传：校验对象存在且大小和类型与签发时一致，然后创建文件记录
+// 对象与声明不符时删除对象并作废本次上传
+func (s *FileService) ConfirmUpload(ctx context.Context, uploadID, userID string) (*model.File, error) {
+	uploadObjID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	upload, err := s.uploadRepo.FindByID(ctx, uploadObjID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if upload.UserID != userObjID {
+		return nil, ErrUploadNotFound
+	}
+	if time.Now().After(upload.ExpiresAt.Add(uploadConfirmGrace)) {
+		return nil, ErrUploadExpired
+	}
+
+	stat, err := s.backend.Stat(ctx, upload.ObjectName)
+	if err != nil {
+		if err == storage.ErrObjectNotFound {
+			return nil, ErrUploadIncomplete
+		}
+		return nil, err
+	}
+	if stat.Size != upload.Size || !sameMediaType(stat.ContentType, upload.ContentType) {
+		s.discardUpload(ctx, upload)
+		return nil, ErrUploadMismatch
+	}
+
+	// 先删除上传记录，并发的重复确认只有一个能成功
+	if err := s.uploadRepo.Delete(ctx, upload.ID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	file := &model.File{
+		Name:   upload.Name,
+		Type:   upload.Type,
+		Size:   stat.Size,
+		URL:    s.backend.URL(upload.ObjectName),
+		UserID: userObjID,
+	}
+	if err := s.fileRepo.Create(ctx, file); err != nil {
+		_ = s.backend.Delete(ctx, upload.ObjectName)
+		return nil, fmt.Errorf("failed to create file record: %v", err)
+	}
+	return file, nil
+}
+
+// sameMediaType 比较两个 MIME 类型，忽略参数和大小写
+func sameMediaType(a, b string) bool {
+	mediaA, _, errA := mime.ParseMediaType(a)
+	mediaB, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && mediaA == mediaB
+}
+
+// discardUpload 删除上传的对象和上传记录，失败只记录日志，残留的记录由清理任务处理
+func (s *FileService) discardUpload(ctx context.Context, upload *model.FileUpload) {
+	if err := s.backend.Delete(ctx, upload.ObjectName); err != nil {
+		log.Printf("Failed to delete object of upload %s: %v", upload.ID.Hex(), err)
+		return
+	}
+	if err := s.uploadRepo.Delete(ctx, upload.ID); err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("Failed to delete upload %s: %v", upload.ID.Hex(), err)
+	}
+}
+
+// GetDownloadURL 校验当前用户能否访问文件，然后签发短时有效的预签名下载 URL，返回 URL 和过期时间
+// 存储后端不支持预签名时返回文件的访问地址，过期时间为 nil
+func (s *FileService) GetDownloadURL(ctx context.Context, fileID, userID string) (string, *time.Time, error) {
+	fileObjID, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return "", nil, errors.New("invalid file ID")
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", nil, errors.New("invalid user ID")
+	}
+
+	file, err := s.fileRepo.GetByID(ctx, fileObjID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			// 不存在和无权访问返回同样的错误，避免探测文件 ID
+			return "", nil, ErrFileAccessDenied
+		}
+		return "", nil, err
+	}
+	ok, err := s.canAccessFile(ctx, file, userObjID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok {
+		return "", nil, ErrFileAccessDenied
+	}
+
+	presigner, ok := s.backend.(storage.Presigner)
+	if !ok {
+		return file.URL, nil, nil
+	}
+	expiresAt := time.Now().Add(s.downloadExpire)
+	url, err := presigner.PresignGet(ctx, s.backend.ObjectName(file.URL), file.Name, s.downloadExpire)
+	if err != nil {
+		return "", nil, err
+	}
+	return url, &expiresAt, nil
+}
+
+// canAccessFile 判断用户能否访问文件：上传者本人、上传者本人或群组的头像，或者文件被用户参与的私聊、
+// 用户所在群组中可以读写的频道里的消息引用
+func (s *FileService) canAccessFile(ctx context.Context, file *model.File, userID primitive.ObjectID) (bool, error) {
+	if file.UserID == userID {
+		return true, nil
+	}
+	// 用户头像和群头像在资料中展示，所有登录用户都可以查看
+	isAvatar, err := s.isAvatar(ctx, file)
+	if err != nil {
+		return false, err
+	}
+	if isAvatar {
+		return true, nil
+	}
+
+	messages, err := s.messageRepo.FindByFileID(ctx, file.ID)
+	if err != nil {
+		return false, err
+	}
+	checked := make(map[primitive.ObjectID]bool)
+	for _, message := range messages {
+		if message.GroupID.IsZero() {
+			if message.SenderID == userID || message.ReceiverID == userID {
+				return true, nil
+			}
+			continue
+		}
+
+		key := message.ChannelID
+		if key.IsZero() {
+			key = message.GroupID
+		}
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		ok, err := s.canReadGroupMessage(ctx, message, userID)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isAvatar 判断文件是否为群头像或上传者本人当前的头像，用户头像只能通过上传接口设置
+func (s *FileService) isAvatar(ctx context.Context, file *model.File) (bool, error) {
+	isAvatar, err := s.groupRepo.ExistsByAvatarFileID(ctx, file.ID)
+	if err != nil || isAvatar {
+		return isAvatar, err
+	}
+
+	owner, err := s.userRepo.FindByID(ctx, file.UserID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, err
+	}
+	return owner.Avatar == file.URL, nil
+}
+
+// canReadGroupMessage 判断用户是否为群成员且可以读写消息所在的频道
+func (s *FileService) canReadGroupMessage(ctx context.Context, message *model.Message, userID primitive.ObjectID) (bool, error) {
+	if _, err := s.groupRepo.GetMember(ctx, message.GroupID, userID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, err
+	}
+	if message.ChannelID.IsZero() {
+		return true, nil
+	}
+
+	channel, err := s.channelRepo.FindByID(ctx, message.GroupID, message.ChannelID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, err
+	}
+	return channel.ReadableBy(userID), nil
+}
+
+// RunUploadCleanupWorker 定期清理过期的上传，应在独立的 goroutine 中运行
+func (s *FileService) RunUploadCleanupWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.CleanupExpiredUploads(context.Background())
+	}
+}
+
+// CleanupExpiredUploads 清理过期未确认的预签名上传和长时间没有进展的分片上传
+func (s *FileService) CleanupExpiredUploads(ctx context.Context) {
+	s.cleanupExpiredPresignedUploads(ctx)
+	s.cleanupExpiredResumableUploads(ctx)
+}
+
+// cleanupExpiredPresignedUploads 删除过期未确认的上传记录及已经上传的对象
+func (s *FileService) cleanupExpiredPresignedUploads(ctx context.Context) {
+	for {
+		uploads, err := s.uploadRepo.FindExpired(ctx, time.Now().Add(-uploadConfirmGrace), uploadCleanupBatchSize)
+		if err != nil {
+			log.Printf("Failed to find expired uploads: %v", err)
+			return
+		}
+
+		removed := 0
+		for _, upload := range uploads {
+			// 对象不存在时存储后端不会返回错误
+			if err := s.backend.Delete(ctx, upload.ObjectName); err != nil {
+				log.Printf("Failed to delete object of expired upload %s: %v", upload.ID.Hex(), err)
+				continue
+			}
+			if err := s.uploadRepo.Delete(ctx, upload.ID); err != nil && err != mongo.ErrNoDocuments {
+				log.Printf("Failed to delete expired upload %s: %v", upload.ID.Hex(), err)
+				continue
+			}
+			removed++
+		}
+		// 本轮有失败时留到下一次执行，避免反复处理同一批记录
+		if len(uploads) < uploadCleanupBatchSize || removed < len(uploads) {
+			return
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/pkg/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxUploadParts = 10000 // MinIO 分片上传最多允许的分片数
+
+var (
+	ErrInvalidPart      = errors.New("invalid part number")
+	ErrPartSizeMismatch = errors.New("part size does not match the expected size")
+	ErrInvalidChecksum  = errors.New("checksum must be a hex encoded SHA-256")
+	ErrChecksumMismatch = errors.New("part content does not match the checksum")
+)
+
+// UploadProgress 分片上传的进度
+type UploadProgress struct {
+	*model.ResumableUpload
+	UploadedBytes int64                `json:"uploaded_bytes"` // 已上传的字节数
+	UploadedParts []model.UploadedPart `json:"uploaded_parts"` // 已上传的分片，按序号排列
+	MissingParts  []int                `json:"missing_parts"`  // 尚未上传的分片序号
+}
+
+// newUploadProgress 根据上传记录计算进度
+func newUploadProgress(upload *model.ResumableUpload) *UploadProgress {
+	progress := &UploadProgress{
+		ResumableUpload: upload,
+		UploadedParts:   make([]model.UploadedPart, 0, len(upload.Parts)),
+		MissingParts:    []int{},
+	}
+	for number := 1; number <= upload.PartCount; number++ {
+		part, ok := upload.Parts[strconv.Itoa(number)]
+		if !ok {
+			progress.MissingParts = append(progress.MissingParts, number)
+			continue
+		}
+		progress.UploadedParts = append(progress.UploadedParts, part)
+		progress.UploadedBytes += part.Size
+	}
+	return progress
+}
+
+// expectedPartSize 返回第 number 个分片应有的大小，最后一片为剩余的字节数
+func expectedPartSize(upload *model.ResumableUpload, number int) int64 {
+	if number < upload.PartCount {
+		return upload.PartSize
+	}
+	return upload.Size - int64(upload.PartCount-1)*upload.PartSize
+}
+
+// InitResumableUpload 校验文件名、类型和大小后创建分片上传，返回分片大小和分片数
+func (s *FileService) InitResumableUpload(ctx context.Context, userID, name, contentType string, size int64) (*UploadProgress, error) {
+	uploader, ok := s.backend.(storage.MultipartUploader)
+	if !ok {
+		return nil, ErrStorageUnsupported
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	name = strings.TrimSpace(filepath.Base(name))
+	if name == "" || name == "." || name == "/" || utf8.RuneCountInString(name) > maxUploadFileNameLength {
+		return nil, errors.New("invalid file name")
+	}
+	if size <= 0 {
+		return nil, errors.New("file size must be positive")
+	}
+	if size > s.maxResumableSize {
+		return nil, ErrFileTooLarge
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	contentType, err = resolveUploadContentType(ext, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	partCount := int((size + s.partSize - 1) / s.partSize)
+	if partCount > maxUploadParts {
+		return nil, ErrFileTooLarge
+	}
+
+	upload := &model.ResumableUpload{
+		ID:          primitive.NewObjectID(),
+		UserID:      userObjID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		Type:        s.determineFileType(ext),
+		PartSize:    s.partSize,
+		PartCount:   partCount,
+		ExpiresAt:   time.Now().Add(s.resumableExpire),
+	}
+	upload.ObjectName = fmt.Sprintf("%s/%s%s", userObjID.Hex(), upload.ID.Hex(), ext)
+
+	upload.StorageID, err = uploader.NewMultipartUpload(ctx, upload.ObjectName, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resumableRepo.Create(ctx, upload); err != nil {
+		_ = uploader.AbortMultipartUpload(ctx, upload.ObjectName, upload.StorageID)
+		return nil, err
+	}
+	return newUploadProgress(upload), nil
+}
+
+// UploadPart 上传一个分片，checksum 为分片内容的 SHA-256（十六进制），内容不符时拒绝该分片
+// 重传同一分片会覆盖之前的内容，每上传一个分片都会顺延上传的过期时间
+func (s *FileService) UploadPart(ctx context.Context, uploadID, userID string, number int, data io.Reader, size int64, checksum string) (*UploadProgress, error) {
+	uploader, upload, err := s.findResumableUpload(ctx, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if number < 1 || number > upload.PartCount {
+		return nil, ErrInvalidPart
+	}
+	if size != expectedPartSize(upload, number) {
+		return nil, ErrPartSizeMismatch
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return nil, ErrInvalidChecksum
+	}
+
+	// 校验和交给存储后端校验分片内容；本地同时计算一遍，不依赖存储端的行为
+	hasher := sha256.New()
+	etag, err := uploader.PutPart(ctx, upload.ObjectName, upload.StorageID, number, io.TeeReader(data, hasher), size, checksum)
+	if err != nil {
+		switch err {
+		case storage.ErrChecksumMismatch:
+			return nil, ErrChecksumMismatch
+		case storage.ErrUploadNotFound:
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != checksum {
+		// 分片没有被记录，客户端重传时会覆盖
+		return nil, ErrChecksumMismatch
+	}
+
+	part := model.UploadedPart{
+		Number:     number,
+		Size:       size,
+		Checksum:   checksum,
+		ETag:       etag,
+		UploadedAt: time.Now(),
+	}
+	expiresAt := part.UploadedAt.Add(s.resumableExpire)
+	if err := s.resumableRepo.SetPart(ctx, upload.ID, part, expiresAt); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	upload.Parts[strconv.Itoa(number)] = part
+	upload.ExpiresAt = expiresAt
+	upload.UpdatedAt = part.UploadedAt
+	return newUploadProgress(upload), nil
+}
+
+// GetUploadProgress 查询分片上传的进度，客户端断线重连后据此继续上传缺少的分片
+func (s *FileService) GetUploadProgress(ctx context.Context, uploadID, userID string) (*UploadProgress, error) {
+	_, upload, err := s.findResumableUpload(ctx, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return newUploadProgress(upload), nil
+}
+
+// CompleteResumableUpload 所有分片上传完成后合并分片，校验最终对象的大小并创建文件记录
+func (s *FileService) CompleteResumableUpload(ctx context.Context, uploadID, userID string) (*model.File, error) {
+	uploader, upload, err := s.findResumableUpload(ctx, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	progress := newUploadProgress(upload)
+	if len(progress.MissingParts) > 0 {
+		return nil, ErrUploadIncomplete
+	}
+
+	parts := make([]storage.Part, 0, len(progress.UploadedParts))
+	for _, part := range progress.UploadedParts {
+		parts = append(parts, storage.Part{Number: part.Number, ETag: part.ETag})
+	}
+	if err := uploader.CompleteMultipartUpload(ctx, upload.ObjectName, upload.StorageID, parts); err != nil {
+		return nil, err
+	}
+
+	stat, err := s.backend.Stat(ctx, upload.ObjectName)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size != upload.Size {
+		_ = s.backend.Delete(ctx, upload.ObjectName)
+		_ = s.resumableRepo.Delete(ctx, upload.ID)
+		return nil, ErrUploadMismatch
+	}
+
+	// 并发的重复完成请求只有一个能删除记录成功
+	if err := s.resumableRepo.Delete(ctx, upload.ID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	file := &model.File{
+		Name:   upload.Name,
+		Type:   upload.Type,
+		Size:   stat.Size,
+		URL:    s.backend.URL(upload.ObjectName),
+		UserID: upload.UserID,
+	}
+	if err := s.fileRepo.Create(ctx, file); err != nil {
+		_ = s.backend.Delete(ctx, upload.ObjectName)
+		return nil, fmt.Errorf("failed to create file record: %v", err)
+	}
+	return file, nil
+}
+
+// AbortResumableUpload 取消分片上传，删除已上传的分片和上传记录
+func (s *FileService) AbortResumableUpload(ctx context.Context, uploadID, userID string) error {
+	// 已过期的上传也可以主动取消
+	uploader, upload, err := s.findResumableUpload(ctx, uploadID, userID)
+	if err != nil && err != ErrUploadExpired {
+		return err
+	}
+	return s.removeResumableUpload(ctx, uploader, upload)
+}
+
+// findResumableUpload 查找当前用户的分片上传，不属于当前用户时当作不存在，已过期时同时返回上传记录
+func (s *FileService) findResumableUpload(ctx context.Context, uploadID, userID string) (storage.MultipartUploader, *model.ResumableUpload, error) {
+	uploader, ok := s.backend.(storage.MultipartUploader)
+	if !ok {
+		return nil, nil, ErrStorageUnsupported
+	}
+	uploadObjID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return nil, nil, ErrUploadNotFound
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, nil, errors.New("invalid user ID")
+	}
+
+	upload, err := s.resumableRepo.FindByID(ctx, uploadObjID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, ErrUploadNotFound
+		}
+		return nil, nil, err
+	}
+	if upload.UserID != userObjID {
+		return nil, nil, ErrUploadNotFound
+	}
+	if upload.Parts == nil {
+		upload.Parts = map[string]model.UploadedPart{}
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return uploader, upload, ErrUploadExpired
+	}
+	return uploader, upload, nil
+}
+
+// removeResumableUpload 取消存储后端中的分片上传，再删除上传记录
+func (s *FileService) removeResumableUpload(ctx context.Context, uploader storage.MultipartUploader, upload *model.ResumableUpload) error {
+	if err := uploader.AbortMultipartUpload(ctx, upload.ObjectName, upload.StorageID); err != nil {
+		return err
+	}
+	if err := s.resumableRepo.Delete(ctx, upload.ID); err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	return nil
+}
+
+// cleanupExpiredResumableUploads 取消长时间没有进展的分片上传并删除已上传的分片
+func (s *FileService) cleanupExpiredResumableUploads(ctx context.Context) {
+	uploader, ok := s.backend.(storage.MultipartUploader)
+	if !ok {
+		return
+	}
+	for {
+		uploads, err := s.resumableRepo.FindExpired(ctx, time.Now(), uploadCleanupBatchSize)
+		if err != nil {
+			log.Printf("Failed to find expired resumable uploads: %v", err)
+			return
+		}
+
+		removed := 0
+		for _, upload := range uploads {
+			if err := s.removeResumableUpload(ctx, uploader, upload); err != nil {
+				log.Printf("Failed to remove expired resumable upload %s: %v", upload.ID.Hex(), err)
+				continue
+			}
+			removed++
+		}
+		if len(uploads) < uploadCleanupBatchSize || removed < len(uploads) {
+			return
+		}
+	}
+}
@@ -5,59 +5,111 @@ import (
 	"chatweb/internal/repository"
 	"chatweb/pkg/storage"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type FileService struct {
-	fileRepo    *repository.FileRepository
-	minioClient *storage.MinioClient
+	fileRepo      *repository.FileRepository
+	uploadRepo    *repository.FileUploadRepository
+	resumableRepo *repository.ResumableUploadRepository
+	messageRepo   *repository.MessageRepository
+	groupRepo     *repository.GroupRepository
+	channelRepo   *repository.GroupChannelRepository
+	userRepo      *repository.UserRepository
+	backend       storage.Backend // 文件存储后端，本地文件系统或 MinIO/S3
+
+	maxUploadSize  int64         // 单个文件的最大大小（字节）
+	uploadExpire   time.Duration // 预签名上传 URL 的有效期
+	downloadExpire time.Duration // 预签名下载 URL 的有效期
+
+	maxResumableSize int64         // 分片上传单个文件的最大大小（字节）
+	partSize         int64         // 分片大小（字节）
+	resumableExpire  time.Duration // 分片上传无进展多久后过期
 }
 
-func NewFileService(fileRepo *repository.FileRepository, minioClient *storage.MinioClient) *FileService {
+func NewFileService(
+	fileRepo *repository.FileRepository,
+	uploadRepo *repository.FileUploadRepository,
+	resumableRepo *repository.ResumableUploadRepository,
+	messageRepo *repository.MessageRepository,
+	groupRepo *repository.GroupRepository,
+	channelRepo *repository.GroupChannelRepository,
+	userRepo *repository.UserRepository,
+	backend storage.Backend,
+	maxUploadSize int64,
+	uploadExpire, downloadExpire time.Duration,
+	maxResumableSize, partSize int64,
+	resumableExpire time.Duration,
+) *FileService {
 	return &FileService{
-		fileRepo:    fileRepo,
-		minioClient: minioClient,
+		fileRepo:       fileRepo,
+		uploadRepo:     uploadRepo,
+		resumableRepo:  resumableRepo,
+		messageRepo:    messageRepo,
+		groupRepo:      groupRepo,
+		channelRepo:    channelRepo,
+		userRepo:       userRepo,
+		backend:        backend,
+		maxUploadSize:  maxUploadSize,
+		uploadExpire:   uploadExpire,
+		downloadExpire: downloadExpire,
+
+		maxResumableSize: maxResumableSize,
+		partSize:         partSize,
+		resumableExpire:  resumableExpire,
 	}
 }
 
+// UploadFile 校验大小和类型后把文件写入存储后端并创建文件记录，所有经服务端中转的上传都走这里
 func (s *FileService) UploadFile(ctx context.Context, file *multipart.FileHeader, userID string) (*model.File, error) {
-	// 生成唯一的文件名
-	ext := filepath.Ext(file.Filename)
-	objectName := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(file.Filename, ext), time.Now().Unix(), ext)
-
-	// 上传到MinIO
-	url, err := s.minioClient.UploadFile(ctx, file, objectName)
+	userObjID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file: %v", err)
+		return nil, err
+	}
+	if file.Size > s.maxUploadSize {
+		return nil, ErrFileTooLarge
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	contentType, err := resolveUploadContentType(ext, file.Header.Get("Content-Type"))
+	if err != nil {
+		// 部分客户端不带或带错 Content-Type，扩展名合法时按扩展名处理
+		if contentType, err = resolveUploadContentType(ext, ""); err != nil {
+			return nil, err
+		}
 	}
 
-	// 确定文件类型
-	fileType := s.determineFileType(ext)
-
-	userObjID, err := primitive.ObjectIDFromHex(userID)
+	src, err := file.Open()
 	if err != nil {
 		return nil, err
 	}
+	defer src.Close()
+
+	// 对象名不使用原始文件名，避免重名覆盖和路径注入
+	objectName := fmt.Sprintf("%s/%s%s", userObjID.Hex(), primitive.NewObjectID().Hex(), ext)
+	if err := s.backend.Put(ctx, objectName, src, file.Size, contentType); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %v", err)
+	}
 
 	// 创建文件记录
 	fileRecord := &model.File{
-		Name:   file.Filename,
-		Type:   fileType,
+		Name:   filepath.Base(file.Filename),
+		Type:   s.determineFileType(ext),
 		Size:   file.Size,
-		URL:    url,
+		URL:    s.backend.URL(objectName),
 		UserID: userObjID,
 	}
 
 	if err := s.fileRepo.Create(ctx, fileRecord); err != nil {
 		// 如果数据库创建失败，删除已上传的文件
-		_ = s.minioClient.DeleteFile(ctx, objectName)
+		_ = s.backend.Delete(ctx, objectName)
 		return nil, fmt.Errorf("failed to create file record: %v", err)
 	}
 
@@ -95,14 +147,162 @@ func (s *FileService) DeleteFile(ctx context.Context, fileID string, userID stri
 		return fmt.Errorf("unauthorized to delete this file")
 	}
 
-	// 从MinIO删除文件
-	objectName := strings.TrimPrefix(file.URL, "/"+s.minioClient.GetBucketName()+"/")
-	if err := s.minioClient.DeleteFile(ctx, objectName); err != nil {
+	return s.removeFile(ctx, file)
+}
+
+// DeleteFileByID 删除文件（存储中的对象和数据库记录），不校验所有者，供群头像等由服务内部管理的文件使用
+func (s *FileService) DeleteFileByID(ctx context.Context, fileID primitive.ObjectID) error {
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	return s.removeFile(ctx, file)
+}
+
+// MessageFile 查找图片或文件消息引用的文件，文件必须由发送者本人上传，图片消息只能引用图片
+func (s *FileService) MessageFile(ctx context.Context, senderID, fileID primitive.ObjectID, messageType model.MessageType) (*model.File, error) {
+	if fileID.IsZero() {
+		return nil, errors.New("file_id is required for image and file messages")
+	}
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrFileAccessDenied
+		}
+		return nil, err
+	}
+	if file.UserID != senderID {
+		return nil, ErrFileAccessDenied
+	}
+	if messageType == model.ImageMessage && file.Type != model.ImageFile {
+		return nil, errors.New("image messages must reference an image file")
+	}
+	return file, nil
+}
+
+// MigrateMessageFileIDs 为升级前的图片和文件消息补记文件 ID，只认领发送者本人上传的文件
+func (s *FileService) MigrateMessageFileIDs(ctx context.Context) error {
+	const batchSize = 500
+	for {
+		messages, err := s.messageRepo.FindLegacyFileMessages(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			fileID := primitive.NilObjectID
+			files, err := s.fileRepo.GetByURLs(ctx, []string{message.Content})
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if file.UserID == message.SenderID {
+					fileID = file.ID
+					break
+				}
+			}
+			if err := s.messageRepo.SetFileID(ctx, message.ID, fileID); err != nil {
+				return err
+			}
+		}
+		if len(messages) < batchSize {
+			return nil
+		}
+	}
+}
+
+// DeleteGroupFiles 删除群组消息引用的文件，文件在发送时已校验为发送者本人上传；
+// 还被群组以外的消息引用或作为头像使用的文件会被保留
+func (s *FileService) DeleteGroupFiles(ctx context.Context, groupID primitive.ObjectID) error {
+	fileIDs, err := s.messageRepo.GroupFileIDs(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	return s.deleteMessageFiles(ctx, fileIDs, func(fileID primitive.ObjectID) (bool, error) {
+		return s.messageRepo.FileReferencedOutsideGroup(ctx, fileID, groupID)
+	})
+}
+
+// DeleteChannelFiles 删除群频道消息引用的文件，还被频道以外的消息引用或作为头像使用的文件会被保留
+func (s *FileService) DeleteChannelFiles(ctx context.Context, channelID primitive.ObjectID) error {
+	fileIDs, err := s.messageRepo.ChannelFileIDs(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	return s.deleteMessageFiles(ctx, fileIDs, func(fileID primitive.ObjectID) (bool, error) {
+		return s.messageRepo.FileReferencedOutsideChannel(ctx, fileID, channelID)
+	})
+}
+
+// deleteMessageFiles 删除消息引用的文件，referencedElsewhere 返回 true 或文件是头像时保留
+func (s *FileService) deleteMessageFiles(ctx context.Context, fileIDs []primitive.ObjectID, referencedElsewhere func(primitive.ObjectID) (bool, error)) error {
+	for _, fileID := range fileIDs {
+		file, err := s.fileRepo.GetByID(ctx, fileID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				continue
+			}
+			return err
+		}
+		referenced, err := referencedElsewhere(fileID)
+		if err != nil {
+			return err
+		}
+		if !referenced {
+			if referenced, err = s.isAvatar(ctx, file); err != nil {
+				return err
+			}
+		}
+		if referenced {
+			continue
+		}
+		if err := s.removeFile(ctx, file); err != nil {
+			return fmt.Errorf("failed to delete file %s: %v", file.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// DeleteOwnedFileByURL 删除 URL 对应且由 ownerID 上传的文件，其他用户的文件和没有文件记录的 URL 会被忽略
+func (s *FileService) DeleteOwnedFileByURL(ctx context.Context, ownerID primitive.ObjectID, url string) error {
+	files, err := s.fileRepo.GetByURLs(ctx, []string{url})
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.UserID != ownerID {
+			continue
+		}
+		if err := s.removeFile(ctx, file); err != nil {
+			return fmt.Errorf("failed to delete file %s: %v", file.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// removeFile 从存储后端删除文件对象，再删除数据库记录
+func (s *FileService) removeFile(ctx context.Context, file *model.File) error {
+	if err := s.backend.Delete(ctx, s.backend.ObjectName(file.URL)); err != nil {
 		return fmt.Errorf("failed to delete file from storage: %v", err)
 	}
 
 	// 从数据库删除记录
-	return s.fileRepo.Delete(ctx, fileObjID)
+	return s.fileRepo.Delete(ctx, file.ID)
+}
+
+// DeleteUserFiles 删除用户上传的所有文件（存储中的对象和数据库记录）
+func (s *FileService) DeleteUserFiles(ctx context.Context, userID primitive.ObjectID) error {
+	files, err := s.fileRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := s.removeFile(ctx, file); err != nil {
+			return fmt.Errorf("failed to delete file %s: %v", file.ID.Hex(), err)
+		}
+	}
+
+	return nil
 }
 
 func (s *FileService) determineFileType(ext string) model.FileType {
@@ -117,19 +317,3 @@ func (s *FileService) determineFileType(ext string) model.FileType {
 		return model.DocFile
 	}
 }
-
-func (s *FileService) Upload(ctx context.Context, file *model.File, reader io.Reader) error {
-	if s.minioClient == nil {
-		return fmt.Errorf("file upload is not available: MinIO client is not initialized")
-	}
-	// ... 其他代码
-	return nil
-}
-
-func (s *FileService) Download(ctx context.Context, fileID string) (io.Reader, error) {
-	if s.minioClient == nil {
-		return nil, fmt.Errorf("file download is not available: MinIO client is not initialized")
-	}
-	// ... 其他代码
-	return nil, nil
-}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxFriendTags      = 50 // 每个用户最多创建的标签数
+	maxTagsPerFriend   = 20 // 每个好友最多关联的标签数
+	maxFriendTagLength = 20 // 标签名称的最大字符数
+)
+
+// 好友列表的排序方式
+const (
+	FriendSortName   = "name"   // 按显示名称（备注、昵称、用户名）排序
+	FriendSortRecent = "recent" // 按最近活跃（最后一条消息或最后在线时间）倒序
+)
+
+const maxPreviewLength = 100 // 最后一条消息预览的最大字符数
+
+// FriendInfo 好友列表中的一项：好友的资料加上当前用户对其的私有设置
+// 内嵌 *model.User，保持与之前直接返回用户列表时相同的字段
+type FriendInfo struct {
+	*model.User
+	Remark      string             `json:"remark"`       // 备注名
+	Tags        []*model.FriendTag `json:"tags"`         // 所属标签
+	Starred     bool               `json:"starred"`      // 是否星标好友
+	Note        string             `json:"note"`         // 描述/备忘
+	Online      bool               `json:"online"`       // 是否在线
+	LastMessage *MessagePreview    `json:"last_message"` // 与该好友的最后一条私聊消息，没有时为 null
+}
+
+// MessagePreview 消息预览，用于列表展示
+type MessagePreview struct {
+	ID        primitive.ObjectID `json:"id"`
+	Type      model.MessageType  `json:"type"`
+	Content   string             `json:"content"` // 超出长度时截断
+	SenderID  primitive.ObjectID `json:"sender_id"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// FriendListFilter 好友列表的过滤条件和排序方式，零值表示不过滤、按名称排序
+type FriendListFilter struct {
+	TagID   string // 只返回带有该标签的好友
+	Starred bool   // 只返回星标好友
+	Sort    string // 排序方式：name 或 recent
+}
+
+// newMessagePreview 生成消息预览
+func newMessagePreview(message *model.Message) *MessagePreview {
+	content := message.Content
+	if utf8.RuneCountInString(content) > maxPreviewLength {
+		content = string([]rune(content)[:maxPreviewLength]) + "…"
+	}
+	return &MessagePreview{
+		ID:        message.ID,
+		Type:      message.Type,
+		Content:   content,
+		SenderID:  message.SenderID,
+		CreatedAt: message.CreatedAt,
+	}
+}
+
+// displayName 好友在列表中显示的名称：优先备注，其次昵称，最后用户名
+func (f *FriendInfo) displayName() string {
+	if f.Remark != "" {
+		return f.Remark
+	}
+	if f.Nickname != "" {
+		return f.Nickname
+	}
+	return f.Username
+}
+
+// lastActivity 最近活跃时间：最后一条消息和最后在线时间中较晚的一个
+func (f *FriendInfo) lastActivity() time.Time {
+	var t time.Time
+	if f.LastMessage != nil {
+		t = f.LastMessage.CreatedAt
+	}
+	if f.LastSeen != nil && f.LastSeen.After(t) {
+		t = *f.LastSeen
+	}
+	return t
+}
+
+// sortFriends 按指定方式排序好友列表，星标好友在按名称排序时置顶
+func sortFriends(friends []*FriendInfo, by string) {
+	sort.SliceStable(friends, func(i, j int) bool {
+		a, b := friends[i], friends[j]
+		if by == FriendSortRecent {
+			ta, tb := a.lastActivity(), b.lastActivity()
+			if !ta.Equal(tb) {
+				return ta.After(tb)
+			}
+		} else if a.Starred != b.Starred {
+			return a.Starred
+		}
+		return strings.ToLower(a.displayName()) < strings.ToLower(b.displayName())
+	})
+}
+
+// FriendMetaUpdate 好友设置更新请求，字段为 nil 表示不修改
+type FriendMetaUpdate struct {
+	Remark  *string   `json:"remark"`  // 备注名，最多 32 个字符
+	TagIDs  *[]string `json:"tag_ids"` // 标签 ID 列表，整体替换
+	Starred *bool     `json:"starred"` // 是否星标
+	Note    *string   `json:"note"`    // 描述，最多 200 个字符
+}
+
+// Validate 校验各字段的长度，并去除首尾空白
+func (u *FriendMetaUpdate) Validate() error {
+	for _, v := range []*string{u.Remark, u.Note} {
+		if v != nil {
+			*v = strings.TrimSpace(*v)
+		}
+	}
+	if err := checkLength("remark", u.Remark, 32); err != nil {
+		return err
+	}
+	if err := checkLength("note", u.Note, 200); err != nil {
+		return err
+	}
+	if u.TagIDs != nil && len(*u.TagIDs) > maxTagsPerFriend {
+		return errors.New("too many tags for one friend")
+	}
+	return nil
+}
+
+// UpdateFriendMeta 修改当前用户对某个好友的备注、标签、星标和描述，只对自己可见
+func (s *FriendshipService) UpdateFriendMeta(ctx context.Context, userID, friendID string, update *FriendMetaUpdate) (*model.FriendMeta, error) {
+	userObjID, friendObjID, err := parseUserPair(userID, friendID)
+	if err != nil {
+		return nil, err
+	}
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+
+	isFriend, err := s.friendshipRepo.Exists(ctx, userObjID, friendObjID)
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, errors.New("friend relationship not found")
+	}
+
+	updates := bson.M{}
+	if update.Remark != nil {
+		updates["remark"] = *update.Remark
+	}
+	if update.Note != nil {
+		updates["note"] = *update.Note
+	}
+	if update.Starred != nil {
+		updates["starred"] = *update.Starred
+	}
+	if update.TagIDs != nil {
+		tagIDs, err := s.ownedTagIDs(ctx, userObjID, *update.TagIDs)
+		if err != nil {
+			return nil, err
+		}
+		updates["tag_ids"] = tagIDs
+	}
+
+	return s.metaRepo.Upsert(ctx, userObjID, friendObjID, updates)
+}
+
+// ownedTagIDs 校验标签都属于当前用户，并去重
+func (s *FriendshipService) ownedTagIDs(ctx context.Context, ownerID primitive.ObjectID, ids []string) ([]primitive.ObjectID, error) {
+	tagIDs := make([]primitive.ObjectID, 0, len(ids))
+	seen := make(map[primitive.ObjectID]bool)
+	for _, id := range ids {
+		tagID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, errors.New("invalid tag ID")
+		}
+		if seen[tagID] {
+			continue
+		}
+		if _, err := s.tagRepo.FindByID(ctx, ownerID, tagID); err != nil {
+			return nil, errors.New("tag not found")
+		}
+		seen[tagID] = true
+		tagIDs = append(tagIDs, tagID)
+	}
+	return tagIDs, nil
+}
+
+// ListTags 获取当前用户的所有好友标签
+func (s *FriendshipService) ListTags(ctx context.Context, userID string) ([]*model.FriendTag, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	return s.tagRepo.ListByOwner(ctx, userObjID)
+}
+
+// CreateTag 创建一个好友标签，同一用户下名称不能重复
+func (s *FriendshipService) CreateTag(ctx context.Context, userID, name string) (*model.FriendTag, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if name, err = normalizeTagName(name); err != nil {
+		return nil, err
+	}
+
+	count, err := s.tagRepo.CountByOwner(ctx, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxFriendTags {
+		return nil, errors.New("too many tags")
+	}
+	if _, err := s.tagRepo.FindByName(ctx, userObjID, name); err == nil {
+		return nil, errors.New("tag already exists")
+	}
+
+	tag := &model.FriendTag{OwnerID: userObjID, Name: name}
+	if err := s.tagRepo.Create(ctx, tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// RenameTag 修改好友标签名称
+func (s *FriendshipService) RenameTag(ctx context.Context, userID, tagID, name string) (*model.FriendTag, error) {
+	userObjID, tagObjID, err := parseTagIDs(userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+	if name, err = normalizeTagName(name); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.tagRepo.FindByName(ctx, userObjID, name); err == nil && existing.ID != tagObjID {
+		return nil, errors.New("tag already exists")
+	}
+
+	tag, err := s.tagRepo.Rename(ctx, userObjID, tagObjID, name)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("tag not found")
+	}
+	return tag, err
+}
+
+// DeleteTag 删除好友标签，并从所有好友设置中移除该标签
+func (s *FriendshipService) DeleteTag(ctx context.Context, userID, tagID string) error {
+	userObjID, tagObjID, err := parseTagIDs(userID, tagID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tagRepo.Delete(ctx, userObjID, tagObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("tag not found")
+		}
+		return err
+	}
+	return s.metaRepo.PullTag(ctx, userObjID, tagObjID)
+}
+
+// normalizeTagName 去除首尾空白并校验标签名称长度
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("tag name is required")
+	}
+	if utf8.RuneCountInString(name) > maxFriendTagLength {
+		return "", errors.New("tag name is too long")
+	}
+	return name, nil
+}
+
+// parseTagIDs 将用户 ID 和标签 ID 转换为 ObjectID
+func parseTagIDs(userID, tagID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid user ID")
+	}
+	tagObjID, err := primitive.ObjectIDFromHex(tagID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid tag ID")
+	}
+	return userObjID, tagObjID, nil
+}
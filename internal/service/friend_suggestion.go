@@ -0,0 +1,161 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"context"
+	"errors"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxSuggestionPageSize   = 50  // 推荐好友每页最多返回的数量
+	maxSuggestionCandidates = 500 // 每个聚合管道最多返回的候选人数，限制排序的内存占用
+)
+
+// FriendSuggestion “可能认识的人”中的一项
+type FriendSuggestion struct {
+	User          *PublicProfile `json:"user"`           // 候选用户的公开资料
+	MutualFriends int            `json:"mutual_friends"` // 共同好友数
+	SharedGroups  int            `json:"shared_groups"`  // 共同群组数
+}
+
+// GetSuggestions 推荐可能认识的人：按共同好友数、其次按共同群组数排序
+// 已是好友、存在待处理请求或拉黑关系的用户，以及已注销、被禁用的用户不会出现在结果中
+// 资料按候选人的隐私设置隐藏，返回当前页及候选总数
+func (s *FriendshipService) GetSuggestions(ctx context.Context, userID string, page, pageSize int) ([]*FriendSuggestion, int, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, 0, errors.New("invalid user ID")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxSuggestionPageSize {
+		pageSize = maxSuggestionPageSize
+	}
+
+	friendIDs, exclude, err := s.suggestionExclusions(ctx, userObjID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	mutual, err := s.friendshipRepo.MutualFriendCounts(ctx, friendIDs, exclude, maxSuggestionCandidates)
+	if err != nil {
+		return nil, 0, err
+	}
+	shared, err := s.groupRepo.SharedGroupCounts(ctx, userObjID, exclude, maxSuggestionCandidates)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 合并两个维度的得分
+	scores := make(map[primitive.ObjectID]*FriendSuggestion)
+	ids := make([]primitive.ObjectID, 0, len(mutual)+len(shared))
+	score := func(id primitive.ObjectID) *FriendSuggestion {
+		if suggestion, ok := scores[id]; ok {
+			return suggestion
+		}
+		suggestion := &FriendSuggestion{}
+		scores[id] = suggestion
+		ids = append(ids, id)
+		return suggestion
+	}
+	for _, c := range mutual {
+		score(c.UserID).MutualFriends = c.Count
+	}
+	for _, c := range shared {
+		score(c.UserID).SharedGroups = c.Count
+	}
+
+	// 先排除已注销和被禁用的用户，保证总数和分页准确
+	candidates, err := s.userRepo.FilterActiveIDs(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := scores[candidates[i]], scores[candidates[j]]
+		if a.MutualFriends != b.MutualFriends {
+			return a.MutualFriends > b.MutualFriends
+		}
+		if a.SharedGroups != b.SharedGroups {
+			return a.SharedGroups > b.SharedGroups
+		}
+		return candidates[i].Hex() < candidates[j].Hex()
+	})
+
+	total := len(candidates)
+	start := (page - 1) * pageSize
+	if start >= total {
+		return []*FriendSuggestion{}, total, nil
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	pageIDs := candidates[start:end]
+
+	// 只为当前页批量查询用户信息，并按候选人的隐私设置隐藏资料
+	users, err := s.userRepo.FindByIDs(ctx, pageIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := s.privacyService.Redact(ctx, userObjID, users); err != nil {
+		return nil, 0, err
+	}
+	userByID := make(map[primitive.ObjectID]*model.User, len(users))
+	for _, user := range users {
+		userByID[user.ID] = user
+	}
+
+	result := make([]*FriendSuggestion, 0, len(pageIDs))
+	for _, id := range pageIDs {
+		user, ok := userByID[id]
+		if !ok || user.DeletedAt != nil || user.Disabled {
+			continue
+		}
+		suggestion := scores[id]
+		suggestion.User = newPublicProfile(user)
+		result = append(result, suggestion)
+	}
+	return result, total, nil
+}
+
+// suggestionExclusions 返回当前用户的好友 ID，以及不应被推荐的用户 ID（自己、好友、待处理请求、拉黑）
+func (s *FriendshipService) suggestionExclusions(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, []primitive.ObjectID, error) {
+	friendships, err := s.friendshipRepo.GetFriendsList(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	friendIDs := make([]primitive.ObjectID, 0, len(friendships))
+	for _, f := range friendships {
+		if f.UserID == userID {
+			friendIDs = append(friendIDs, f.FriendID)
+		} else {
+			friendIDs = append(friendIDs, f.UserID)
+		}
+	}
+
+	pending, err := s.requestRepo.FindPendingCounterparties(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocked, err := s.blockService.BlockedSet(ctx, userID.Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exclude := make([]primitive.ObjectID, 0, 1+len(friendIDs)+len(pending)+len(blocked))
+	exclude = append(exclude, userID)
+	exclude = append(exclude, friendIDs...)
+	exclude = append(exclude, pending...)
+	for id := range blocked {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			exclude = append(exclude, objID)
+		}
+	}
+	return friendIDs, exclude, nil
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -16,69 +18,333 @@ type FriendshipService struct {
 	friendshipRepo *repository.FriendshipRepository
 	// userRepo 用于与数据库交互，管理用户数据
 	userRepo *repository.UserRepository
+	// requestRepo 用于与数据库交互，管理好友请求数据
+	requestRepo *repository.FriendRequestRepository
+	// tagRepo 用于管理用户自定义的好友标签
+	tagRepo *repository.FriendTagRepository
+	// metaRepo 用于管理用户对好友的备注、标签和星标
+	metaRepo *repository.FriendMetaRepository
+	// groupRepo 用于统计共同群组，生成好友推荐
+	groupRepo *repository.GroupRepository
+	// messageRepo 用于查询与每个好友的最后一条私聊消息
+	messageRepo *repository.MessageRepository
+	// onlineService 用于查询好友的在线状态
+	onlineService *OnlineService
+	// notificationService 用于向请求双方推送实时通知
+	notificationService *NotificationService
+	// blockService 用于拦截存在拉黑关系的用户之间的好友请求
+	blockService *BlockService
+	// privacyService 用于按好友的隐私设置隐藏其资料和在线状态
+	privacyService *PrivacyService
+	// requestTTL 好友请求的有效期
+	requestTTL time.Duration
 }
 
 // NewFriendshipService 创建并返回一个新的 FriendshipService 实例
-func NewFriendshipService(friendshipRepo *repository.FriendshipRepository, userRepo *repository.UserRepository) *FriendshipService {
+func NewFriendshipService(
+	friendshipRepo *repository.FriendshipRepository,
+	userRepo *repository.UserRepository,
+	requestRepo *repository.FriendRequestRepository,
+	tagRepo *repository.FriendTagRepository,
+	metaRepo *repository.FriendMetaRepository,
+	groupRepo *repository.GroupRepository,
+	messageRepo *repository.MessageRepository,
+	onlineService *OnlineService,
+	notificationService *NotificationService,
+	blockService *BlockService,
+	privacyService *PrivacyService,
+	requestTTL time.Duration,
+) *FriendshipService {
 	return &FriendshipService{
-		friendshipRepo: friendshipRepo,
-		userRepo:       userRepo,
+		friendshipRepo:      friendshipRepo,
+		userRepo:            userRepo,
+		requestRepo:         requestRepo,
+		tagRepo:             tagRepo,
+		metaRepo:            metaRepo,
+		groupRepo:           groupRepo,
+		messageRepo:         messageRepo,
+		onlineService:       onlineService,
+		notificationService: notificationService,
+		blockService:        blockService,
+		privacyService:      privacyService,
+		requestTTL:          requestTTL,
 	}
 }
 
+// FriendRequestInfo 好友请求及对方（收到的请求为发送方，发出的请求为接收方）的用户信息
+type FriendRequestInfo struct {
+	*model.FriendRequest
+	User *PublicProfile `json:"user"` // 对方按其隐私设置可见的公开资料
+}
+
 // SendFriendRequest 发送好友请求
-// 输入: userID - 当前用户的ID, friendID - 想要添加为好友的用户ID
+// 输入: userID - 当前用户的ID, friendID - 想要添加为好友的用户ID, greeting - 验证消息
 // 功能: 1. 检查好友是否存在
 //  2. 检查是否尝试添加自己为好友
-//  3. 检查是否已经是好友
-//  4. 如果条件满足，则在数据库中创建好友关系记录
-func (s *FriendshipService) SendFriendRequest(ctx context.Context, userID, friendID string) error {
+//  3. 检查是否已经是好友或已有待处理的请求
+//  4. 对方已经向自己发出请求时直接同意，否则创建待处理的请求并通知对方
+func (s *FriendshipService) SendFriendRequest(ctx context.Context, userID, friendID, greeting string) (*model.FriendRequest, error) {
 	// 将用户和朋友的字符串ID转换为 ObjectID
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-	friendObjID, _ := primitive.ObjectIDFromHex(friendID)
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	friendObjID, err := primitive.ObjectIDFromHex(friendID)
+	if err != nil {
+		return nil, errors.New("invalid friend ID")
+	}
+
+	// 判断是否尝试添加自己为好友
+	if userObjID == friendObjID {
+		return nil, errors.New("cannot add yourself as friend")
+	}
 
 	// 查询朋友是否存在
 	friend, err := s.userRepo.FindByID(ctx, friendObjID)
+	if err != nil || friend.DeletedAt != nil {
+		return nil, errors.New("user not found") // 如果没有找到用户，则返回错误
+	}
+
+	// 任一方拉黑了对方时拒绝，不透露具体原因
+	if s.blockService.IsBlocked(ctx, userObjID, friendObjID) {
+		return nil, ErrActionNotAllowed
+	}
+
+	// 检查是否已经是好友
+	isFriend, err := s.friendshipRepo.Exists(ctx, userObjID, friendObjID)
 	if err != nil {
-		return errors.New("user not found") // 如果没有找到用户，则返回错误
+		return nil, err
+	}
+	if isFriend {
+		return nil, errors.New("already friends")
 	}
 
-	// 判断是否尝试添加自己为好友
-	if friend.ID.Hex() == userID {
-		return errors.New("cannot add yourself as friend")
+	// 不重复发送
+	if _, err := s.requestRepo.FindPending(ctx, userObjID, friendObjID); err == nil {
+		return nil, errors.New("friend request already sent")
 	}
 
-	// 查询当前用户的好友列表
-	friendships, err := s.friendshipRepo.GetFriendsList(ctx, userObjID)
+	// 对方已经向自己发出了请求，视为双方同意
+	if incoming, err := s.requestRepo.FindPending(ctx, friendObjID, userObjID); err == nil {
+		return s.AcceptRequest(ctx, userID, incoming.ID.Hex())
+	}
+
+	request := &model.FriendRequest{
+		FromUserID: userObjID,
+		ToUserID:   friendObjID,
+		Greeting:   strings.TrimSpace(greeting),
+		Status:     model.FriendRequestPending,
+		ExpiresAt:  time.Now().Add(s.requestTTL),
+	}
+	if err := s.requestRepo.Create(ctx, request); err != nil {
+		return nil, err
+	}
+
+	content := "请求添加你为好友"
+	if request.Greeting != "" {
+		content = request.Greeting
+	}
+	s.notifyRequest(ctx, request, request.ToUserID, request.FromUserID, "新的好友请求", content)
+	return request, nil
+}
+
+// AcceptRequest 同意好友请求，只有接收方可以操作，同意后双方成为好友
+func (s *FriendshipService) AcceptRequest(ctx context.Context, userID, requestID string) (*model.FriendRequest, error) {
+	request, err := s.respond(ctx, userID, requestID, model.FriendRequestAccepted)
 	if err != nil {
-		return err // 如果获取好友列表失败，返回错误
+		return nil, err
 	}
 
-	// 检查是否已经是好友
-	for _, f := range friendships {
-		// 如果已经存在这条好友关系，则返回错误
-		if (f.UserID == userObjID && f.FriendID == friend.ID) ||
-			(f.UserID == friend.ID && f.FriendID == userObjID) {
-			return errors.New("already friends")
+	// 双方可能已经通过其他途径成为好友，避免重复创建
+	isFriend, err := s.friendshipRepo.Exists(ctx, request.FromUserID, request.ToUserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		if err := s.friendshipRepo.Create(ctx, &model.Friendship{
+			UserID:   request.FromUserID,
+			FriendID: request.ToUserID,
+		}); err != nil {
+			return nil, err
 		}
 	}
 
-	// 创建新的好友关系记录
-	friendship := &model.Friendship{
-		UserID:   userObjID,
-		FriendID: friend.ID,
+	s.notifyRequest(ctx, request, request.FromUserID, request.ToUserID, "好友请求已通过", "对方已同意你的好友请求")
+	return request, nil
+}
+
+// RejectRequest 拒绝好友请求，只有接收方可以操作
+func (s *FriendshipService) RejectRequest(ctx context.Context, userID, requestID string) (*model.FriendRequest, error) {
+	request, err := s.respond(ctx, userID, requestID, model.FriendRequestRejected)
+	if err != nil {
+		return nil, err
 	}
 
-	// 在数据库中创建这条好友关系记录
-	return s.friendshipRepo.Create(ctx, friendship)
+	s.notifyRequest(ctx, request, request.FromUserID, request.ToUserID, "好友请求被拒绝", "对方拒绝了你的好友请求")
+	return request, nil
 }
 
-// GetFriendsList 获取指定用户的好友列表
-// 输入: userID - 用户的ID
+// CancelRequest 撤回自己发出的好友请求，只有发送方可以操作
+func (s *FriendshipService) CancelRequest(ctx context.Context, userID, requestID string) (*model.FriendRequest, error) {
+	request, err := s.findRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.FromUserID.Hex() != userID {
+		return nil, errors.New("friend request not found")
+	}
+
+	if request, err = s.requestRepo.Transition(ctx, request.ID, model.FriendRequestCancelled); err != nil {
+		return nil, err
+	}
+
+	s.notifyRequest(ctx, request, request.ToUserID, request.FromUserID, "好友请求已撤回", "对方撤回了好友请求")
+	return request, nil
+}
+
+// ListIncomingRequests 获取用户收到的好友请求，status 为空表示全部状态
+func (s *FriendshipService) ListIncomingRequests(ctx context.Context, userID, status string) ([]*FriendRequestInfo, error) {
+	return s.listRequests(ctx, "to_user_id", userID, status)
+}
+
+// ListOutgoingRequests 获取用户发出的好友请求，status 为空表示全部状态
+func (s *FriendshipService) ListOutgoingRequests(ctx context.Context, userID, status string) ([]*FriendRequestInfo, error) {
+	return s.listRequests(ctx, "from_user_id", userID, status)
+}
+
+// listRequests 查询好友请求并附带对方的用户信息
+func (s *FriendshipService) listRequests(ctx context.Context, field, userID, status string) ([]*FriendRequestInfo, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	requestStatus := model.FriendRequestStatus(status)
+	switch requestStatus {
+	case "", model.FriendRequestPending, model.FriendRequestAccepted, model.FriendRequestRejected,
+		model.FriendRequestCancelled, model.FriendRequestExpired:
+	default:
+		return nil, errors.New("invalid status")
+	}
+
+	// 先把超时的请求标记为过期，保证返回的状态准确
+	if err := s.requestRepo.ExpirePending(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+
+	requests, err := s.requestRepo.ListByUser(ctx, field, userObjID, requestStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	// 批量查询对方的用户信息
+	otherIDs := make([]primitive.ObjectID, 0, len(requests))
+	for _, request := range requests {
+		otherIDs = append(otherIDs, otherParty(request, userObjID))
+	}
+	users := make(map[primitive.ObjectID]*model.User)
+	if len(otherIDs) > 0 {
+		found, err := s.userRepo.FindByIDs(ctx, otherIDs)
+		if err != nil {
+			return nil, err
+		}
+		// 按对方的隐私设置隐藏资料，联系方式等非公开字段不返回
+		if _, err := s.privacyService.Redact(ctx, userObjID, found); err != nil {
+			return nil, err
+		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	result := make([]*FriendRequestInfo, 0, len(requests))
+	for _, request := range requests {
+		info := &FriendRequestInfo{FriendRequest: request}
+		if user := users[otherParty(request, userObjID)]; user != nil {
+			info.User = newPublicProfile(user)
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// respond 接收方处理好友请求
+func (s *FriendshipService) respond(ctx context.Context, userID, requestID string, status model.FriendRequestStatus) (*model.FriendRequest, error) {
+	request, err := s.findRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.ToUserID.Hex() != userID {
+		return nil, errors.New("friend request not found")
+	}
+
+	// 请求发出后双方之间出现拉黑时不能再同意
+	if status == model.FriendRequestAccepted && s.blockService.IsBlocked(ctx, request.FromUserID, request.ToUserID) {
+		return nil, ErrActionNotAllowed
+	}
+
+	return s.requestRepo.Transition(ctx, request.ID, status)
+}
+
+// findRequest 根据字符串 ID 查找好友请求
+func (s *FriendshipService) findRequest(ctx context.Context, requestID string) (*model.FriendRequest, error) {
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, errors.New("invalid request ID")
+	}
+
+	request, err := s.requestRepo.FindByID(ctx, objID)
+	if err != nil {
+		return nil, errors.New("friend request not found")
+	}
+	return request, nil
+}
+
+// notifyRequest 向 userID 发送与好友请求相关的通知，通知会通过 WebSocket 实时推送
+func (s *FriendshipService) notifyRequest(ctx context.Context, request *model.FriendRequest, userID, senderID primitive.ObjectID, title, content string) {
+	notification := &model.Notification{
+		Type:      model.FriendNotification,
+		Title:     title,
+		Content:   content,
+		UserID:    userID,
+		SenderID:  senderID,
+		RequestID: request.ID,
+	}
+	if err := s.notificationService.CreateNotification(ctx, notification); err != nil {
+		log.Printf("Failed to send friend request notification: %v", err)
+	}
+}
+
+// otherParty 返回好友请求中另一方的用户 ID
+func otherParty(request *model.FriendRequest, userID primitive.ObjectID) primitive.ObjectID {
+	if request.FromUserID == userID {
+		return request.ToUserID
+	}
+	return request.FromUserID
+}
+
+// GetFriendsList 获取指定用户的好友列表，附带备注、标签、在线状态和最后一条私聊消息
+// 好友资料、备注和最后消息各用一次批量查询获取，不随好友数量逐个查询
+// 输入: userID - 用户的ID, filter - 按标签或星标过滤及排序方式
 // 输出: 好友列表，或在出错时返回错误
-func (s *FriendshipService) GetFriendsList(ctx context.Context, userID string) ([]*model.User, error) {
+func (s *FriendshipService) GetFriendsList(ctx context.Context, userID string, filter FriendListFilter) ([]*FriendInfo, error) {
 	// 将字符串ID转换为 ObjectID
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	var filterTagID primitive.ObjectID
+	if filter.TagID != "" {
+		if filterTagID, err = primitive.ObjectIDFromHex(filter.TagID); err != nil {
+			return nil, errors.New("invalid tag ID")
+		}
+	}
+	switch filter.Sort {
+	case "", FriendSortName, FriendSortRecent:
+	default:
+		return nil, errors.New("sort must be name or recent")
+	}
 
 	// 获取当前用户的所有好友关系
 	friendships, err := s.friendshipRepo.GetFriendsList(ctx, userObjID)
@@ -86,25 +352,89 @@ func (s *FriendshipService) GetFriendsList(ctx context.Context, userID string) (
 		return nil, err // 如果获取好友列表失败，返回错误
 	}
 
-	// 创建一个切片来存储好友信息
-	var friends []*model.User
+	// 当前用户对好友的设置和标签
+	metas, err := s.metaRepo.ListByOwner(ctx, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	metaByFriend := make(map[primitive.ObjectID]*model.FriendMeta, len(metas))
+	for _, meta := range metas {
+		metaByFriend[meta.FriendID] = meta
+	}
+
+	tags, err := s.tagRepo.ListByOwner(ctx, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	tagByID := make(map[primitive.ObjectID]*model.FriendTag, len(tags))
+	for _, tag := range tags {
+		tagByID[tag.ID] = tag
+	}
+
+	// 先按备注条件过滤，再批量查询剩余好友的资料
+	infos := make(map[primitive.ObjectID]*FriendInfo, len(friendships))
+	friendIDs := make([]primitive.ObjectID, 0, len(friendships))
 	for _, f := range friendships {
 		// 确定朋友的ID
-		var friendID primitive.ObjectID
+		friendID := f.UserID
 		if f.UserID == userObjID {
 			friendID = f.FriendID
-		} else {
-			friendID = f.UserID
 		}
 
-		// 查询好友的详细信息
-		friend, err := s.userRepo.FindByID(ctx, friendID)
-		if err != nil {
-			continue // 如果找不到好友，则跳过
+		info := &FriendInfo{Tags: []*model.FriendTag{}}
+		hasTag := false
+		if meta, ok := metaByFriend[friendID]; ok {
+			info.Remark = meta.Remark
+			info.Starred = meta.Starred
+			info.Note = meta.Note
+			for _, tagID := range meta.TagIDs {
+				if tag, ok := tagByID[tagID]; ok {
+					info.Tags = append(info.Tags, tag)
+				}
+				if tagID == filterTagID {
+					hasTag = true
+				}
+			}
+		}
+		if (filter.TagID != "" && !hasTag) || (filter.Starred && !info.Starred) {
+			continue
 		}
-		friends = append(friends, friend) // 将好友添加到列表中
+
+		infos[friendID] = info
+		friendIDs = append(friendIDs, friendID)
+	}
+
+	friends := make([]*FriendInfo, 0, len(friendIDs))
+	if len(friendIDs) == 0 {
+		return friends, nil
+	}
+
+	users, err := s.userRepo.FindByIDs(ctx, friendIDs)
+	if err != nil {
+		return nil, err
+	}
+	lastMessages, err := s.messageRepo.LastDirectMessages(ctx, userObjID, friendIDs)
+	if err != nil {
+		return nil, err
+	}
+	// 按好友的隐私设置和拉黑关系隐藏资料、在线状态和最后在线时间
+	presence, err := s.privacyService.Redact(ctx, userObjID, users)
+	if err != nil {
+		return nil, err
 	}
 
+	for _, user := range users {
+		info := infos[user.ID]
+		info.User = user
+		info.Online = presence[user.ID] && s.onlineService.IsUserOnline(user.ID.Hex())
+		if message, ok := lastMessages[user.ID]; ok {
+			info.LastMessage = newMessagePreview(message)
+		}
+		friends = append(friends, info)
+	}
+
+	sortFriends(friends, filter.Sort)
+
 	// 返回好友列表
 	return friends, nil
 }
@@ -144,5 +474,6 @@ func (s *FriendshipService) DeleteFriend(ctx context.Context, friendId string, u
 		return err
 	}
 
-	return nil
+	// 双方对彼此的备注随好友关系一起删除
+	return s.metaRepo.DeleteBetween(ctx, userObjID, friendObjID)
 }
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxAnnouncementLength = 2000 // 群公告的最大字符数
+	maxAnnouncementList   = 50   // 公告列表最多返回的数量
+)
+
+// AnnouncementReadStatus 群公告的已读情况
+type AnnouncementReadStatus struct {
+	Acks   []model.GroupAnnouncementAck `json:"acks"`   // 已确认的成员及确认时间
+	Unread []primitive.ObjectID         `json:"unread"` // 尚未确认的当前成员
+}
+
+// PostAnnouncement 发布群公告，需要 announce 权限；通知其他成员并推送群组事件
+func (s *GroupService) PostAnnouncement(ctx context.Context, groupID, actorID, content string) (*model.GroupAnnouncement, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("content cannot be empty")
+	}
+	if utf8.RuneCountInString(content) > maxAnnouncementLength {
+		return nil, fmt.Errorf("content must be at most %d characters", maxAnnouncementLength)
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermAnnounce)
+	if err != nil {
+		return nil, err
+	}
+	announcement := &model.GroupAnnouncement{
+		GroupID:  group.ID,
+		AuthorID: actorObjID,
+		Content:  content,
+	}
+	if err := s.announcementRepo.Create(ctx, announcement); err != nil {
+		return nil, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionAnnouncementPosted, actorObjID, announcement.ID, "")
+	s.notifyGroupMembers(ctx, group, actorObjID, "群公告", fmt.Sprintf("群聊「%s」发布了新公告，请查看并确认", group.Name))
+	return announcement, nil
+}
+
+// ListAnnouncements 获取群公告列表，最新的在前，并标记当前用户是否已确认
+func (s *GroupService) ListAnnouncements(ctx context.Context, groupID, userID string) ([]*model.GroupAnnouncement, error) {
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	announcements, err := s.announcementRepo.ListByGroup(ctx, group.ID, maxAnnouncementList)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range announcements {
+		a.Acked = a.AckedBy(userObjID)
+	}
+	return announcements, nil
+}
+
+// AckAnnouncement 成员确认已阅读群公告，重复确认不报错
+func (s *GroupService) AckAnnouncement(ctx context.Context, groupID, userID, announcementID string) error {
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	announcementObjID, err := primitive.ObjectIDFromHex(announcementID)
+	if err != nil {
+		return errors.New("invalid announcement ID")
+	}
+	if _, err := s.announcementRepo.FindByID(ctx, group.ID, announcementObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("announcement not found")
+		}
+		return err
+	}
+
+	_, err = s.announcementRepo.Ack(ctx, group.ID, announcementObjID, userObjID)
+	return err
+}
+
+// GetAnnouncementReadStatus 获取群公告的已读列表和当前尚未确认的成员，需要 announce 权限
+func (s *GroupService) GetAnnouncementReadStatus(ctx context.Context, groupID, actorID, announcementID string) (*AnnouncementReadStatus, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	announcementObjID, err := primitive.ObjectIDFromHex(announcementID)
+	if err != nil {
+		return nil, errors.New("invalid announcement ID")
+	}
+	if _, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermAnnounce); err != nil {
+		return nil, err
+	}
+
+	announcement, err := s.announcementRepo.FindByID(ctx, groupObjID, announcementObjID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("announcement not found")
+		}
+		return nil, err
+	}
+	members, err := s.groupRepo.GetGroupMembers(ctx, groupObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &AnnouncementReadStatus{Acks: announcement.Acks, Unread: []primitive.ObjectID{}}
+	for _, member := range members {
+		if member.UserID != announcement.AuthorID && !announcement.AckedBy(member.UserID) {
+			status.Unread = append(status.Unread, member.UserID)
+		}
+	}
+	return status, nil
+}
+
+// DeleteAnnouncement 删除群公告，需要 announce 权限
+func (s *GroupService) DeleteAnnouncement(ctx context.Context, groupID, actorID, announcementID string) error {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	announcementObjID, err := primitive.ObjectIDFromHex(announcementID)
+	if err != nil {
+		return errors.New("invalid announcement ID")
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermAnnounce)
+	if err != nil {
+		return err
+	}
+	if err := s.announcementRepo.Delete(ctx, group.ID, announcementObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("announcement not found")
+		}
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionAnnouncementDeleted, actorObjID, announcementObjID, "")
+	return nil
+}
+
+// PinMessage 置顶群组中的一条消息，需要 pin 权限且能读取消息所在的频道，每个频道最多置顶 maxPins 条
+// 置顶通知只发给能读取该频道的成员
+func (s *GroupService) PinMessage(ctx context.Context, groupID, actorID, messageID string) error {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	messageObjID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return errors.New("invalid message ID")
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermPin)
+	if err != nil {
+		return err
+	}
+	message, err := s.messageRepo.GetByID(ctx, messageObjID)
+	if err != nil || message.GroupID != group.ID || message.Type == model.SystemMessage {
+		return errors.New("message not found")
+	}
+	channel, err := s.readableChannel(ctx, group, message.ChannelID, actorObjID)
+	if err != nil {
+		return err
+	}
+
+	pin := model.GroupPin{MessageID: messageObjID, ChannelID: channel.ID, PinnedBy: actorObjID}
+	if err := s.groupRepo.AddPin(ctx, group.ID, pin, s.maxPins); err != nil {
+		if err == repository.ErrPinLimitReached {
+			return fmt.Errorf("at most %d messages can be pinned per channel", s.maxPins)
+		}
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionMessagePinned, actorObjID, messageObjID, "")
+	s.notifyChannelReaders(ctx, group, channel, actorObjID, "置顶消息", fmt.Sprintf("群聊「%s」的频道「%s」有新的置顶消息", group.Name, channel.Name))
+	return nil
+}
+
+// UnpinMessage 取消置顶群组中的一条消息，需要 pin 权限
+func (s *GroupService) UnpinMessage(ctx context.Context, groupID, actorID, messageID string) error {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	messageObjID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return errors.New("invalid message ID")
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermPin)
+	if err != nil {
+		return err
+	}
+	if err := s.groupRepo.RemovePin(ctx, group.ID, messageObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("message is not pinned")
+		}
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionMessageUnpinned, actorObjID, messageObjID, "")
+	return nil
+}
+
+// ListPins 获取群组的置顶消息及其内容，按置顶时间排序；已被删除的消息和当前用户不可读频道中的消息不返回
+func (s *GroupService) ListPins(ctx context.Context, groupID, userID string) ([]model.GroupPin, error) {
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(group.Pins) == 0 {
+		return []model.GroupPin{}, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(group.Pins))
+	for _, pin := range group.Pins {
+		ids = append(ids, pin.MessageID)
+	}
+	channelIDs, err := s.ReadableChannelIDs(ctx, []primitive.ObjectID{group.ID}, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.messageRepo.GetMessages(ctx, bson.M{"_id": bson.M{"$in": ids}, "channel_id": bson.M{"$in": channelIDs}})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]*model.Message, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+
+	pins := make([]model.GroupPin, 0, len(group.Pins))
+	for _, pin := range group.Pins {
+		if pin.Message = byID[pin.MessageID]; pin.Message != nil {
+			pins = append(pins, pin)
+		}
+	}
+	return pins, nil
+}
+
+// loadGroupAsMember 加载群组并校验 userID 是群成员
+func (s *GroupService) loadGroupAsMember(ctx context.Context, groupID, userID string) (*model.Group, primitive.ObjectID, error) {
+	groupObjID, userObjID, err := parseGroupActor(groupID, userID)
+	if err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+	group, err := s.groupRepo.GetGroupByID(ctx, groupObjID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, primitive.NilObjectID, errors.New("group not found")
+		}
+		return nil, primitive.NilObjectID, err
+	}
+	if _, err := s.memberRole(ctx, group, userObjID); err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+	return group, userObjID, nil
+}
+
+// notifyGroupMembers 向除 exceptID 外未屏蔽群通知的群成员发送群组通知，失败只记录日志
+func (s *GroupService) notifyGroupMembers(ctx context.Context, group *model.Group, exceptID primitive.ObjectID, title, content string) {
+	s.notifyChannelReaders(ctx, group, nil, exceptID, title, content)
+}
+
+// notifyChannelReaders 同 notifyGroupMembers，channel 不为 nil 时只通知能读取该频道的成员
+func (s *GroupService) notifyChannelReaders(ctx context.Context, group *model.Group, channel *model.GroupChannel, exceptID primitive.ObjectID, title, content string) {
+	members, err := s.groupRepo.GetGroupMembers(ctx, group.ID)
+	if err != nil {
+		log.Printf("Failed to load members of group %s: %v", group.ID.Hex(), err)
+		return
+	}
+	for _, member := range members {
+		if member.UserID == exceptID || member.NotificationsMuted {
+			continue
+		}
+		if channel != nil && !channel.ReadableBy(member.UserID) {
+			continue
+		}
+		if err := s.notificationService.CreateGroupNotification(ctx, member.UserID, group.ID, title, content); err != nil {
+			log.Printf("Failed to send group notification to %s: %v", member.UserID.Hex(), err)
+		}
+	}
+}
+
+// SetNotificationsMuted 成员屏蔽或恢复群组通知，屏蔽后仍会收到 @ 提及的通知
+func (s *GroupService) SetNotificationsMuted(ctx context.Context, groupID, userID string, muted bool) error {
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	return s.groupRepo.SetNotificationsMuted(ctx, group.ID, userObjID, muted)
+}
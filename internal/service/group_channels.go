@@ -0,0 +1,504 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxGroupChannels      = 50  // 每个群最多的频道数
+	maxChannelTopicLength = 250 // 频道主题的最大字符数
+)
+
+// channelNamePattern 频道名称只能包含小写字母、数字、中划线和下划线，以字母或数字开头
+var channelNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ErrChannelAccessDenied 用户不是私有频道的成员
+var ErrChannelAccessDenied = errors.New("you are not a member of this channel")
+
+// GroupChannelCreate 创建频道请求
+type GroupChannelCreate struct {
+	Name      string   `json:"name" binding:"required"` // 频道名称
+	Topic     string   `json:"topic"`                   // 频道主题
+	Private   bool     `json:"private"`                 // 是否为私有频道
+	MemberIDs []string `json:"member_ids"`              // 私有频道的初始成员，创建者会自动加入
+}
+
+// GroupChannelUpdate 频道修改请求，字段为 nil 表示不修改
+type GroupChannelUpdate struct {
+	Name  *string `json:"name"`  // 频道名称，默认频道不能改名
+	Topic *string `json:"topic"` // 频道主题
+}
+
+// CreateChannel 在群组中创建频道，需要 manage_channels 权限
+func (s *GroupService) CreateChannel(ctx context.Context, groupID, actorID string, req *GroupChannelCreate) (*model.GroupChannel, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	name, err := normalizeChannelName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	topic, err := normalizeChannelTopic(req.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageChannels)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.channelRepo.CountByGroup(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxGroupChannels {
+		return nil, fmt.Errorf("a group can have at most %d channels", maxGroupChannels)
+	}
+
+	channel := &model.GroupChannel{
+		GroupID:   group.ID,
+		Name:      name,
+		Topic:     topic,
+		Private:   req.Private,
+		CreatorID: actorObjID,
+	}
+	if req.Private {
+		members, err := s.filterGroupMembers(ctx, group.ID, req.MemberIDs)
+		if err != nil {
+			return nil, err
+		}
+		channel.Members = []primitive.ObjectID{actorObjID}
+		for _, id := range members {
+			if id != actorObjID {
+				channel.Members = append(channel.Members, id)
+			}
+		}
+	}
+	if err := s.channelRepo.Create(ctx, channel); err != nil {
+		return nil, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionChannelCreated, actorObjID, channel.ID, "")
+	return channel, nil
+}
+
+// ListChannels 获取当前用户在群组中可以读写的频道，并填充每个频道的未读消息数
+func (s *GroupService) ListChannels(ctx context.Context, groupID, userID string) ([]*model.GroupChannel, error) {
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := s.channelRepo.ListReadable(ctx, []primitive.ObjectID{group.ID}, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return channels, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(channels))
+	for _, channel := range channels {
+		ids = append(ids, channel.ID)
+	}
+	counts, err := s.messageRepo.CountUnreadByChannel(ctx, ids, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	unread := make(map[primitive.ObjectID]int64, len(counts))
+	for _, c := range counts {
+		unread[c.ChannelID] = c.Count
+	}
+	for _, channel := range channels {
+		channel.UnreadCount = unread[channel.ID]
+	}
+	return channels, nil
+}
+
+// UpdateChannel 修改频道名称或主题，需要 manage_channels 权限，默认频道不能改名
+func (s *GroupService) UpdateChannel(ctx context.Context, groupID, actorID, channelID string, update *GroupChannelUpdate) (*model.GroupChannel, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	channelObjID, err := parseChannelID(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageChannels)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := s.findChannel(ctx, group.ID, channelObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := bson.M{}
+	if update.Name != nil {
+		name, err := normalizeChannelName(*update.Name)
+		if err != nil {
+			return nil, err
+		}
+		if channel.IsDefault && name != channel.Name {
+			return nil, errors.New("default channel cannot be renamed")
+		}
+		updates["name"] = name
+	}
+	if update.Topic != nil {
+		topic, err := normalizeChannelTopic(*update.Topic)
+		if err != nil {
+			return nil, err
+		}
+		updates["topic"] = topic
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	if err := s.channelRepo.Update(ctx, group.ID, channel.ID, updates); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("channel not found")
+		}
+		return nil, err
+	}
+	if channel, err = s.channelRepo.FindByID(ctx, group.ID, channel.ID); err != nil {
+		return nil, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionChannelUpdated, actorObjID, channel.ID, "")
+	return channel, nil
+}
+
+// DeleteChannel 删除频道及其消息和文件，需要 manage_channels 权限，默认频道不能删除
+func (s *GroupService) DeleteChannel(ctx context.Context, groupID, actorID, channelID string) error {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	channelObjID, err := parseChannelID(channelID)
+	if err != nil {
+		return err
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageChannels)
+	if err != nil {
+		return err
+	}
+	channel, err := s.findChannel(ctx, group.ID, channelObjID)
+	if err != nil {
+		return err
+	}
+	if channel.IsDefault {
+		return errors.New("default channel cannot be deleted")
+	}
+
+	// 先删除频道，避免清理消息期间还有新消息写入
+	if err := s.channelRepo.Delete(ctx, group.ID, channel.ID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("channel not found")
+		}
+		return err
+	}
+	if err := s.fileService.DeleteChannelFiles(ctx, channel.ID); err != nil {
+		log.Printf("Failed to delete files of channel %s: %v", channel.ID.Hex(), err)
+	}
+	if err := s.messageRepo.DeleteByChannelID(ctx, channel.ID); err != nil {
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionChannelDeleted, actorObjID, channel.ID, "")
+	return nil
+}
+
+// AddChannelMembers 将群成员加入私有频道，需要 manage_channels 权限，不是群成员的用户会被忽略
+func (s *GroupService) AddChannelMembers(ctx context.Context, groupID, actorID, channelID string, userIDs []string) (*model.GroupChannel, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	channelObjID, err := parseChannelID(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageChannels)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := s.findChannel(ctx, group.ID, channelObjID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.Private {
+		return nil, errors.New("only private channels have members")
+	}
+
+	members, err := s.filterGroupMembers(ctx, group.ID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("no group members to add")
+	}
+	if err := s.channelRepo.AddMembers(ctx, group.ID, channel.ID, members); err != nil {
+		return nil, err
+	}
+	return s.channelRepo.FindByID(ctx, group.ID, channel.ID)
+}
+
+// RemoveChannelMember 将成员移出私有频道；成员可以自己退出，移出他人需要 manage_channels 权限
+func (s *GroupService) RemoveChannelMember(ctx context.Context, groupID, actorID, channelID, targetID string) error {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	channelObjID, err := parseChannelID(channelID)
+	if err != nil {
+		return err
+	}
+	targetObjID, err := primitive.ObjectIDFromHex(targetID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	var group *model.Group
+	if targetObjID == actorObjID {
+		group, _, err = s.loadGroupAsMember(ctx, groupID, actorID)
+	} else {
+		group, _, err = s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageChannels)
+	}
+	if err != nil {
+		return err
+	}
+	channel, err := s.findChannel(ctx, group.ID, channelObjID)
+	if err != nil {
+		return err
+	}
+	if !channel.Private {
+		return errors.New("only private channels have members")
+	}
+
+	if err := s.channelRepo.RemoveMember(ctx, group.ID, channel.ID, targetObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("user is not a member of this channel")
+		}
+		return err
+	}
+	return nil
+}
+
+// MarkChannelRead 将频道中当前用户未读的消息全部标记为已读，返回标记的消息数
+func (s *GroupService) MarkChannelRead(ctx context.Context, groupID, userID, channelID string) (int64, error) {
+	channelObjID, err := parseChannelID(channelID)
+	if err != nil {
+		return 0, err
+	}
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return 0, err
+	}
+	channel, err := s.readableChannel(ctx, group, channelObjID, userObjID)
+	if err != nil {
+		return 0, err
+	}
+	return s.messageRepo.MarkChannelAsRead(ctx, channel.ID, userObjID)
+}
+
+// ResolveChannel 返回群成员 userID 可以读写的频道，channelID 为空时返回群组的默认频道
+func (s *GroupService) ResolveChannel(ctx context.Context, groupID, channelID, userID primitive.ObjectID) (*model.GroupChannel, error) {
+	group, err := s.groupRepo.GetGroupByID(ctx, groupID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("group not found")
+		}
+		return nil, err
+	}
+	if _, err := s.memberRole(ctx, group, userID); err != nil {
+		return nil, err
+	}
+	return s.readableChannel(ctx, group, channelID, userID)
+}
+
+// ReadableChannelIDs 返回 userID 在 groupIDs 中可以读写的所有频道 ID
+func (s *GroupService) ReadableChannelIDs(ctx context.Context, groupIDs []primitive.ObjectID, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if len(groupIDs) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+	channels, err := s.channelRepo.ListReadable(ctx, groupIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(channels))
+	for _, channel := range channels {
+		ids = append(ids, channel.ID)
+	}
+	return ids, nil
+}
+
+// ChannelReaderIDs 返回可以读写频道的群成员：公开频道为所有群成员，私有频道为仍在群内的频道成员
+func (s *GroupService) ChannelReaderIDs(ctx context.Context, channel *model.GroupChannel) ([]primitive.ObjectID, error) {
+	if channel.Private {
+		if len(channel.Members) == 0 {
+			return []primitive.ObjectID{}, nil
+		}
+		return s.groupRepo.FilterMembers(ctx, channel.GroupID, channel.Members)
+	}
+
+	members, err := s.groupRepo.GetGroupMembers(ctx, channel.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+	return ids, nil
+}
+
+// FilterChannelReaders 从 userIDs 中筛选出可以读写频道的群成员
+func (s *GroupService) FilterChannelReaders(ctx context.Context, channel *model.GroupChannel, userIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if channel.Private {
+		readable := make([]primitive.ObjectID, 0, len(userIDs))
+		for _, id := range userIDs {
+			if channel.HasMember(id) {
+				readable = append(readable, id)
+			}
+		}
+		userIDs = readable
+	}
+	if len(userIDs) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+	return s.groupRepo.FilterMembers(ctx, channel.GroupID, userIDs)
+}
+
+// MigrateChannels 为还没有频道的群组创建默认频道，并把已有的群消息归入默认频道；已迁移的群组直接跳过
+func (s *GroupService) MigrateChannels(ctx context.Context) error {
+	if err := s.channelRepo.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	groups, err := s.groupRepo.FindWithoutDefaultChannel(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		// 上次迁移中断时默认频道可能已经创建
+		channel, err := s.channelRepo.FindByName(ctx, group.ID, model.DefaultGroupChannelName)
+		if err == mongo.ErrNoDocuments {
+			channel = &model.GroupChannel{
+				GroupID:   group.ID,
+				Name:      model.DefaultGroupChannelName,
+				IsDefault: true,
+				CreatorID: group.GetOwnerID(),
+			}
+			err = s.channelRepo.Create(ctx, channel)
+		}
+		if err != nil {
+			return fmt.Errorf("create default channel for group %s: %v", group.ID.Hex(), err)
+		}
+
+		if err := s.messageRepo.AssignChannel(ctx, group.ID, channel.ID); err != nil {
+			return fmt.Errorf("assign messages of group %s: %v", group.ID.Hex(), err)
+		}
+		if err := s.groupRepo.UpdateGroup(ctx, group.ID, map[string]interface{}{"default_channel_id": channel.ID}); err != nil {
+			return fmt.Errorf("set default channel of group %s: %v", group.ID.Hex(), err)
+		}
+	}
+	if len(groups) > 0 {
+		log.Printf("Migrated %d groups to channels", len(groups))
+	}
+	return nil
+}
+
+// removeFromChannels 成员退群或被移出后将其移出群组的所有私有频道，失败只记录日志
+func (s *GroupService) removeFromChannels(ctx context.Context, groupID, userID primitive.ObjectID) {
+	if err := s.channelRepo.RemoveUserFromGroup(ctx, groupID, userID); err != nil {
+		log.Printf("Failed to remove user %s from channels of group %s: %v", userID.Hex(), groupID.Hex(), err)
+	}
+}
+
+// readableChannel 加载频道并校验 userID 可以读写，channelID 为空时返回群组的默认频道
+func (s *GroupService) readableChannel(ctx context.Context, group *model.Group, channelID, userID primitive.ObjectID) (*model.GroupChannel, error) {
+	if channelID.IsZero() {
+		channelID = group.DefaultChannelID
+	}
+	channel, err := s.findChannel(ctx, group.ID, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.ReadableBy(userID) {
+		return nil, ErrChannelAccessDenied
+	}
+	return channel, nil
+}
+
+// findChannel 加载群组中的频道
+func (s *GroupService) findChannel(ctx context.Context, groupID, channelID primitive.ObjectID) (*model.GroupChannel, error) {
+	channel, err := s.channelRepo.FindByID(ctx, groupID, channelID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("channel not found")
+		}
+		return nil, err
+	}
+	return channel, nil
+}
+
+// filterGroupMembers 解析用户 ID 列表并只保留群成员
+func (s *GroupService) filterGroupMembers(ctx context.Context, groupID primitive.ObjectID, userIDs []string) ([]primitive.ObjectID, error) {
+	if len(userIDs) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(userIDs))
+	for _, id := range userIDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %s", id)
+		}
+		ids = append(ids, objID)
+	}
+	return s.groupRepo.FilterMembers(ctx, groupID, ids)
+}
+
+// parseChannelID 解析频道 ID
+func parseChannelID(channelID string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(channelID)
+	if err != nil {
+		return primitive.NilObjectID, errors.New("invalid channel ID")
+	}
+	return id, nil
+}
+
+// normalizeChannelName 统一频道名称为小写并校验格式
+func normalizeChannelName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !channelNamePattern.MatchString(name) {
+		return "", errors.New("channel name must be 1-32 lowercase letters, digits, '-' or '_'")
+	}
+	return name, nil
+}
+
+// normalizeChannelTopic 去掉频道主题首尾空白并校验长度
+func normalizeChannelTopic(topic string) (string, error) {
+	topic = strings.TrimSpace(topic)
+	if utf8.RuneCountInString(topic) > maxChannelTopicLength {
+		return "", fmt.Errorf("topic must be at most %d characters", maxChannelTopicLength)
+	}
+	return topic, nil
+}
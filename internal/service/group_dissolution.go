@@ -0,0 +1,161 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrGroupDissolved 群组已解散，只能查看和导出历史
+var ErrGroupDissolved = errors.New("group has been dissolved")
+
+// GroupExport 群聊历史导出内容
+type GroupExport struct {
+	Group         *model.Group               `json:"group"`
+	Members       []*model.GroupMember       `json:"members"`
+	Announcements []*model.GroupAnnouncement `json:"announcements"`
+	Channels      []*model.GroupChannel      `json:"channels"`
+	Messages      []*model.Message           `json:"messages"`
+	ExportedAt    time.Time                  `json:"exported_at"`
+}
+
+// DissolveGroup 解散群组，只有群主可以操作；解散后群组只读，成员仍可查看和导出历史
+// purge 为 true 时保留期结束后自动删除群组及其消息和文件
+func (s *GroupService) DissolveGroup(ctx context.Context, groupID, actorID string, purge bool) (*model.Group, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	group, err := s.groupRepo.GetGroupByID(ctx, groupObjID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("group not found")
+		}
+		return nil, err
+	}
+	if group.GetOwnerID() != actorObjID {
+		return nil, ErrGroupPermissionDenied
+	}
+
+	var purgeAt *time.Time
+	if purge {
+		t := time.Now().Add(s.dissolveRetention)
+		purgeAt = &t
+	}
+	if err := s.groupRepo.Dissolve(ctx, group.ID, purgeAt); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrGroupDissolved
+		}
+		return nil, err
+	}
+	if group, err = s.groupRepo.GetGroupByID(ctx, group.ID); err != nil {
+		return nil, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionDissolved, actorObjID, primitive.NilObjectID, "")
+	content := fmt.Sprintf("群聊「%s」已被群主解散，聊天记录仍可查看和导出", group.Name)
+	if purgeAt != nil {
+		content = fmt.Sprintf("群聊「%s」已被群主解散，聊天记录将于 %s 删除，请及时导出", group.Name, purgeAt.Format("2006-01-02"))
+	}
+	s.notifyGroupMembers(ctx, group, actorObjID, "群聊已解散", content)
+	return group, nil
+}
+
+// ExportGroupHistory 导出群资料、成员、公告以及当前用户可读频道的全部消息，群成员在解散前后都可以导出
+func (s *GroupService) ExportGroupHistory(ctx context.Context, groupID, userID string) (*GroupExport, error) {
+	group, userObjID, err := s.loadGroupAsMember(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.groupRepo.GetGroupMembers(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	announcements, err := s.announcementRepo.ListByGroup(ctx, group.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := s.channelRepo.ListReadable(ctx, []primitive.ObjectID{group.ID}, userObjID)
+	if err != nil {
+		return nil, err
+	}
+	channelIDs := make([]primitive.ObjectID, 0, len(channels))
+	for _, channel := range channels {
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	messages, err := s.messageRepo.GetMessages(ctx, bson.M{"group_id": group.ID, "channel_id": bson.M{"$in": channelIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupExport{
+		Group:         group,
+		Members:       members,
+		Announcements: announcements,
+		Channels:      channels,
+		Messages:      messages,
+		ExportedAt:    time.Now(),
+	}, nil
+}
+
+// RunPurgeWorker 定期清理保留期已结束的已解散群组和没有成员的群组，应在独立的 goroutine 中运行
+func (s *GroupService) RunPurgeWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.PurgeDueGroups(context.Background())
+	}
+}
+
+// PurgeDueGroups 清理所有需要清理的群组
+func (s *GroupService) PurgeDueGroups(ctx context.Context) {
+	groups, err := s.groupRepo.FindDueForPurge(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to find groups due for purge: %v", err)
+		return
+	}
+
+	for _, group := range groups {
+		if err := s.purgeGroup(ctx, group); err != nil {
+			// 失败的群组保持原状，下一轮会重试
+			log.Printf("Failed to purge group %s: %v", group.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Group %s purged", group.ID.Hex())
+	}
+}
+
+// purgeGroup 删除群组的文件、消息、邀请、入群申请和公告，最后删除群组和成员记录
+func (s *GroupService) purgeGroup(ctx context.Context, group *model.Group) error {
+	if err := s.fileService.DeleteGroupFiles(ctx, group.ID); err != nil {
+		return fmt.Errorf("delete files: %v", err)
+	}
+	if !group.AvatarFileID.IsZero() {
+		if err := s.fileService.DeleteFileByID(ctx, group.AvatarFileID); err != nil && err != mongo.ErrNoDocuments {
+			return fmt.Errorf("delete avatar: %v", err)
+		}
+	}
+
+	if err := s.messageRepo.DeleteByGroupID(ctx, group.ID); err != nil {
+		return fmt.Errorf("delete messages: %v", err)
+	}
+	if err := s.inviteRepo.DeleteByGroup(ctx, group.ID); err != nil {
+		return fmt.Errorf("delete invites: %v", err)
+	}
+	if err := s.joinRepo.DeleteByGroup(ctx, group.ID); err != nil {
+		return fmt.Errorf("delete join requests: %v", err)
+	}
+	if err := s.announcementRepo.DeleteByGroup(ctx, group.ID); err != nil {
+		return fmt.Errorf("delete announcements: %v", err)
+	}
+	return s.groupRepo.Delete(ctx, group.ID)
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	directInviteTTL   = 7 * 24 * time.Hour   // 直接邀请好友的有效期
+	maxInviteLinkTTL  = 365 * 24 * time.Hour // 邀请链接最长有效期
+	maxInviteLinkUses = 100000               // 邀请链接最多使用次数
+)
+
+// InviteLinkOptions 创建邀请链接的参数，零值表示永不过期、不限次数
+type InviteLinkOptions struct {
+	ExpiresIn time.Duration // 有效期
+	MaxUses   int           // 最多使用次数
+}
+
+// InviteResult 直接邀请好友的结果
+type InviteResult struct {
+	Invited []string `json:"invited"` // 已发出邀请的用户 ID
+	Failed  []string `json:"failed"`  // 未能邀请的用户 ID（不是好友、已在群中或对方不允许被拉进群）
+}
+
+// CreateInviteLink 创建邀请链接，需要 manage_joins 权限
+func (s *GroupService) CreateInviteLink(ctx context.Context, groupID, actorID string, opts InviteLinkOptions) (*model.GroupInvite, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ExpiresIn < 0 || opts.ExpiresIn > maxInviteLinkTTL {
+		return nil, errors.New("invalid expiry")
+	}
+	if opts.MaxUses < 0 || opts.MaxUses > maxInviteLinkUses {
+		return nil, errors.New("invalid max uses")
+	}
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageJoins)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureNotFull(group); err != nil {
+		return nil, err
+	}
+
+	invite := &model.GroupInvite{
+		GroupID:   groupObjID,
+		CreatorID: actorObjID,
+		MaxUses:   opts.MaxUses,
+	}
+	if opts.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(opts.ExpiresIn)
+		invite.ExpiresAt = &expiresAt
+	}
+	if invite.Code, err = generateInviteCode(); err != nil {
+		return nil, err
+	}
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// ListInviteLinks 获取群组中未撤销的邀请链接，需要 manage_joins 权限
+func (s *GroupService) ListInviteLinks(ctx context.Context, groupID, actorID string) ([]*model.GroupInvite, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageJoins); err != nil {
+		return nil, err
+	}
+	return s.inviteRepo.ListLinksByGroup(ctx, groupObjID)
+}
+
+// RevokeInvite 撤销邀请，需要 manage_joins 权限
+func (s *GroupService) RevokeInvite(ctx context.Context, groupID, actorID, inviteID string) error {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	inviteObjID, err := primitive.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return errors.New("invalid invite ID")
+	}
+	if _, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageJoins); err != nil {
+		return err
+	}
+
+	if err := s.inviteRepo.Revoke(ctx, groupObjID, inviteObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("invite not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// InviteFriends 直接邀请好友入群，每个好友会收到一条带邀请码的群组通知
+// 只能邀请自己的好友，对方已在群中或隐私设置不允许时计入失败
+func (s *GroupService) InviteFriends(ctx context.Context, groupID, actorID string, userIDs []string) (*InviteResult, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermInvite)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureNotFull(group); err != nil {
+		return nil, err
+	}
+
+	result := &InviteResult{Invited: []string{}, Failed: []string{}}
+	for _, userID := range userIDs {
+		if err := s.inviteFriend(ctx, group, actorObjID, userID); err != nil {
+			result.Failed = append(result.Failed, userID)
+			continue
+		}
+		result.Invited = append(result.Invited, userID)
+	}
+	return result, nil
+}
+
+// inviteFriend 为单个好友创建一次性邀请并发送通知
+func (s *GroupService) inviteFriend(ctx context.Context, group *model.Group, actorID primitive.ObjectID, userID string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+	isFriend, err := s.friendshipRepo.Exists(ctx, actorID, userObjID)
+	if err != nil {
+		return err
+	}
+	if !isFriend {
+		return errors.New("user is not your friend")
+	}
+	if !s.privacyService.CanAddToGroup(ctx, actorID, userObjID) {
+		return ErrActionNotAllowed
+	}
+	if err := s.ensureNotMember(ctx, group.ID, userObjID); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(directInviteTTL)
+	invite := &model.GroupInvite{
+		GroupID:   group.ID,
+		CreatorID: actorID,
+		InviteeID: userObjID,
+		ExpiresAt: &expiresAt,
+		MaxUses:   1,
+	}
+	if invite.Code, err = generateInviteCode(); err != nil {
+		return err
+	}
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return err
+	}
+
+	notification := &model.Notification{
+		Type:       model.GroupNotification,
+		Title:      "群聊邀请",
+		Content:    fmt.Sprintf("邀请你加入群聊「%s」", group.Name),
+		UserID:     userObjID,
+		SenderID:   actorID,
+		GroupID:    group.ID,
+		InviteCode: invite.Code,
+	}
+	if err := s.notificationService.CreateNotification(ctx, notification); err != nil {
+		log.Printf("Failed to send group invite notification: %v", err)
+	}
+	return nil
+}
+
+// AcceptInvite 通过邀请码加入群组
+// 群组开启入群审核且邀请人不是管理员时只创建入群申请并返回该申请；直接入群时返回 nil
+func (s *GroupService) AcceptInvite(ctx context.Context, code, userID string) (*model.GroupJoinRequest, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	invite, err := s.inviteRepo.FindByCode(ctx, code)
+	if err != nil || (!invite.InviteeID.IsZero() && invite.InviteeID != userObjID) {
+		return nil, errors.New("invite not found")
+	}
+	if !invite.Usable(time.Now()) {
+		return nil, repository.ErrGroupInviteUnavailable
+	}
+
+	group, err := s.groupRepo.GetGroupByID(ctx, invite.GroupID)
+	if err != nil {
+		return nil, errors.New("group not found")
+	}
+	if group.Dissolved() {
+		return nil, ErrGroupDissolved
+	}
+	if err := s.ensureNotMember(ctx, group.ID, userObjID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureNotFull(group); err != nil {
+		return nil, err
+	}
+
+	// 邀请人已不在群中或已被降级时，按当前角色判断是否需要审核
+	inviterRole, err := s.memberRole(ctx, group, invite.CreatorID)
+	if err != nil && err != ErrNotGroupMember {
+		return nil, err
+	}
+
+	// 已有待审核的申请时直接返回，不再消耗邀请
+	needsApproval := group.ApprovalRequired && !model.GroupRoleCan(inviterRole, model.GroupPermManageJoins)
+	if needsApproval {
+		if existing, err := s.joinRepo.FindPending(ctx, group.ID, userObjID); err == nil {
+			return existing, nil
+		}
+	}
+
+	if err := s.inviteRepo.Consume(ctx, invite.ID); err != nil {
+		return nil, err
+	}
+	var request *model.GroupJoinRequest
+	if needsApproval {
+		request, err = s.createJoinRequest(ctx, group, userObjID, invite.CreatorID)
+	} else {
+		err = s.addMember(ctx, group, invite.CreatorID, userObjID)
+	}
+	if err != nil {
+		// 入群失败（如群已满）时归还使用次数，邀请不会因失败的尝试被用完
+		if releaseErr := s.inviteRepo.Release(ctx, invite.ID); releaseErr != nil {
+			log.Printf("Failed to release invite %s: %v", invite.ID.Hex(), releaseErr)
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// createJoinRequest 创建入群申请并通知群主和管理员，已有待审核的申请时直接返回该申请
+func (s *GroupService) createJoinRequest(ctx context.Context, group *model.Group, userID, inviterID primitive.ObjectID) (*model.GroupJoinRequest, error) {
+	if existing, err := s.joinRepo.FindPending(ctx, group.ID, userID); err == nil {
+		return existing, nil
+	}
+
+	request := &model.GroupJoinRequest{
+		GroupID:   group.ID,
+		UserID:    userID,
+		InviterID: inviterID,
+		Status:    model.GroupJoinPending,
+	}
+	if err := s.joinRepo.Create(ctx, request); err != nil {
+		return nil, err
+	}
+
+	members, err := s.GetGroupMembers(ctx, group.ID.Hex())
+	if err != nil {
+		log.Printf("Failed to load members of group %s: %v", group.ID.Hex(), err)
+		return request, nil
+	}
+	for _, member := range members {
+		if !model.GroupRoleCan(member.Role, model.GroupPermManageJoins) {
+			continue
+		}
+		s.notifyJoinRequest(ctx, request, member.UserID, "入群申请", fmt.Sprintf("有新的用户申请加入群聊「%s」", group.Name))
+	}
+	return request, nil
+}
+
+// ListJoinRequests 获取群组中待审核的入群申请，需要 manage_joins 权限
+func (s *GroupService) ListJoinRequests(ctx context.Context, groupID, actorID string) ([]*model.GroupJoinRequest, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageJoins); err != nil {
+		return nil, err
+	}
+	return s.joinRepo.ListPending(ctx, groupObjID)
+}
+
+// ReviewJoinRequest 通过或拒绝入群申请，需要 manage_joins 权限，结果会通知申请人
+func (s *GroupService) ReviewJoinRequest(ctx context.Context, groupID, actorID, requestID string, approve bool) (*model.GroupJoinRequest, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	requestObjID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, errors.New("invalid request ID")
+	}
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermManageJoins)
+	if err != nil {
+		return nil, err
+	}
+
+	status := model.GroupJoinRejected
+	if approve {
+		status = model.GroupJoinApproved
+	}
+	request, err := s.joinRepo.Review(ctx, groupObjID, requestObjID, actorObjID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if !approve {
+		s.notifyJoinRequest(ctx, request, request.UserID, "入群申请被拒绝", fmt.Sprintf("你加入群聊「%s」的申请被拒绝", group.Name))
+		return request, nil
+	}
+
+	// 申请期间已通过其他方式入群时不再重复添加
+	if err := s.addMember(ctx, group, actorObjID, request.UserID); err != nil {
+		if err == repository.ErrAlreadyGroupMember {
+			return request, nil
+		}
+		return nil, err
+	}
+	s.notifyJoinRequest(ctx, request, request.UserID, "入群申请已通过", fmt.Sprintf("你已加入群聊「%s」", group.Name))
+	return request, nil
+}
+
+// notifyJoinRequest 向 userID 发送与入群申请相关的群组通知
+func (s *GroupService) notifyJoinRequest(ctx context.Context, request *model.GroupJoinRequest, userID primitive.ObjectID, title, content string) {
+	notification := &model.Notification{
+		Type:      model.GroupNotification,
+		Title:     title,
+		Content:   content,
+		UserID:    userID,
+		SenderID:  request.UserID,
+		GroupID:   request.GroupID,
+		RequestID: request.ID,
+	}
+	if err := s.notificationService.CreateNotification(ctx, notification); err != nil {
+		log.Printf("Failed to send join request notification: %v", err)
+	}
+}
+
+// parseGroupActor 将群组 ID 和操作者 ID 转换为 ObjectID
+func parseGroupActor(groupID, actorID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	groupObjID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid group ID")
+	}
+	actorObjID, err := primitive.ObjectIDFromHex(actorID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, errors.New("invalid user ID")
+	}
+	return groupObjID, actorObjID, nil
+}
+
+// generateInviteCode 生成随机的邀请码
+func generateInviteCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
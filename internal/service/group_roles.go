@@ -0,0 +1,339 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/pkg/event"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime/multipart"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrGroupPermissionDenied 当前用户在群组中没有执行该操作的权限
+var ErrGroupPermissionDenied = errors.New("permission denied")
+
+// ErrNotGroupMember 当前用户不是群组成员
+var ErrNotGroupMember = errors.New("not a member of this group")
+
+// GroupInfoUpdate 群资料修改请求，字段为 nil 表示不修改
+type GroupInfoUpdate struct {
+	Name        *string `json:"name"`        // 群名称，1-50 个字符
+	Description *string `json:"description"` // 群描述，最多 500 个字符
+
+	ApprovalRequired *bool `json:"approval_required"` // 入群是否需要管理员审核
+}
+
+// Validate 校验各字段的长度，并去除首尾空白
+func (u *GroupInfoUpdate) Validate() error {
+	for _, v := range []*string{u.Name, u.Description} {
+		if v != nil {
+			*v = strings.TrimSpace(*v)
+		}
+	}
+	if u.Name != nil && *u.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if err := checkLength("name", u.Name, 50); err != nil {
+		return err
+	}
+	return checkLength("description", u.Description, 500)
+}
+
+// memberRole 返回 userID 在群组中的角色，群主以群组记录为准
+func (s *GroupService) memberRole(ctx context.Context, group *model.Group, userID primitive.ObjectID) (string, error) {
+	if group.GetOwnerID() == userID {
+		return model.GroupRoleOwner, nil
+	}
+	member, err := s.groupRepo.GetMember(ctx, group.ID, userID)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrNotGroupMember
+	}
+	if err != nil {
+		return "", err
+	}
+	if member.Role == model.GroupRoleAdmin {
+		return model.GroupRoleAdmin, nil
+	}
+	return model.GroupRoleMember, nil
+}
+
+// Authorize 校验 userID 是群组成员且拥有 perm 权限，返回群组和该用户的角色；群组已解散时返回 ErrGroupDissolved
+func (s *GroupService) Authorize(ctx context.Context, groupID, userID primitive.ObjectID, perm model.GroupPermission) (*model.Group, string, error) {
+	group, err := s.groupRepo.GetGroupByID(ctx, groupID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, "", errors.New("group not found")
+		}
+		return nil, "", err
+	}
+	if group.Dissolved() {
+		return nil, "", ErrGroupDissolved
+	}
+	role, err := s.memberRole(ctx, group, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !model.GroupRoleCan(role, perm) {
+		return nil, "", ErrGroupPermissionDenied
+	}
+	return group, role, nil
+}
+
+// authorizeOnTarget 校验操作者拥有 perm 权限且角色高于被操作成员，返回群组和被操作成员的角色
+func (s *GroupService) authorizeOnTarget(ctx context.Context, groupID, actorID, targetID string, perm model.GroupPermission) (*model.Group, primitive.ObjectID, primitive.ObjectID, string, error) {
+	groupObjID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return nil, primitive.NilObjectID, primitive.NilObjectID, "", errors.New("invalid group ID")
+	}
+	actorObjID, targetObjID, err := parseUserPair(actorID, targetID)
+	if err != nil {
+		return nil, primitive.NilObjectID, primitive.NilObjectID, "", err
+	}
+	if actorObjID == targetObjID {
+		return nil, primitive.NilObjectID, primitive.NilObjectID, "", errors.New("cannot perform this action on yourself")
+	}
+
+	group, actorRole, err := s.Authorize(ctx, groupObjID, actorObjID, perm)
+	if err != nil {
+		return nil, primitive.NilObjectID, primitive.NilObjectID, "", err
+	}
+	targetRole, err := s.memberRole(ctx, group, targetObjID)
+	if err != nil {
+		if err == ErrNotGroupMember {
+			return nil, primitive.NilObjectID, primitive.NilObjectID, "", errors.New("user is not a member of this group")
+		}
+		return nil, primitive.NilObjectID, primitive.NilObjectID, "", err
+	}
+	if !model.GroupRoleOutranks(actorRole, targetRole) {
+		return nil, primitive.NilObjectID, primitive.NilObjectID, "", ErrGroupPermissionDenied
+	}
+	return group, actorObjID, targetObjID, targetRole, nil
+}
+
+// KickMember 将成员移出群组，只能移除角色低于自己的成员
+func (s *GroupService) KickMember(ctx context.Context, groupID, actorID, targetID string) error {
+	group, actorObjID, targetObjID, _, err := s.authorizeOnTarget(ctx, groupID, actorID, targetID, model.GroupPermRemoveMember)
+	if err != nil {
+		return err
+	}
+	if err := s.groupRepo.RemoveMember(ctx, group.ID, targetObjID); err != nil {
+		return err
+	}
+	s.removeFromChannels(ctx, group.ID, targetObjID)
+
+	// 被移除的成员也要收到推送
+	s.publishGroupEvent(ctx, group, model.GroupActionMemberRemoved, actorObjID, targetObjID, "", targetObjID)
+	return nil
+}
+
+// PromoteMember 将普通成员设为管理员，只有群主可以操作
+func (s *GroupService) PromoteMember(ctx context.Context, groupID, actorID, targetID string) error {
+	group, actorObjID, targetObjID, targetRole, err := s.authorizeOnTarget(ctx, groupID, actorID, targetID, model.GroupPermManageAdmins)
+	if err != nil {
+		return err
+	}
+	if targetRole != model.GroupRoleMember {
+		return errors.New("user is already an admin")
+	}
+	if err := s.groupRepo.UpdateMemberRole(ctx, group.ID, targetObjID, model.GroupRoleAdmin); err != nil {
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionAdminPromoted, actorObjID, targetObjID, model.GroupRoleAdmin)
+	return nil
+}
+
+// DemoteAdmin 取消管理员，只有群主可以操作
+func (s *GroupService) DemoteAdmin(ctx context.Context, groupID, actorID, targetID string) error {
+	group, actorObjID, targetObjID, targetRole, err := s.authorizeOnTarget(ctx, groupID, actorID, targetID, model.GroupPermManageAdmins)
+	if err != nil {
+		return err
+	}
+	if targetRole != model.GroupRoleAdmin {
+		return errors.New("user is not an admin")
+	}
+	if err := s.groupRepo.UpdateMemberRole(ctx, group.ID, targetObjID, model.GroupRoleMember); err != nil {
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionAdminDemoted, actorObjID, targetObjID, model.GroupRoleMember)
+	return nil
+}
+
+// TransferOwnership 将群主转让给另一个成员，原群主变为管理员
+func (s *GroupService) TransferOwnership(ctx context.Context, groupID, actorID, targetID string) error {
+	group, actorObjID, targetObjID, _, err := s.authorizeOnTarget(ctx, groupID, actorID, targetID, model.GroupPermManageAdmins)
+	if err != nil {
+		return err
+	}
+
+	if err := s.groupRepo.TransferOwnership(ctx, group.ID, actorObjID, targetObjID); err != nil {
+		return err
+	}
+	group.OwnerID = targetObjID
+
+	s.publishGroupEvent(ctx, group, model.GroupActionOwnerTransferred, actorObjID, targetObjID, model.GroupRoleOwner)
+	return nil
+}
+
+// UpdateGroupInfo 修改群名称、描述和入群审核设置，需要 edit_info 权限，返回修改后的群组
+func (s *GroupService) UpdateGroupInfo(ctx context.Context, groupID, actorID string, update *GroupInfoUpdate) (*model.Group, error) {
+	groupObjID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return nil, errors.New("invalid group ID")
+	}
+	actorObjID, err := primitive.ObjectIDFromHex(actorID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.Name != nil {
+		updates["name"] = *update.Name
+	}
+	if update.Description != nil {
+		updates["description"] = *update.Description
+	}
+	if update.ApprovalRequired != nil {
+		updates["approval_required"] = *update.ApprovalRequired
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermEditInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.groupRepo.UpdateGroup(ctx, groupObjID, updates); err != nil {
+		return nil, err
+	}
+	group, err = s.groupRepo.GetGroupByID(ctx, groupObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionInfoUpdated, actorObjID, primitive.NilObjectID, "")
+	return group, nil
+}
+
+// publishGroupEvent 在群里写入一条系统消息，并推送群组变动事件给当前群成员和 extraRecipients
+// 系统消息和推送失败只记录日志，不影响已完成的操作
+func (s *GroupService) publishGroupEvent(ctx context.Context, group *model.Group, action string, actorID, targetID primitive.ObjectID, role string, extraRecipients ...primitive.ObjectID) {
+	content := model.GroupSystemContent{
+		Action:  action,
+		ActorID: actorID.Hex(),
+		Role:    role,
+	}
+	if !targetID.IsZero() {
+		content.TargetID = targetID.Hex()
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		log.Printf("Failed to encode group system message: %v", err)
+		return
+	}
+
+	message := &model.Message{
+		Type:      model.SystemMessage,
+		Content:   string(data),
+		SenderID:  actorID,
+		GroupID:   group.ID,
+		ChannelID: group.DefaultChannelID,
+		Status:    "sent",
+	}
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		log.Printf("Failed to save group system message for group %s: %v", group.ID.Hex(), err)
+	}
+
+	members, err := s.groupRepo.GetGroupMembers(ctx, group.ID)
+	if err != nil {
+		log.Printf("Failed to load members of group %s: %v", group.ID.Hex(), err)
+		return
+	}
+	recipients := make([]string, 0, len(members)+len(extraRecipients))
+	for _, member := range members {
+		recipients = append(recipients, member.UserID.Hex())
+	}
+	for _, id := range extraRecipients {
+		recipients = append(recipients, id.Hex())
+	}
+
+	s.eventBus.Publish(event.Event{
+		Type: event.GroupUpdated,
+		Content: event.GroupUpdatedContent{
+			GroupID:    group.ID.Hex(),
+			Action:     action,
+			ActorID:    content.ActorID,
+			TargetID:   content.TargetID,
+			Role:       role,
+			Message:    message,
+			Recipients: recipients,
+		},
+	})
+}
+
+// UpdateGroupAvatar 上传并设置群头像，需要 edit_info 权限；旧头像文件会被删除
+func (s *GroupService) UpdateGroupAvatar(ctx context.Context, groupID, actorID string, file *multipart.FileHeader) (*model.Group, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermEditInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar, err := s.fileService.UploadFile(ctx, file, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.setGroupAvatar(ctx, group, actorObjID, avatar)
+}
+
+// RemoveGroupAvatar 清除群头像，需要 edit_info 权限
+func (s *GroupService) RemoveGroupAvatar(ctx context.Context, groupID, actorID string) (*model.Group, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	group, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermEditInfo)
+	if err != nil {
+		return nil, err
+	}
+	if group.Avatar == "" {
+		return nil, errors.New("group has no avatar")
+	}
+	return s.setGroupAvatar(ctx, group, actorObjID, nil)
+}
+
+// setGroupAvatar 保存群头像并删除旧头像文件，发布 avatar_updated 事件，返回修改后的群组
+func (s *GroupService) setGroupAvatar(ctx context.Context, group *model.Group, actorID primitive.ObjectID, avatar *model.File) (*model.Group, error) {
+	previous, err := s.groupRepo.SetAvatar(ctx, group.ID, avatar)
+	if err != nil {
+		if avatar != nil {
+			_ = s.fileService.DeleteFileByID(ctx, avatar.ID)
+		}
+		return nil, err
+	}
+	if !previous.IsZero() {
+		if err := s.fileService.DeleteFileByID(ctx, previous); err != nil {
+			log.Printf("Failed to delete previous avatar of group %s: %v", group.ID.Hex(), err)
+		}
+	}
+
+	group, err = s.groupRepo.GetGroupByID(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishGroupEvent(ctx, group, model.GroupActionAvatarUpdated, actorID, primitive.NilObjectID, "")
+	return group, nil
+}
@@ -3,76 +3,169 @@ package service
 import (
 	"chatweb/internal/model"
 	"chatweb/internal/repository"
+	"chatweb/pkg/event"
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type GroupService struct {
-	groupRepo *repository.GroupRepository
+	groupRepo           *repository.GroupRepository
+	inviteRepo          *repository.GroupInviteRepository       // 群邀请存储库
+	joinRepo            *repository.GroupJoinRepository         // 入群申请存储库
+	announcementRepo    *repository.GroupAnnouncementRepository // 群公告存储库
+	channelRepo         *repository.GroupChannelRepository      // 群频道存储库
+	messageRepo         *repository.MessageRepository           // 消息存储库，用于写入群系统消息
+	friendshipRepo      *repository.FriendshipRepository        // 好友关系存储库，直接邀请只能发给好友
+	eventBus            *event.EventBus                         // 事件总线，用于推送群组变动
+	privacyService      *PrivacyService                         // 隐私服务，用于判断能否把其他用户拉进群
+	notificationService *NotificationService                    // 通知服务，用于发送群邀请、入群申请、公告和置顶通知
+	fileService         *FileService                            // 文件服务，用于保存群头像
+	maxPins             int                                     // 每个频道最多置顶的消息数
+	maxMembers          int                                     // 每个群最多的成员数
+	dissolveRetention   time.Duration                           // 解散群组后保留消息和文件的时长
 }
 
-func NewGroupService(groupRepo *repository.GroupRepository) *GroupService {
+func NewGroupService(
+	groupRepo *repository.GroupRepository,
+	inviteRepo *repository.GroupInviteRepository,
+	joinRepo *repository.GroupJoinRepository,
+	announcementRepo *repository.GroupAnnouncementRepository,
+	channelRepo *repository.GroupChannelRepository,
+	messageRepo *repository.MessageRepository,
+	friendshipRepo *repository.FriendshipRepository,
+	eventBus *event.EventBus,
+	privacyService *PrivacyService,
+	notificationService *NotificationService,
+	fileService *FileService,
+	maxPins int,
+	maxMembers int,
+	dissolveRetention time.Duration,
+) *GroupService {
 	return &GroupService{
-		groupRepo: groupRepo,
+		groupRepo:           groupRepo,
+		inviteRepo:          inviteRepo,
+		joinRepo:            joinRepo,
+		announcementRepo:    announcementRepo,
+		channelRepo:         channelRepo,
+		messageRepo:         messageRepo,
+		friendshipRepo:      friendshipRepo,
+		eventBus:            eventBus,
+		privacyService:      privacyService,
+		notificationService: notificationService,
+		fileService:         fileService,
+		maxPins:             maxPins,
+		maxMembers:          maxMembers,
+		dissolveRetention:   dissolveRetention,
 	}
 }
 
 func (s *GroupService) CreateGroup(ctx context.Context, group *model.Group) error {
-	// 创建群组
-	if err := s.groupRepo.Create(ctx, group); err != nil {
-		return err
-	}
+	// 创建者即群主
+	group.OwnerID = group.CreatorID
 
-	// 添加创建者为群组成员
-	member := &model.GroupMember{
-		GroupID: group.ID,
-		UserID:  group.CreatorID,
-		Role:    "admin",
+	// 创建群组，同时添加创建者为群组成员
+	owner := &model.GroupMember{
+		UserID: group.CreatorID,
+		Role:   model.GroupRoleOwner,
 	}
-
-	return s.groupRepo.AddMember(ctx, member)
+	return s.groupRepo.Create(ctx, group, owner)
 }
 
-func (s *GroupService) JoinGroup(ctx context.Context, groupID string, userID string) error {
+// JoinGroup 将 userID 加入群组，actorID 为发起操作的用户
+// actorID 与 userID 不同时视为拉人进群，需要 actorID 在群内有邀请权限且 userID 的隐私设置允许 actorID
+// 不通过邀请码自行加入时总是只创建入群申请并返回该申请，直接入群需要通过邀请链接（AcceptInvite）；
+// 群组开启入群审核时，没有 manage_joins 权限的成员拉人也只会创建入群申请；直接入群时返回 nil
+func (s *GroupService) JoinGroup(ctx context.Context, groupID string, actorID string, userID string) (*model.GroupJoinRequest, error) {
 	groupObjID, err := primitive.ObjectIDFromHex(groupID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	userObjID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 检查群组是否存在
-	group, err := s.groupRepo.GetGroupByID(ctx, groupObjID)
+	actorObjID, err := primitive.ObjectIDFromHex(actorID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 检查用户是否已经是群组成员
-	members, err := s.groupRepo.GetGroupMembers(ctx, groupObjID)
-	if err != nil {
-		return err
+	// 检查群组是否存在，拉人进群时检查邀请权限
+	var group *model.Group
+	var actorRole string
+	if actorObjID != userObjID {
+		if group, actorRole, err = s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermInvite); err != nil {
+			return nil, err
+		}
+		if !s.privacyService.CanAddToGroup(ctx, actorObjID, userObjID) {
+			return nil, ErrActionNotAllowed
+		}
+	} else if group, err = s.groupRepo.GetGroupByID(ctx, groupObjID); err != nil {
+		return nil, err
+	} else if group.Dissolved() {
+		return nil, ErrGroupDissolved
 	}
 
-	for _, member := range members {
-		if member.UserID == userObjID {
-			return errors.New("user is already a member of this group")
+	// 检查用户是否已经是群组成员、群组是否已满，并发加入时由成员集合的唯一索引和成员数条件更新兜底
+	if err := s.ensureNotMember(ctx, group.ID, userObjID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureNotFull(group); err != nil {
+		return nil, err
+	}
+
+	// 只知道群组 ID 不能直接入群，自行加入需要审核通过
+	selfJoin := actorObjID == userObjID
+	if (selfJoin || group.ApprovalRequired) && !model.GroupRoleCan(actorRole, model.GroupPermManageJoins) {
+		inviterID := primitive.NilObjectID
+		if !selfJoin {
+			inviterID = actorObjID
 		}
+		return s.createJoinRequest(ctx, group, userObjID, inviterID)
 	}
+	return nil, s.addMember(ctx, group, actorObjID, userObjID)
+}
 
-	// 添加新成员
+// ensureNotMember 用户已经是群组成员时返回错误
+func (s *GroupService) ensureNotMember(ctx context.Context, groupID, userID primitive.ObjectID) error {
+	_, err := s.groupRepo.GetMember(ctx, groupID, userID)
+	if err == nil {
+		return repository.ErrAlreadyGroupMember
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+	return nil
+}
+
+// ensureNotFull 群组成员数已达上限时返回 repository.ErrGroupFull
+func (s *GroupService) ensureNotFull(group *model.Group) error {
+	if group.MemberCount >= s.maxMembers {
+		return repository.ErrGroupFull
+	}
+	return nil
+}
+
+// addMember 将用户作为普通成员加入群组，并发布 member_added 事件，actorID 为邀请人或审核人
+func (s *GroupService) addMember(ctx context.Context, group *model.Group, actorID, userID primitive.ObjectID) error {
 	member := &model.GroupMember{
 		GroupID: group.ID,
-		UserID:  userObjID,
-		Role:    "member",
+		UserID:  userID,
+		Role:    model.GroupRoleMember,
 	}
 
-	return s.groupRepo.AddMember(ctx, member)
+	if err := s.groupRepo.AddMember(ctx, member, s.maxMembers); err != nil {
+		return err
+	}
+	s.publishGroupEvent(ctx, group, model.GroupActionMemberAdded, actorID, userID, model.GroupRoleMember)
+	return nil
 }
 
 func (s *GroupService) LeaveGroup(ctx context.Context, groupID string, userID string) error {
@@ -86,7 +179,37 @@ func (s *GroupService) LeaveGroup(ctx context.Context, groupID string, userID st
 		return err
 	}
 
-	return s.groupRepo.RemoveMember(ctx, groupObjID, userObjID)
+	group, err := s.groupRepo.GetGroupByID(ctx, groupObjID)
+	if err != nil {
+		return err
+	}
+	// 群主需要先转让群主才能退出，群组已解散或群主是最后一个成员时除外
+	if group.GetOwnerID() == userObjID && !group.Dissolved() && group.MemberCount > 1 {
+		return errors.New("owner must transfer ownership before leaving the group")
+	}
+
+	if err := s.groupRepo.RemoveMember(ctx, groupObjID, userObjID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrNotGroupMember
+		}
+		return err
+	}
+
+	s.removeFromChannels(ctx, groupObjID, userObjID)
+
+	// 最后一个成员退出后立即清理群组，失败时由定期清理任务重试
+	if group, err = s.groupRepo.GetGroupByID(ctx, groupObjID); err != nil {
+		log.Printf("Failed to reload group %s after member left: %v", groupObjID.Hex(), err)
+		return nil
+	}
+	if group.MemberCount <= 0 {
+		if err := s.purgeGroup(ctx, group); err != nil {
+			log.Printf("Failed to purge empty group %s: %v", groupObjID.Hex(), err)
+		}
+		return nil
+	}
+	s.publishGroupEvent(ctx, group, model.GroupActionMemberLeft, userObjID, userObjID, "", userObjID)
+	return nil
 }
 
 func (s *GroupService) GetUserGroups(ctx context.Context, userID string) ([]*model.Group, error) {
@@ -104,7 +227,25 @@ func (s *GroupService) GetGroupMembers(ctx context.Context, groupID string) ([]*
 		return nil, err
 	}
 
-	return s.groupRepo.GetGroupMembers(ctx, groupObjID)
+	group, err := s.groupRepo.GetGroupByID(ctx, groupObjID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.groupRepo.GetGroupMembers(ctx, groupObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 角色以群主字段为准，兼容创建者被记录为 admin 的历史数据
+	ownerID := group.GetOwnerID()
+	for _, member := range members {
+		if member.UserID == ownerID {
+			member.Role = model.GroupRoleOwner
+		} else if member.Role == model.GroupRoleOwner {
+			member.Role = model.GroupRoleAdmin
+		}
+	}
+	return members, nil
 }
 
 func (s *GroupService) GetGroupByID(ctx context.Context, groupID primitive.ObjectID) (*model.Group, error) {
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxSlowModeSeconds = 3600                // 慢速模式的最大发言间隔
+	minMuteDuration    = time.Minute         // 单个成员禁言的最短时长
+	maxMuteDuration    = 30 * 24 * time.Hour // 单个成员禁言的最长时长
+)
+
+// 群组发言限制的错误码，随错误一起返回给客户端
+const (
+	GroupPostGroupMuted  = "group_muted"  // 群组开启了全员禁言
+	GroupPostMemberMuted = "member_muted" // 发送者被禁言
+	GroupPostSlowMode    = "slow_mode"    // 慢速模式下发言过于频繁
+)
+
+// GroupPostError 因群组发言限制导致消息发送失败
+type GroupPostError struct {
+	Code       string        // 错误码
+	RetryAfter time.Duration // 多久之后可以再次发言，全员禁言时为 0
+}
+
+func (e *GroupPostError) Error() string {
+	switch e.Code {
+	case GroupPostGroupMuted:
+		return "only owner and admins can send messages in this group"
+	case GroupPostMemberMuted:
+		return fmt.Sprintf("you are muted in this group, retry after %d seconds", e.RetryAfterSeconds())
+	default:
+		return fmt.Sprintf("slow mode is enabled, retry after %d seconds", e.RetryAfterSeconds())
+	}
+}
+
+// RetryAfterSeconds 返回向上取整的等待秒数
+func (e *GroupPostError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// GroupSettingsUpdate 群设置修改请求，字段为 nil 表示不修改
+type GroupSettingsUpdate struct {
+	MuteAll         *bool `json:"mute_all"`          // 全员禁言，只有群主和管理员可以发言
+	SlowModeSeconds *int  `json:"slow_mode_seconds"` // 慢速模式的发言间隔秒数，0 表示关闭
+}
+
+// CheckCanPost 校验 userID 能否在群组中发言，群主和管理员不受禁言和慢速模式限制
+// 慢速模式下校验通过即记为一次发言
+func (s *GroupService) CheckCanPost(ctx context.Context, groupID, userID primitive.ObjectID) error {
+	group, err := s.groupRepo.GetGroupByID(ctx, groupID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("group not found")
+		}
+		return err
+	}
+	if group.Dissolved() {
+		return ErrGroupDissolved
+	}
+	if group.GetOwnerID() == userID {
+		return nil
+	}
+	member, err := s.groupRepo.GetMember(ctx, groupID, userID)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotGroupMember
+	}
+	if err != nil {
+		return err
+	}
+	if model.GroupRoleCan(member.Role, model.GroupPermMute) {
+		return nil
+	}
+
+	now := time.Now()
+	if member.MutedUntil != nil && member.MutedUntil.After(now) {
+		return &GroupPostError{Code: GroupPostMemberMuted, RetryAfter: member.MutedUntil.Sub(now)}
+	}
+	if group.MuteAll {
+		return &GroupPostError{Code: GroupPostGroupMuted}
+	}
+	if group.SlowModeSeconds <= 0 {
+		return nil
+	}
+
+	interval := time.Duration(group.SlowModeSeconds) * time.Second
+	ok, err := s.groupRepo.TryPost(ctx, groupID, userID, interval)
+	if err != nil || ok {
+		return err
+	}
+	retry := interval
+	if member.LastPostedAt != nil {
+		retry = member.LastPostedAt.Add(interval).Sub(now)
+	}
+	if retry < time.Second {
+		retry = time.Second
+	}
+	return &GroupPostError{Code: GroupPostSlowMode, RetryAfter: retry}
+}
+
+// UpdateGroupSettings 修改全员禁言和慢速模式，需要 mute 权限，返回修改后的群组
+func (s *GroupService) UpdateGroupSettings(ctx context.Context, groupID, actorID string, update *GroupSettingsUpdate) (*model.Group, error) {
+	groupObjID, actorObjID, err := parseGroupActor(groupID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.MuteAll != nil {
+		updates["mute_all"] = *update.MuteAll
+	}
+	if update.SlowModeSeconds != nil {
+		if *update.SlowModeSeconds < 0 || *update.SlowModeSeconds > maxSlowModeSeconds {
+			return nil, fmt.Errorf("slow_mode_seconds must be between 0 and %d", maxSlowModeSeconds)
+		}
+		updates["slow_mode_seconds"] = *update.SlowModeSeconds
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	if _, _, err := s.Authorize(ctx, groupObjID, actorObjID, model.GroupPermMute); err != nil {
+		return nil, err
+	}
+	if err := s.groupRepo.UpdateGroup(ctx, groupObjID, updates); err != nil {
+		return nil, err
+	}
+	group, err := s.groupRepo.GetGroupByID(ctx, groupObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionSettingsUpdated, actorObjID, primitive.NilObjectID, "")
+	return group, nil
+}
+
+// MuteMember 禁言成员 duration 时长，只能禁言角色低于自己的成员，返回禁言截止时间
+func (s *GroupService) MuteMember(ctx context.Context, groupID, actorID, targetID string, duration time.Duration) (time.Time, error) {
+	if duration < minMuteDuration || duration > maxMuteDuration {
+		return time.Time{}, errors.New("mute duration must be between 1 minute and 30 days")
+	}
+	group, actorObjID, targetObjID, _, err := s.authorizeOnTarget(ctx, groupID, actorID, targetID, model.GroupPermMute)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	until := time.Now().Add(duration)
+	if err := s.groupRepo.SetMemberMute(ctx, group.ID, targetObjID, &until); err != nil {
+		return time.Time{}, err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionMemberMuted, actorObjID, targetObjID, "")
+	return until, nil
+}
+
+// UnmuteMember 解除成员禁言
+func (s *GroupService) UnmuteMember(ctx context.Context, groupID, actorID, targetID string) error {
+	group, actorObjID, targetObjID, _, err := s.authorizeOnTarget(ctx, groupID, actorID, targetID, model.GroupPermMute)
+	if err != nil {
+		return err
+	}
+	if err := s.groupRepo.SetMemberMute(ctx, group.ID, targetObjID, nil); err != nil {
+		return err
+	}
+
+	s.publishGroupEvent(ctx, group, model.GroupActionMemberUnmuted, actorObjID, targetObjID, "")
+	return nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTrackedLogins 记录数超过该值时触发一次全量清理，防止伪造大量账号名撑爆内存
+const maxTrackedLogins = 10000
+
+// LoginThrottledError 表示登录请求因失败次数过多被拒绝
+type LoginThrottledError struct {
+	RetryAfter time.Duration // 需要等待的时长
+	Locked     bool          // 是否处于锁定状态（否则只是退避中）
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed attempts, temporarily locked, retry after %d seconds", int(e.RetryAfter.Seconds())+1)
+	}
+	return fmt.Sprintf("too many failed attempts, retry after %d seconds", int(e.RetryAfter.Seconds())+1)
+}
+
+// loginAttempt 记录某个 key（IP 或账号）的连续失败情况
+type loginAttempt struct {
+	failures    int       // 连续失败次数
+	pending     int       // 已通过 Check、尚未得出结果的尝试数，计入失败上限
+	lastFailure time.Time // 最近一次失败时间
+	lockedUntil time.Time // 锁定截止时间
+}
+
+// LoginGuard 在内存中按 IP 和账号跟踪登录失败次数，实现指数退避和临时锁定
+// 每次通过 Check 的尝试都必须以 RecordFailure 或 RecordSuccess 结束，释放预占的名额
+type LoginGuard struct {
+	mu                 sync.Mutex               // 保护 attempts 的并发访问
+	attempts           map[string]*loginAttempt // key 为 "account:<email>" 或 "ip:<ip>"
+	maxAccountFailures int                      // 账号连续失败多少次后锁定
+	maxIPFailures      int                      // IP 连续失败多少次后锁定
+	backoffBase        time.Duration            // 退避基础时长，每次失败翻倍
+	lockoutDuration    time.Duration            // 锁定时长
+}
+
+// NewLoginGuard 创建一个新的 LoginGuard 实例
+func NewLoginGuard(maxAccountFailures, maxIPFailures int, backoffBase, lockoutDuration time.Duration) *LoginGuard {
+	return &LoginGuard{
+		attempts:           make(map[string]*loginAttempt),
+		maxAccountFailures: maxAccountFailures,
+		maxIPFailures:      maxIPFailures,
+		backoffBase:        backoffBase,
+		lockoutDuration:    lockoutDuration,
+	}
+}
+
+// accountKey 生成账号维度的 key，邮箱不区分大小写
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipKey 生成 IP 维度的 key
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check 检查该账号和 IP 当前是否允许尝试登录，任意一个被限制都会返回 *LoginThrottledError
+// 允许时为账号和 IP 各预占一次尝试：并发的请求在得出结果前就计入失败上限，
+// 账号已有失败记录时同一时间只允许一次尝试，避免并发请求绕过退避和锁定
+func (g *LoginGuard) Check(email, ip string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if len(g.attempts) > maxTrackedLogins {
+		g.sweep(now)
+	}
+
+	var throttled *LoginThrottledError
+	limit := func(err *LoginThrottledError) {
+		if throttled == nil || (err.Locked && !throttled.Locked) ||
+			(err.Locked == throttled.Locked && err.RetryAfter > throttled.RetryAfter) {
+			throttled = err
+		}
+	}
+	keys := []struct {
+		key         string
+		maxFailures int
+		serial      bool // 有失败记录后同一时间只允许一次尝试
+	}{
+		{accountKey(email), g.maxAccountFailures, true},
+		{ipKey(ip), g.maxIPFailures, false},
+	}
+	for _, k := range keys {
+		attempt := g.load(k.key, now)
+		if attempt == nil {
+			continue
+		}
+
+		// 处于锁定期，锁定优先于退避
+		if now.Before(attempt.lockedUntil) {
+			limit(&LoginThrottledError{RetryAfter: attempt.lockedUntil.Sub(now), Locked: true})
+			continue
+		}
+
+		// 处于退避期
+		next := attempt.lastFailure.Add(g.backoff(attempt.failures))
+		if now.Before(next) {
+			limit(&LoginThrottledError{RetryAfter: next.Sub(now)})
+			continue
+		}
+
+		// 进行中的尝试全部失败就会达到上限，或账号已有失败且还有尝试未得出结果，等下一次退避后再试
+		if attempt.failures+attempt.pending >= k.maxFailures ||
+			(k.serial && attempt.failures > 0 && attempt.pending > 0) {
+			limit(&LoginThrottledError{RetryAfter: g.backoff(attempt.failures + 1)})
+		}
+	}
+
+	if throttled != nil {
+		return throttled
+	}
+
+	for _, k := range keys {
+		attempt := g.load(k.key, now)
+		if attempt == nil {
+			attempt = &loginAttempt{}
+			g.attempts[k.key] = attempt
+		}
+		attempt.pending++
+	}
+	return nil
+}
+
+// RecordFailure 记录一次登录失败并释放 Check 预占的尝试，返回账号和 IP 是否因本次失败进入锁定
+func (g *LoginGuard) RecordFailure(email, ip string) (accountLocked, ipLocked bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	accountLocked = g.recordFailure(accountKey(email), g.maxAccountFailures, now)
+	ipLocked = g.recordFailure(ipKey(ip), g.maxIPFailures, now)
+	return accountLocked, ipLocked
+}
+
+// RecordSuccess 登录成功：清除账号的失败记录，并释放 IP 上预占的尝试
+// IP 的失败记录不随之清除，避免攻击者用自己的账号登录来重置计数
+func (g *LoginGuard) RecordSuccess(email, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.attempts, accountKey(email))
+	if attempt := g.attempts[ipKey(ip)]; attempt != nil {
+		attempt.release()
+	}
+}
+
+// ResetAccount 清除账号的失败记录（管理员解锁时调用）
+func (g *LoginGuard) ResetAccount(email string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.attempts, accountKey(email))
+}
+
+// ResetIP 清除 IP 的失败记录
+func (g *LoginGuard) ResetIP(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.attempts, ipKey(ip))
+}
+
+// recordFailure 为单个 key 记录失败，达到阈值且当前未锁定时进入锁定并返回 true
+func (g *LoginGuard) recordFailure(key string, maxFailures int, now time.Time) bool {
+	attempt := g.load(key, now)
+	if attempt == nil {
+		attempt = &loginAttempt{}
+		g.attempts[key] = attempt
+	}
+
+	attempt.release()
+	attempt.failures++
+	attempt.lastFailure = now
+
+	if attempt.failures >= maxFailures && !now.Before(attempt.lockedUntil) {
+		attempt.lockedUntil = now.Add(g.lockoutDuration)
+		return true
+	}
+	return false
+}
+
+// release 释放一次预占的尝试
+func (a *loginAttempt) release() {
+	if a.pending > 0 {
+		a.pending--
+	}
+}
+
+// load 获取 key 的记录，已过期的记录会被清理并返回 nil
+func (g *LoginGuard) load(key string, now time.Time) *loginAttempt {
+	attempt, ok := g.attempts[key]
+	if !ok {
+		return nil
+	}
+
+	// 锁定已结束且距离最后一次失败超过锁定时长，视为重新开始计数
+	if g.expired(attempt, now) {
+		delete(g.attempts, key)
+		return nil
+	}
+	return attempt
+}
+
+// expired 判断记录是否已经失效，还有尝试未得出结果的记录不会失效
+func (g *LoginGuard) expired(attempt *loginAttempt, now time.Time) bool {
+	return attempt.pending == 0 && !now.Before(attempt.lockedUntil) && now.Sub(attempt.lastFailure) > g.lockoutDuration
+}
+
+// sweep 清理所有已经失效的记录
+func (g *LoginGuard) sweep(now time.Time) {
+	for key, attempt := range g.attempts {
+		if g.expired(attempt, now) {
+			delete(g.attempts, key)
+		}
+	}
+}
+
+// backoff 计算第 failures 次失败后的退避时长：base * 2^(failures-1)，不超过锁定时长
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	wait := g.backoffBase
+	for i := 1; i < failures; i++ {
+		wait *= 2
+		if wait >= g.lockoutDuration {
+			return g.lockoutDuration
+		}
+	}
+	return wait
+}
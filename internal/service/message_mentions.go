@@ -0,0 +1,194 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxMentionsPerMessage  = 50               // 单条消息最多解析的 @用户 数
+	maxMentionPageSize     = 50               // 提及列表每页最多返回的数量
+	mentionNotifyPreview   = 100              // 提及通知中消息内容预览的最大字符数
+	mentionNotifyTimeout   = 30 * time.Second // 后台发送提及通知的超时时间
+	mentionAllKeyword      = "all"
+	mentionUsernameCharset = "A-Za-z0-9_.\\-"
+)
+
+// mentionPattern 匹配 @用户名，@ 前不能紧跟用户名字符，避免把邮箱地址当成提及
+var mentionPattern = regexp.MustCompile(`(?:^|[^` + mentionUsernameCharset + `])@([` + mentionUsernameCharset + `]{3,32})`)
+
+// ErrMentionAllDenied 只有群主和管理员可以 @all
+var ErrMentionAllDenied = errors.New("only owner and admins can mention @all")
+
+// parseMentions 从消息内容中解析出被 @ 的用户名（去重，保持出现顺序）以及是否 @all
+func parseMentions(content string) ([]string, bool) {
+	var names []string
+	all := false
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// 句末的点和中划线不算用户名的一部分
+		name := strings.TrimRight(match[1], ".-")
+		if strings.EqualFold(name, mentionAllKeyword) {
+			all = true
+			continue
+		}
+		if len(name) < 3 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, all
+}
+
+// resolveMentions 解析群文本消息中的 @，只保留可以读写 channel 的群成员，结果写入 message.Mentions 和 message.MentionAll
+// 发送者没有 mention_all 权限却 @all 时返回 ErrMentionAllDenied
+func (s *MessageService) resolveMentions(ctx context.Context, message *model.Message, channel *model.GroupChannel) error {
+	message.Mentions = nil
+	message.MentionAll = false
+	if message.Type != model.TextMessage {
+		return nil
+	}
+
+	names, all := parseMentions(message.Content)
+	if all {
+		if _, _, err := s.groupService.Authorize(ctx, message.GroupID, message.SenderID, model.GroupPermMentionAll); err != nil {
+			if err == ErrGroupPermissionDenied {
+				return ErrMentionAllDenied
+			}
+			return err
+		}
+		message.MentionAll = true
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if len(names) > maxMentionsPerMessage {
+		names = names[:maxMentionsPerMessage]
+	}
+
+	users, err := s.userRepo.FindByUsernames(ctx, names)
+	if err != nil {
+		return err
+	}
+	userIDs := make([]primitive.ObjectID, 0, len(users))
+	for _, user := range users {
+		if user.ID != message.SenderID {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	members, err := s.groupService.FilterChannelReaders(ctx, channel, userIDs)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		message.Mentions = members
+	}
+	return nil
+}
+
+// notifyMentions 在后台向被 @ 的成员批量发送高优先级通知，@all 时通知除发送者外的所有频道成员，失败只记录日志
+// 通知的生成不阻塞发送流程，@all 大频道时也不会拖慢消息的发送
+func (s *MessageService) notifyMentions(message *model.Message, channel *model.GroupChannel) {
+	if len(message.Mentions) == 0 && !message.MentionAll {
+		return
+	}
+
+	// 复制一份消息，调用方在发送后继续修改 message 也不会影响后台通知
+	go func(message model.Message) {
+		ctx, cancel := context.WithTimeout(context.Background(), mentionNotifyTimeout)
+		defer cancel()
+
+		recipients := message.Mentions
+		if message.MentionAll {
+			readers, err := s.groupService.ChannelReaderIDs(ctx, channel)
+			if err != nil {
+				log.Printf("Failed to load members of channel %s: %v", channel.ID.Hex(), err)
+				return
+			}
+			recipients = readers
+		}
+
+		userIDs := make([]primitive.ObjectID, 0, len(recipients))
+		for _, userID := range recipients {
+			if userID != message.SenderID {
+				userIDs = append(userIDs, userID)
+			}
+		}
+
+		preview := message.Content
+		if utf8.RuneCountInString(preview) > mentionNotifyPreview {
+			preview = string([]rune(preview)[:mentionNotifyPreview]) + "…"
+		}
+		if err := s.notificationService.CreateMentionNotifications(ctx, userIDs, &message, preview); err != nil {
+			log.Printf("Failed to send mention notifications for message %s: %v", message.ID.Hex(), err)
+		}
+	}(*message)
+}
+
+// GetMentions 分页获取当前用户可读的频道中提及自己的消息，最新的在前
+func (s *MessageService) GetMentions(ctx context.Context, userID string, page, pageSize int) ([]*model.Message, error) {
+	userObjID, channelIDs, err := s.mentionScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(channelIDs) == 0 {
+		return []*model.Message{}, nil
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxMentionPageSize {
+		pageSize = maxMentionPageSize
+	}
+
+	return s.messageRepo.FindMentions(ctx, userObjID, channelIDs, int64((page-1)*pageSize), int64(pageSize))
+}
+
+// GetUnreadMentionCounts 按群组统计提及当前用户的未读消息数，没有未读提及的群组不返回
+func (s *MessageService) GetUnreadMentionCounts(ctx context.Context, userID string) ([]*repository.MentionCount, error) {
+	userObjID, channelIDs, err := s.mentionScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(channelIDs) == 0 {
+		return []*repository.MentionCount{}, nil
+	}
+
+	return s.messageRepo.CountUnreadMentions(ctx, userObjID, channelIDs)
+}
+
+// mentionScope 返回用户 ID 和其当前所在群组中可以读写的频道，提及只统计这些频道
+func (s *MessageService) mentionScope(ctx context.Context, userID string) (primitive.ObjectID, []primitive.ObjectID, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, nil, errors.New("invalid user ID")
+	}
+	groups, err := s.groupService.GetUserGroups(ctx, userID)
+	if err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+
+	groupIDs := make([]primitive.ObjectID, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+	channelIDs, err := s.groupService.ReadableChannelIDs(ctx, groupIDs, userObjID)
+	if err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	return userObjID, channelIDs, nil
+}
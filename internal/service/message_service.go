@@ -4,6 +4,7 @@ import (
 	"chatweb/internal/model"
 	"chatweb/internal/repository"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -16,17 +17,36 @@ import (
 
 // MessageService 提供消息相关的操作服务
 type MessageService struct {
-	messageRepo *repository.MessageRepository // 消息存储库，用于与数据库交互
-	readCache   *ReadStatusCache              // 用于存储消息已读状态的缓存
-	eventBus    *event.EventBus               // 事件总线，用于发布事件
+	messageRepo         *repository.MessageRepository // 消息存储库，用于与数据库交互
+	userRepo            *repository.UserRepository    // 用户存储库，用于把 @用户名 解析为用户
+	readCache           *ReadStatusCache              // 用于存储消息已读状态的缓存
+	eventBus            *event.EventBus               // 事件总线，用于发布事件
+	privacyService      *PrivacyService               // 隐私服务，用于拦截对方不允许的私聊和控制已读回执
+	groupService        *GroupService                 // 群组服务，用于校验群成员身份、禁言和慢速模式
+	notificationService *NotificationService          // 通知服务，用于发送 @ 提及通知
+	fileService         *FileService                  // 文件服务，用于校验图片和文件消息引用的文件
 }
 
 // NewMessageService 创建一个新的 MessageService 实例
-func NewMessageService(messageRepo *repository.MessageRepository, readCache *ReadStatusCache, eventBus *event.EventBus) *MessageService {
+func NewMessageService(
+	messageRepo *repository.MessageRepository,
+	userRepo *repository.UserRepository,
+	readCache *ReadStatusCache,
+	eventBus *event.EventBus,
+	privacyService *PrivacyService,
+	groupService *GroupService,
+	notificationService *NotificationService,
+	fileService *FileService,
+) *MessageService {
 	return &MessageService{
-		messageRepo: messageRepo, // 初始化消息存储库
-		readCache:   readCache,   // 初始化已读缓存
-		eventBus:    eventBus,    // 初始化事件总线
+		messageRepo:         messageRepo,         // 初始化消息存储库
+		userRepo:            userRepo,            // 初始化用户存储库
+		readCache:           readCache,           // 初始化已读缓存
+		eventBus:            eventBus,            // 初始化事件总线
+		privacyService:      privacyService,      // 初始化隐私服务
+		groupService:        groupService,        // 初始化群组服务
+		notificationService: notificationService, // 初始化通知服务
+		fileService:         fileService,         // 初始化文件服务
 	}
 }
 
@@ -37,6 +57,82 @@ func (s *MessageService) CreateMessage(ctx context.Context, message *model.Messa
 	return s.messageRepo.Create(ctx, message) // 将消息存入数据库
 }
 
+// SendMessage 校验并保存一条由用户发送的消息，REST 和 WebSocket 发送都经过这里
+// 私聊时接收者的隐私设置不允许发送者发消息，或双方存在拉黑关系时返回 ErrActionNotAllowed，不透露具体原因
+// 群聊时发送者必须是群成员，未指定频道时发到默认频道，不是私有频道成员时返回 ErrChannelAccessDenied；
+// 被禁言、全员禁言或慢速模式限制时返回 *GroupPostError；消息中的 @ 会被解析并通知被提及的频道成员
+// 图片和文件消息必须通过 file_id 引用发送者本人上传的文件，内容和文件名以文件记录为准
+func (s *MessageService) SendMessage(ctx context.Context, message *model.Message) error {
+	if message.SenderID.IsZero() {
+		return errors.New("invalid sender ID")
+	}
+	if message.Type == model.ImageMessage || message.Type == model.FileMessage {
+		file, err := s.fileService.MessageFile(ctx, message.SenderID, message.FileID, message.Type)
+		if err != nil {
+			return err
+		}
+		message.Content = file.URL
+		message.FileName = file.Name
+	} else {
+		message.FileID = primitive.NilObjectID
+	}
+	var channel *model.GroupChannel
+	if !message.GroupID.IsZero() {
+		var err error
+		if channel, err = s.groupService.ResolveChannel(ctx, message.GroupID, message.ChannelID, message.SenderID); err != nil {
+			return err
+		}
+		message.ChannelID = channel.ID
+		// 先校验 @，避免无效的 @all 占用慢速模式的发言次数
+		if err := s.resolveMentions(ctx, message, channel); err != nil {
+			return err
+		}
+		if err := s.groupService.CheckCanPost(ctx, message.GroupID, message.SenderID); err != nil {
+			return err
+		}
+	} else {
+		if message.ReceiverID.IsZero() {
+			return errors.New("receiver_id or group_id is required")
+		}
+		if !s.privacyService.CanMessage(ctx, message.SenderID, message.ReceiverID) {
+			return ErrActionNotAllowed
+		}
+		message.ChannelID = primitive.NilObjectID
+	}
+
+	now := time.Now()
+	message.Status = "sent"
+	message.CreatedAt = now
+	message.UpdatedAt = now
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		return err
+	}
+	if channel != nil {
+		s.notifyMentions(message, channel)
+	}
+	return nil
+}
+
+// GroupMessageRecipients 返回群消息需要实时推送的用户：消息所在频道的成员，不含发送者
+func (s *MessageService) GroupMessageRecipients(ctx context.Context, message *model.Message) ([]string, error) {
+	channel, err := s.groupService.ResolveChannel(ctx, message.GroupID, message.ChannelID, message.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	readers, err := s.groupService.ChannelReaderIDs(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]string, 0, len(readers))
+	for _, id := range readers {
+		if id != message.SenderID {
+			recipients = append(recipients, id.Hex())
+		}
+	}
+	return recipients, nil
+}
+
 // DeleteMessageById 根据 userId, otherId 和 messageId 删除特定的消息
 func (s *MessageService) DeleteMessageById(ctx context.Context, userId, otherId, messageId string) ([]*model.Message, error) {
 	// 将用户ID和另一个用户ID转换为 ObjectID
@@ -115,17 +211,28 @@ func (s *MessageService) GetAllLastMessages(ctx context.Context, userID string)
 	return s.messageRepo.GetAllLastMessages(ctx, userObjID)
 }
 
-// GetGroupMessages 获取群组消息
-func (s *MessageService) GetGroupMessages(ctx context.Context, groupID string) ([]*model.Message, error) {
-	// 将群组ID转换为 ObjectID
-	groupObjID, err := primitive.ObjectIDFromHex(groupID)
+// GetGroupMessages 获取群组中某个频道的消息，channelID 为空时获取默认频道的消息
+// 只有群成员可以查看，私有频道只有频道成员可以查看
+func (s *MessageService) GetGroupMessages(ctx context.Context, groupID, channelID, userID string) ([]*model.Message, error) {
+	groupObjID, userObjID, err := parseGroupActor(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	channelObjID := primitive.NilObjectID
+	if channelID != "" {
+		if channelObjID, err = parseChannelID(channelID); err != nil {
+			return nil, err
+		}
+	}
+
+	channel, err := s.groupService.ResolveChannel(ctx, groupObjID, channelObjID, userObjID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置查询条件，查找指定群组的消息
-	filter := bson.M{"group_id": groupObjID}
-	return s.messageRepo.GetMessages(ctx, filter) // 查询群组消息
+	// 设置查询条件，查找指定频道的消息
+	filter := bson.M{"group_id": groupObjID, "channel_id": channel.ID}
+	return s.messageRepo.GetMessages(ctx, filter) // 查询频道消息
 }
 
 // UpdateMessageStatus 更新消息状态
@@ -140,6 +247,7 @@ func (s *MessageService) UpdateMessageStatus(ctx context.Context, messageID stri
 }
 
 // MarkMessageAsRead 标记单条消息为已读
+// 只有阅读者允许发送已读回执时才通知消息的发送者
 func (s *MessageService) MarkMessageAsRead(ctx context.Context, messageID string, userID string) error {
 	// 将消息ID和用户ID转换为 ObjectID
 	msgObjID, err := primitive.ObjectIDFromHex(messageID)
@@ -152,18 +260,30 @@ func (s *MessageService) MarkMessageAsRead(ctx context.Context, messageID string
 		return fmt.Errorf("invalid user ID: %v", err)
 	}
 
+	message, err := s.messageRepo.GetByID(ctx, msgObjID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.messageRepo.MarkAsRead(ctx, msgObjID, userObjID); err != nil { // 更新消息为已读
+		return err
+	}
+
 	// 发布消息已读事件
-	s.eventBus.Publish(event.Event{
-		Type: event.MessageRead,
-		Content: event.MessageReadContent{
-			MessageID: messageID,
-			UserID:    userID,
-			ReadAt:    time.Now().Format(time.RFC3339),
-			IsGroup:   false,
-		},
-	})
+	if message.SenderID != userObjID && s.privacyService.SendsReadReceipts(ctx, userObjID) {
+		s.eventBus.Publish(event.Event{
+			Type: event.MessageRead,
+			Content: event.MessageReadContent{
+				MessageID:  messageID,
+				UserID:     userID,
+				ReadAt:     time.Now().Format(time.RFC3339),
+				IsGroup:    false,
+				Recipients: []string{message.SenderID.Hex()},
+			},
+		})
+	}
 
-	return s.messageRepo.MarkAsRead(ctx, msgObjID, userObjID) // 更新消息为已读
+	return nil
 }
 
 // MarkMessagesAsRead 批量标记消息为已读
@@ -198,36 +318,53 @@ func (s *MessageService) GetUnreadMessages(ctx context.Context, userID string) (
 	return s.messageRepo.GetUnreadMessages(ctx, userObjID) // 查询未读消息
 }
 
-// GetGroupUnreadMessages 获取群组中用户未读的消息
-func (s *MessageService) GetGroupUnreadMessages(ctx context.Context, groupID string, userID string) ([]*model.Message, error) {
-	// 将群组ID和用户ID转换为 ObjectID
-	groupObjID, err := primitive.ObjectIDFromHex(groupID)
+// GetGroupUnreadMessages 获取群组中用户未读的消息，channelID 为空时统计用户可读的所有频道
+func (s *MessageService) GetGroupUnreadMessages(ctx context.Context, groupID, channelID, userID string) ([]*model.Message, error) {
+	groupObjID, userObjID, channelIDs, err := s.unreadScope(ctx, groupID, channelID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid group ID: %v", err)
+		return nil, err
 	}
 
-	userObjID, err := primitive.ObjectIDFromHex(userID)
+	return s.messageRepo.GetGroupUnreadMessages(ctx, groupObjID, channelIDs, userObjID) // 查询群组未读消息
+}
+
+// GetGroupUnreadCount 获取群组中用户未读消息的数量，channelID 为空时统计用户可读的所有频道
+func (s *MessageService) GetGroupUnreadCount(ctx context.Context, groupID, channelID, userID string) (int64, error) {
+	groupObjID, userObjID, channelIDs, err := s.unreadScope(ctx, groupID, channelID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %v", err)
+		return 0, err
 	}
 
-	return s.messageRepo.GetGroupUnreadMessages(ctx, groupObjID, userObjID) // 查询群组未读消息
+	return s.messageRepo.GetGroupUnreadCount(ctx, groupObjID, channelIDs, userObjID) // 获取群组未读消息数量
 }
 
-// GetGroupUnreadCount 获取群组中用户未读消息的数量
-func (s *MessageService) GetGroupUnreadCount(ctx context.Context, groupID string, userID string) (int64, error) {
-	// 将群组ID和用户ID转换为 ObjectID
-	groupObjID, err := primitive.ObjectIDFromHex(groupID)
+// unreadScope 校验群成员身份，返回统计未读消息的频道：指定频道时只含该频道，否则为用户可读的所有频道
+func (s *MessageService) unreadScope(ctx context.Context, groupID, channelID, userID string) (primitive.ObjectID, primitive.ObjectID, []primitive.ObjectID, error) {
+	groupObjID, userObjID, err := parseGroupActor(groupID, userID)
 	if err != nil {
-		return 0, fmt.Errorf("invalid group ID: %v", err)
+		return primitive.NilObjectID, primitive.NilObjectID, nil, err
+	}
+	channelObjID := primitive.NilObjectID
+	if channelID != "" {
+		if channelObjID, err = parseChannelID(channelID); err != nil {
+			return primitive.NilObjectID, primitive.NilObjectID, nil, err
+		}
 	}
 
-	userObjID, err := primitive.ObjectIDFromHex(userID)
+	// 未指定频道时解析默认频道，用于校验群成员身份
+	channel, err := s.groupService.ResolveChannel(ctx, groupObjID, channelObjID, userObjID)
 	if err != nil {
-		return 0, fmt.Errorf("invalid user ID: %v", err)
+		return primitive.NilObjectID, primitive.NilObjectID, nil, err
+	}
+	if channelID != "" {
+		return groupObjID, userObjID, []primitive.ObjectID{channel.ID}, nil
 	}
 
-	return s.messageRepo.GetGroupUnreadCount(ctx, groupObjID, userObjID) // 获取群组未读消息数量
+	channelIDs, err := s.groupService.ReadableChannelIDs(ctx, []primitive.ObjectID{groupObjID}, userObjID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, nil, err
+	}
+	return groupObjID, userObjID, channelIDs, nil
 }
 
 // GetMessageReadStatus 获取消息的已读状态
@@ -296,12 +433,27 @@ func (s *MessageService) MarkGroupMessageAsRead(ctx context.Context, messageID s
 		return err
 	}
 
-	// 获取已读用户列表
-	readBy := make([]string, len(message.ReadBy)+1)
-	for i, receipt := range message.ReadBy {
-		readBy[i] = receipt.UserID.Hex()
+	// 阅读者关闭了已读回执时不通知发送者
+	if message.SenderID == userObjID || !s.privacyService.SendsReadReceipts(ctx, userObjID) {
+		return nil
+	}
+
+	// 获取已读用户列表，去掉关闭了已读回执的用户
+	readByIDs := make([]primitive.ObjectID, 0, len(message.ReadBy)+1)
+	for _, receipt := range message.ReadBy {
+		if receipt.UserID != userObjID {
+			readByIDs = append(readByIDs, receipt.UserID)
+		}
+	}
+	readByIDs = append(readByIDs, userObjID)
+	readByIDs, err = s.privacyService.FilterReadReceipts(ctx, readByIDs)
+	if err != nil {
+		return err
+	}
+	readBy := make([]string, len(readByIDs))
+	for i, id := range readByIDs {
+		readBy[i] = id.Hex()
 	}
-	readBy[len(readBy)-1] = userID
 
 	// 发布群组消息已读事件
 	s.eventBus.Publish(event.Event{
@@ -313,6 +465,7 @@ func (s *MessageService) MarkGroupMessageAsRead(ctx context.Context, messageID s
 			ReadAt:     time.Now().Format(time.RFC3339),
 			ReadCount:  len(readBy),
 			ReadBy:     readBy,
+			Recipients: []string{message.SenderID.Hex()},
 		},
 	})
 
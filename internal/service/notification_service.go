@@ -50,6 +50,36 @@ func (s *NotificationService) CreateGroupNotification(ctx context.Context, userI
 	return s.CreateNotification(ctx, notification) // 调用 CreateNotification 创建通知
 }
 
+// CreateMentionNotifications 批量创建高优先级的 @ 提及通知，不受群消息免打扰影响
+func (s *NotificationService) CreateMentionNotifications(ctx context.Context, userIDs []primitive.ObjectID, message *model.Message, content string) error {
+	notifications := make([]*model.Notification, len(userIDs))
+	for i, userID := range userIDs {
+		notifications[i] = &model.Notification{
+			Type:      model.MentionNotification,
+			Title:     "有人@了你",
+			Content:   content,
+			UserID:    userID,
+			SenderID:  message.SenderID,
+			GroupID:   message.GroupID,
+			MessageID: message.ID,
+			Priority:  model.NotificationPriorityHigh,
+		}
+	}
+
+	if err := s.notificationRepo.CreateMany(ctx, notifications); err != nil {
+		return err
+	}
+
+	for _, notification := range notifications {
+		s.eventBus.Publish(event.Event{
+			Type:    event.Notification,
+			Content: notification,
+		})
+	}
+
+	return nil
+}
+
 // CreateSystemNotification 创建一个新的系统通知
 func (s *NotificationService) CreateSystemNotification(ctx context.Context, userID primitive.ObjectID, title, content string) error {
 	notification := &model.Notification{
@@ -136,3 +166,30 @@ func (s *NotificationService) CreateNotification(ctx context.Context, notificati
 
 	return nil
 }
+
+// BroadcastSystemNotification 向一批用户发送同一条系统通知，返回发送的数量
+func (s *NotificationService) BroadcastSystemNotification(ctx context.Context, userIDs []primitive.ObjectID, title, content string) (int, error) {
+	notifications := make([]*model.Notification, len(userIDs))
+	for i, userID := range userIDs {
+		notifications[i] = &model.Notification{
+			Type:    model.SystemNotification,
+			Title:   title,
+			Content: content,
+			UserID:  userID,
+		}
+	}
+
+	if err := s.notificationRepo.CreateMany(ctx, notifications); err != nil {
+		return 0, err
+	}
+
+	// 逐条发布通知事件，由 WebSocket Hub 推送给在线的接收者
+	for _, notification := range notifications {
+		s.eventBus.Publish(event.Event{
+			Type:    event.Notification,
+			Content: notification,
+		})
+	}
+
+	return len(notifications), nil
+}
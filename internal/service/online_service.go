@@ -19,14 +19,17 @@ type OnlineService struct {
 	onlineUsers sync.Map
 	// eventBus 事件总线，用于发布用户上线/下线事件
 	eventBus *event.EventBus
+	// privacyService 隐私服务，按用户的隐私设置和拉黑关系决定谁能看到其在线状态
+	privacyService *PrivacyService
 }
 
 // NewOnlineService 创建一个新的 OnlineService 实例
-func NewOnlineService(userRepo *repository.UserRepository, eventBus *event.EventBus) *OnlineService {
+func NewOnlineService(userRepo *repository.UserRepository, eventBus *event.EventBus, privacyService *PrivacyService) *OnlineService {
 	return &OnlineService{
-		userRepo:    userRepo,
-		onlineUsers: sync.Map{}, // 使用 sync.Map 处理并发
-		eventBus:    eventBus,
+		userRepo:       userRepo,
+		onlineUsers:    sync.Map{}, // 使用 sync.Map 处理并发
+		eventBus:       eventBus,
+		privacyService: privacyService,
 	}
 }
 
@@ -115,3 +118,58 @@ func (s *OnlineService) GetOnlineUsers(ctx context.Context) ([]string, error) {
 	})
 	return onlineUsers, nil
 }
+
+// IsUserOnlineFor 检查 viewerID 能否看到 userID 在线
+// 对方隐私设置不允许 viewerID 查看在线状态，或双方存在拉黑关系时总是显示离线
+func (s *OnlineService) IsUserOnlineFor(ctx context.Context, viewerID, userID string) bool {
+	if !s.IsUserOnline(userID) {
+		return false
+	}
+	viewerObjID, err := primitive.ObjectIDFromHex(viewerID)
+	if err != nil {
+		return false
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false
+	}
+	return s.privacyService.CanSeeLastSeen(ctx, viewerObjID, userObjID)
+}
+
+// GetVisibleOnlineUsers 获取 viewerID 可见的在线用户ID列表，过滤掉不允许其查看在线状态的用户
+func (s *OnlineService) GetVisibleOnlineUsers(ctx context.Context, viewerID string) ([]string, error) {
+	viewerObjID, err := primitive.ObjectIDFromHex(viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	onlineUsers, err := s.GetOnlineUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(onlineUsers))
+	for _, userID := range onlineUsers {
+		if objID, err := primitive.ObjectIDFromHex(userID); err == nil {
+			ids = append(ids, objID)
+		}
+	}
+
+	visible := make([]string, 0, len(ids))
+	if len(ids) == 0 {
+		return visible, nil
+	}
+	users, err := s.userRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	presence, err := s.privacyService.Redact(ctx, viewerObjID, users)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if presence[user.ID] {
+			visible = append(visible, user.ID.Hex())
+		}
+	}
+	return visible, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrivacyService 根据用户的隐私设置判断其他用户能否看到其资料、在线状态，能否给其发消息或拉其进群
+// 存在拉黑关系时所有判断都不通过；查询失败时按不允许处理
+type PrivacyService struct {
+	userRepo       *repository.UserRepository       // 用户存储库，用于读取隐私设置
+	friendshipRepo *repository.FriendshipRepository // 好友关系存储库，用于判断“仅好友”
+	blockService   *BlockService                    // 黑名单服务
+}
+
+// NewPrivacyService 创建一个新的 PrivacyService 实例
+func NewPrivacyService(userRepo *repository.UserRepository, friendshipRepo *repository.FriendshipRepository, blockService *BlockService) *PrivacyService {
+	return &PrivacyService{
+		userRepo:       userRepo,
+		friendshipRepo: friendshipRepo,
+		blockService:   blockService,
+	}
+}
+
+// CanSeeLastSeen 判断 viewerID 能否看到 userID 的在线状态和最后在线时间
+func (s *PrivacyService) CanSeeLastSeen(ctx context.Context, viewerID, userID primitive.ObjectID) bool {
+	return s.check(ctx, viewerID, userID, model.PrivacySettings.LastSeenAudience)
+}
+
+// CanAddToGroup 判断 actorID 能否把 userID 拉进群组
+func (s *PrivacyService) CanAddToGroup(ctx context.Context, actorID, userID primitive.ObjectID) bool {
+	return s.check(ctx, actorID, userID, model.PrivacySettings.GroupAddAudience)
+}
+
+// CanMessage 判断 senderID 能否给 receiverID 发私聊消息
+func (s *PrivacyService) CanMessage(ctx context.Context, senderID, receiverID primitive.ObjectID) bool {
+	return s.check(ctx, senderID, receiverID, model.PrivacySettings.DirectMessageAudience)
+}
+
+// SendsReadReceipts 判断 userID 是否发送已读回执
+func (s *PrivacyService) SendsReadReceipts(ctx context.Context, userID primitive.ObjectID) bool {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return false
+	}
+	return user.Privacy.SendsReadReceipts()
+}
+
+// FilterReadReceipts 从 userIDs 中去掉关闭了已读回执的用户
+func (s *PrivacyService) FilterReadReceipts(ctx context.Context, userIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if len(userIDs) == 0 {
+		return userIDs, nil
+	}
+	users, err := s.userRepo.FindByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	sends := make(map[primitive.ObjectID]bool, len(users))
+	for _, user := range users {
+		sends[user.ID] = user.Privacy.SendsReadReceipts()
+	}
+
+	filtered := make([]primitive.ObjectID, 0, len(userIDs))
+	for _, id := range userIDs {
+		if sends[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// Redact 按 viewerID 的身份隐藏 users 中不允许其看到的资料和最后在线时间（原地修改），
+// 邮箱、手机号等联系方式只有用户本人可以看到
+// 返回 viewerID 可以看到在线状态的用户集合
+func (s *PrivacyService) Redact(ctx context.Context, viewerID primitive.ObjectID, users []*model.User) (map[primitive.ObjectID]bool, error) {
+	blocked, err := s.blockService.BlockedSet(ctx, viewerID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	friendships, err := s.friendshipRepo.GetFriendsList(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	friends := make(map[primitive.ObjectID]bool, len(friendships))
+	for _, f := range friendships {
+		if f.UserID == viewerID {
+			friends[f.FriendID] = true
+		} else {
+			friends[f.UserID] = true
+		}
+	}
+
+	allows := func(user *model.User, audience model.PrivacyAudience) bool {
+		if user.ID == viewerID {
+			return true
+		}
+		if blocked[user.ID.Hex()] {
+			return false
+		}
+		return audienceAllows(audience, friends[user.ID])
+	}
+
+	presence := make(map[primitive.ObjectID]bool, len(users))
+	for _, user := range users {
+		if user.ID != viewerID {
+			redactContact(user)
+		}
+		if allows(user, user.Privacy.LastSeenAudience()) {
+			presence[user.ID] = true
+		} else {
+			user.LastSeen = nil
+		}
+		if !allows(user, user.Privacy.ProfileAudience()) {
+			redactProfile(user)
+		}
+	}
+	return presence, nil
+}
+
+// check 读取 ownerID 的隐私设置，判断其中由 audience 选出的一项是否允许 viewerID
+func (s *PrivacyService) check(ctx context.Context, viewerID, ownerID primitive.ObjectID, audience func(model.PrivacySettings) model.PrivacyAudience) bool {
+	if viewerID == ownerID {
+		return true
+	}
+	if s.blockService.IsBlocked(ctx, viewerID, ownerID) {
+		return false
+	}
+	owner, err := s.userRepo.FindByID(ctx, ownerID)
+	if err != nil {
+		return false
+	}
+
+	a := audience(owner.Privacy)
+	if a != model.AudienceFriends {
+		return audienceAllows(a, false)
+	}
+	isFriend, err := s.friendshipRepo.Exists(ctx, viewerID, ownerID)
+	return err == nil && isFriend
+}
+
+// audienceAllows 判断可见范围是否允许某个用户，isFriend 表示该用户是否为好友
+func audienceAllows(audience model.PrivacyAudience, isFriend bool) bool {
+	switch audience {
+	case model.AudienceEveryone:
+		return true
+	case model.AudienceFriends:
+		return isFriend
+	default:
+		return false
+	}
+}
+
+// redactContact 清除邮箱、手机号等联系方式
+func redactContact(user *model.User) {
+	user.Email = ""
+	user.Phone = ""
+	user.PendingEmail = ""
+}
+
+// redactProfile 清除头像和个人资料，只保留用户名和昵称等识别用户所必需的字段
+func redactProfile(user *model.User) {
+	user.Avatar = ""
+	user.Bio = ""
+	user.StatusText = ""
+	user.Gender = ""
+	user.Birthday = ""
+	user.Region = ""
+}
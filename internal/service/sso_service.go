@@ -0,0 +1,229 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"chatweb/pkg/oidc"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ssoStateTTL         = 10 * time.Minute // 登录流程（state）的有效期
+	maxPendingSSOLogins = 10000            // 未完成的登录流程超过该数量时清理过期记录
+)
+
+// ssoUsernameInvalidChars 生成用户名时需要替换掉的字符
+var ssoUsernameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// ssoLogin 一次进行中的 SSO 登录流程
+type ssoLogin struct {
+	verifier  string    // PKCE code_verifier
+	nonce     string    // ID Token 中应携带的 nonce
+	expiresAt time.Time // 过期时间
+}
+
+// SSOService 处理 OIDC 单点登录：发起授权、处理回调、关联或创建本地账号并签发聊天 token
+type SSOService struct {
+	provider     *oidc.Provider             // OIDC 身份提供方
+	userRepo     *repository.UserRepository // 用户存储库
+	userService  *UserService               // 用户服务，用于签发 token
+	auditService *AuditService              // 审计服务，记录身份关联
+
+	mu     sync.Mutex           // 保护 logins 的并发访问
+	logins map[string]*ssoLogin // 进行中的登录流程，key 为 state
+}
+
+// NewSSOService 创建一个新的 SSOService 实例
+func NewSSOService(provider *oidc.Provider, userRepo *repository.UserRepository, userService *UserService, auditService *AuditService) *SSOService {
+	return &SSOService{
+		provider:     provider,
+		userRepo:     userRepo,
+		userService:  userService,
+		auditService: auditService,
+		logins:       make(map[string]*ssoLogin),
+	}
+}
+
+// StartLogin 发起一次 SSO 登录，返回身份提供方的授权地址和本次登录的 state
+// 调用方需要把 state 与浏览器绑定（如写入 Cookie），回调时一并校验
+func (s *SSOService) StartLogin(ctx context.Context) (authURL, state string, err error) {
+	if state, err = oidc.RandomString(32); err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err = s.provider.AuthCodeURL(ctx, state, nonce, oidc.S256Challenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.logins) > maxPendingSSOLogins {
+		for key, login := range s.logins {
+			if now.After(login.expiresAt) {
+				delete(s.logins, key)
+			}
+		}
+	}
+	s.logins[state] = &ssoLogin{verifier: verifier, nonce: nonce, expiresAt: now.Add(ssoStateTTL)}
+
+	return authURL, state, nil
+}
+
+// HandleCallback 处理身份提供方的回调：校验 state、用授权码换取令牌、校验 ID Token，
+// 然后找到（或关联、创建）本地账号并签发聊天 token
+func (s *SSOService) HandleCallback(ctx context.Context, state, code, ip string) (string, *model.User, error) {
+	login := s.consumeState(state)
+	if login == nil {
+		return "", nil, errors.New("invalid or expired login state")
+	}
+
+	tokens, err := s.provider.Exchange(ctx, code, login.verifier)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims, err := s.provider.VerifyIDToken(ctx, tokens.IDToken, login.nonce)
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, err := s.resolveUser(ctx, claims, ip)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := s.userService.CompleteLogin(ctx, user)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, user, nil
+}
+
+// consumeState 取出并删除 state 对应的登录流程，每个 state 只能使用一次
+func (s *SSOService) consumeState(state string) *ssoLogin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.logins[state]
+	if !ok {
+		return nil
+	}
+	delete(s.logins, state)
+
+	if time.Now().After(login.expiresAt) {
+		return nil
+	}
+	return login
+}
+
+// resolveUser 按外部身份查找本地账号；首次登录时按已验证的邮箱关联已有账号，没有则创建新账号
+func (s *SSOService) resolveUser(ctx context.Context, claims *oidc.IDTokenClaims, ip string) (*model.User, error) {
+	issuer := s.provider.Issuer()
+
+	if user, err := s.userRepo.FindByIdentity(ctx, issuer, claims.Subject); err == nil {
+		if user.DeletedAt != nil {
+			return nil, errors.New("account has been deleted")
+		}
+		return user, nil
+	}
+
+	// 未验证的邮箱可能被他人冒用，不能据此关联或创建账号
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return nil, errors.New("identity provider did not return a verified email")
+	}
+
+	identity := model.ExternalIdentity{
+		Provider: issuer,
+		Subject:  claims.Subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if user, err = s.createUser(ctx, claims, email); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.AddIdentity(ctx, user.ID, identity); err != nil {
+		return nil, err
+	}
+	user.Identities = append(user.Identities, identity)
+
+	s.auditService.Record(ctx, model.AuditIdentityLinked, user.ID, primitive.NilObjectID, ip,
+		fmt.Sprintf("linked %s identity %s", issuer, claims.Subject))
+	return user, nil
+}
+
+// createUser 为首次通过 SSO 登录的用户创建本地账号，SSO 账号没有本地密码
+func (s *SSOService) createUser(ctx context.Context, claims *oidc.IDTokenClaims, email string) (*model.User, error) {
+	username, err := s.uniqueUsername(ctx, claims, email)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Username: username,
+		Email:    email,
+		Nickname: claims.Name,
+		Avatar:   claims.Picture,
+		Role:     model.RoleUser,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	s.userService.RefreshContactHashes(ctx, user)
+
+	log.Printf("Created user %s from SSO login", user.ID.Hex())
+	return user, nil
+}
+
+// uniqueUsername 根据 preferred_username 或邮箱前缀生成不重复的用户名
+func (s *SSOService) uniqueUsername(ctx context.Context, claims *oidc.IDTokenClaims, email string) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	base = ssoUsernameInvalidChars.ReplaceAllString(base, "_")
+	if len(base) > 24 {
+		base = base[:24]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		if _, err := s.userRepo.FindByUsername(ctx, candidate); err != nil {
+			return candidate, nil
+		}
+		suffix, err := oidc.RandomString(4)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + ssoUsernameInvalidChars.ReplaceAllString(suffix, "")
+	}
+	return "", errors.New("failed to generate a unique username")
+}
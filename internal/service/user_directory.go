@@ -0,0 +1,136 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/internal/repository"
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	minDirectoryQueryLength = 2   // 目录搜索关键字的最少字符数，太短的模糊匹配几乎会命中所有用户
+	maxDirectoryQueryLength = 32  // 目录搜索关键字的最多字符数
+	maxDirectoryPageSize    = 50  // 目录搜索每页最多返回的数量
+	maxDirectoryGroupPeers  = 500 // 参与排序的共同群组成员上限
+)
+
+// PublicProfile 对其他用户公开的资料，不包含手机号、邮箱等私人信息
+type PublicProfile struct {
+	ID         primitive.ObjectID `json:"id"`
+	Username   string             `json:"username"`
+	Nickname   string             `json:"nickname"`
+	Avatar     string             `json:"avatar"`
+	Bio        string             `json:"bio"`
+	StatusText string             `json:"status_text"`
+	LastSeen   *time.Time         `json:"last_seen,omitempty"` // 最后在线时间，对方不允许查看时为空
+}
+
+// newPublicProfile 从用户资料中提取公开字段
+func newPublicProfile(user *model.User) *PublicProfile {
+	return &PublicProfile{
+		ID:         user.ID,
+		Username:   user.Username,
+		Nickname:   user.Nickname,
+		Avatar:     user.Avatar,
+		Bio:        user.Bio,
+		StatusText: user.StatusText,
+		LastSeen:   user.LastSeen,
+	}
+}
+
+// DirectoryEntry 用户目录搜索结果中的一项
+type DirectoryEntry struct {
+	*PublicProfile
+	IsFriend     bool `json:"is_friend"`     // 是否为好友
+	SharedGroups int  `json:"shared_groups"` // 共同群组数
+}
+
+// SearchDirectory 按用户名和昵称前缀/模糊搜索用户，好友和共同群组成员排在前面
+// 拉黑双方互相搜索不到，结果只包含对方允许查看的公开资料；返回当前页和总数
+func (s *UserService) SearchDirectory(ctx context.Context, viewerID, query string, page, pageSize int) ([]*DirectoryEntry, int64, error) {
+	viewerObjID, err := primitive.ObjectIDFromHex(viewerID)
+	if err != nil {
+		return nil, 0, errors.New("invalid user ID")
+	}
+
+	query = strings.TrimSpace(query)
+	if n := utf8.RuneCountInString(query); n < minDirectoryQueryLength || n > maxDirectoryQueryLength {
+		return nil, 0, errors.New("query must be 2-32 characters")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxDirectoryPageSize {
+		pageSize = maxDirectoryPageSize
+	}
+
+	// 排除自己和存在拉黑关系的用户
+	blocked, err := s.blockService.BlockedSet(ctx, viewerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	exclude := []primitive.ObjectID{viewerObjID}
+	for id := range blocked {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			exclude = append(exclude, objID)
+		}
+	}
+
+	friendships, err := s.friendshipRepo.GetFriendsList(ctx, viewerObjID)
+	if err != nil {
+		return nil, 0, err
+	}
+	friendIDs := make([]primitive.ObjectID, 0, len(friendships))
+	for _, f := range friendships {
+		if f.UserID == viewerObjID {
+			friendIDs = append(friendIDs, f.FriendID)
+		} else {
+			friendIDs = append(friendIDs, f.UserID)
+		}
+	}
+
+	peers, err := s.groupRepo.SharedGroupCounts(ctx, viewerObjID, exclude, maxDirectoryGroupPeers)
+	if err != nil {
+		return nil, 0, err
+	}
+	peerIDs := make([]primitive.ObjectID, 0, len(peers))
+	sharedGroups := make(map[primitive.ObjectID]int, len(peers))
+	for _, peer := range peers {
+		peerIDs = append(peerIDs, peer.UserID)
+		sharedGroups[peer.UserID] = peer.Count
+	}
+
+	rows, total, err := s.userRepo.SearchDirectory(ctx, repository.DirectorySearch{
+		Query:        query,
+		Exclude:      exclude,
+		FriendIDs:    friendIDs,
+		GroupPeerIDs: peerIDs,
+	}, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 按对方的隐私设置隐藏头像和资料
+	users := make([]*model.User, len(rows))
+	for i, row := range rows {
+		users[i] = &row.User
+	}
+	if _, err := s.privacyService.Redact(ctx, viewerObjID, users); err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]*DirectoryEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, &DirectoryEntry{
+			PublicProfile: newPublicProfile(&row.User),
+			IsFriend:      row.IsFriend,
+			SharedGroups:  sharedGroups[row.ID],
+		})
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrivacyView 返回给用户本人的隐私设置，未设置的项填充为默认值
+type PrivacyView struct {
+	DiscoverableByPhone bool                  `json:"discoverable_by_phone"` // 是否允许通过手机号被发现
+	DiscoverableByEmail bool                  `json:"discoverable_by_email"` // 是否允许通过邮箱被发现
+	LastSeen            model.PrivacyAudience `json:"last_seen"`             // 谁可以看到在线状态和最后在线时间
+	Profile             model.PrivacyAudience `json:"profile"`               // 谁可以看到头像和个人资料
+	GroupAdd            model.PrivacyAudience `json:"group_add"`             // 谁可以拉我进群
+	DirectMessage       model.PrivacyAudience `json:"direct_message"`        // 谁可以给我发私聊消息
+	ReadReceipts        bool                  `json:"read_receipts"`         // 是否发送已读回执
+}
+
+// PrivacyUpdate 隐私设置更新请求，字段为 nil 表示不修改
+// 可见范围的取值为 everyone、friends 或 nobody
+type PrivacyUpdate struct {
+	DiscoverableByPhone *bool                  `json:"discoverable_by_phone"`
+	DiscoverableByEmail *bool                  `json:"discoverable_by_email"`
+	LastSeen            *model.PrivacyAudience `json:"last_seen"`
+	Profile             *model.PrivacyAudience `json:"profile"`
+	GroupAdd            *model.PrivacyAudience `json:"group_add"`
+	DirectMessage       *model.PrivacyAudience `json:"direct_message"`
+	ReadReceipts        *bool                  `json:"read_receipts"`
+}
+
+// newPrivacyView 根据存储的设置生成填充了默认值的视图
+func newPrivacyView(p model.PrivacySettings) *PrivacyView {
+	return &PrivacyView{
+		DiscoverableByPhone: p.IsDiscoverableByPhone(),
+		DiscoverableByEmail: p.IsDiscoverableByEmail(),
+		LastSeen:            p.LastSeenAudience(),
+		Profile:             p.ProfileAudience(),
+		GroupAdd:            p.GroupAddAudience(),
+		DirectMessage:       p.DirectMessageAudience(),
+		ReadReceipts:        p.SendsReadReceipts(),
+	}
+}
+
+// GetPrivacy 获取用户的隐私设置
+func (s *UserService) GetPrivacy(ctx context.Context, userID string) (*PrivacyView, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return newPrivacyView(user.Privacy), nil
+}
+
+// UpdatePrivacy 修改用户的隐私设置，返回修改后的完整设置
+func (s *UserService) UpdatePrivacy(ctx context.Context, userID string, update *PrivacyUpdate) (*PrivacyView, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	updates := bson.M{}
+	if update.DiscoverableByPhone != nil {
+		updates["discoverable_by_phone"] = *update.DiscoverableByPhone
+	}
+	if update.DiscoverableByEmail != nil {
+		updates["discoverable_by_email"] = *update.DiscoverableByEmail
+	}
+	audiences := map[string]*model.PrivacyAudience{
+		"last_seen":      update.LastSeen,
+		"profile":        update.Profile,
+		"group_add":      update.GroupAdd,
+		"direct_message": update.DirectMessage,
+	}
+	for field, audience := range audiences {
+		if audience == nil {
+			continue
+		}
+		if !model.ValidAudience(*audience) {
+			return nil, errors.New("invalid value for " + field + ": must be everyone, friends or nobody")
+		}
+		updates[field] = *audience
+	}
+	if update.ReadReceipts != nil {
+		updates["read_receipts"] = *update.ReadReceipts
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	if err := s.userRepo.UpdatePrivacy(ctx, objID, updates); err != nil {
+		return nil, err
+	}
+	return s.GetPrivacy(ctx, userID)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"chatweb/internal/model"
+	"chatweb/pkg/event"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// usernamePattern 用户名只允许字母、数字、下划线、点和中划线
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{3,32}$`)
+	// phonePattern 手机号：可选的 + 号开头，6 到 15 位数字
+	phonePattern = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+	// errUsernameFormat 用户名不符合 usernamePattern
+	errUsernameFormat = errors.New("username must be 3-32 characters of letters, digits, '_', '.' or '-'")
+	// errPhoneFormat 手机号不符合 phonePattern
+	errPhoneFormat = errors.New("invalid phone number format")
+	// localePattern 语言标签，如 zh、zh-CN、en-US
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+)
+
+// 允许的性别取值
+var allowedGenders = map[string]bool{
+	"":       true, // 未填写
+	"male":   true,
+	"female": true,
+	"other":  true,
+}
+
+// ProfileUpdate 用户资料更新请求，字段为 nil 表示不修改（PATCH 语义），只有这里列出的字段可以被修改
+type ProfileUpdate struct {
+	Username   *string `json:"username"`    // 用户名，3-32 位，需唯一
+	Phone      *string `json:"phone"`       // 手机号，需唯一
+	Avatar     *string `json:"avatar"`      // 不允许修改，头像只能通过上传接口设置
+	Nickname   *string `json:"nickname"`    // 昵称，最多 32 个字符
+	Bio        *string `json:"bio"`         // 个人简介，最多 200 个字符
+	StatusText *string `json:"status_text"` // 状态文字，最多 64 个字符
+	Gender     *string `json:"gender"`      // 性别：male、female、other 或空
+	Birthday   *string `json:"birthday"`    // 生日，格式 YYYY-MM-DD
+	Region     *string `json:"region"`      // 地区，最多 64 个字符
+	Locale     *string `json:"locale"`      // 语言偏好，如 zh-CN
+}
+
+// Validate 校验各字段的格式和长度，并去除首尾空白
+func (p *ProfileUpdate) Validate() error {
+	trim := func(v *string) {
+		if v != nil {
+			*v = strings.TrimSpace(*v)
+		}
+	}
+	for _, v := range []*string{p.Username, p.Phone, p.Avatar, p.Nickname, p.Bio, p.StatusText, p.Gender, p.Birthday, p.Region, p.Locale} {
+		trim(v)
+	}
+
+	if p.Username != nil && !usernamePattern.MatchString(*p.Username) {
+		return errUsernameFormat
+	}
+	if p.Phone != nil && !phonePattern.MatchString(*p.Phone) {
+		return errPhoneFormat
+	}
+	if p.Avatar != nil {
+		return errors.New("avatar can only be changed by uploading an image")
+	}
+	if err := checkLength("nickname", p.Nickname, 32); err != nil {
+		return err
+	}
+	if err := checkLength("bio", p.Bio, 200); err != nil {
+		return err
+	}
+	if err := checkLength("status_text", p.StatusText, 64); err != nil {
+		return err
+	}
+	if err := checkLength("region", p.Region, 64); err != nil {
+		return err
+	}
+	if p.Gender != nil && !allowedGenders[*p.Gender] {
+		return errors.New("gender must be one of male, female, other")
+	}
+	if p.Birthday != nil && *p.Birthday != "" {
+		birthday, err := time.Parse("2006-01-02", *p.Birthday)
+		if err != nil {
+			return errors.New("birthday must be in YYYY-MM-DD format")
+		}
+		if birthday.After(time.Now()) {
+			return errors.New("birthday cannot be in the future")
+		}
+	}
+	if p.Locale != nil && *p.Locale != "" && !localePattern.MatchString(*p.Locale) {
+		return errors.New("invalid locale format")
+	}
+
+	return nil
+}
+
+// toUpdates 将非 nil 字段转换为 MongoDB $set 使用的更新内容
+func (p *ProfileUpdate) toUpdates() map[string]interface{} {
+	updates := make(map[string]interface{})
+	set := func(field string, v *string) {
+		if v != nil {
+			updates[field] = *v
+		}
+	}
+
+	set("username", p.Username)
+	set("phone", p.Phone)
+	set("nickname", p.Nickname)
+	set("bio", p.Bio)
+	set("status_text", p.StatusText)
+	set("gender", p.Gender)
+	set("birthday", p.Birthday)
+	set("region", p.Region)
+	set("locale", p.Locale)
+	return updates
+}
+
+// checkLength 校验字符串字段的最大长度（按字符计）
+func checkLength(field string, v *string, max int) error {
+	if v != nil && utf8.RuneCountInString(*v) > max {
+		return fmt.Errorf("%s must be at most %d characters", field, max)
+	}
+	return nil
+}
+
+// UpdateProfile 校验并更新用户资料，成功后向好友推送 user_updated 事件
+func (s *UserService) UpdateProfile(ctx context.Context, userID string, update *ProfileUpdate) (*model.User, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %v", err)
+	}
+
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+
+	updates := update.toUpdates()
+	if len(updates) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	// 用户名和手机号由唯一索引保证不重复，冲突时返回 ErrUsernameTaken 或 ErrPhoneTaken
+	if err := s.userRepo.Update(ctx, objID, updates); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, objID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Phone != nil {
+		s.RefreshContactHashes(ctx, user)
+	}
+	s.publishUserUpdated(ctx, user)
+	return user, nil
+}
+
+// publishUserUpdated 向用户的所有好友推送资料更新事件，便于客户端刷新缓存
+// 推送的是公开资料，并按每个好友的身份经过隐私设置过滤
+func (s *UserService) publishUserUpdated(ctx context.Context, user *model.User) {
+	friendships, err := s.friendshipRepo.GetFriendsList(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to load friends for user_updated event: %v", err)
+		return
+	}
+
+	// 拉黑关系中的好友不再接收资料更新
+	blocked, err := s.blockService.BlockedSet(ctx, user.ID.Hex())
+	if err != nil {
+		log.Printf("Failed to load blocks for user_updated event: %v", err)
+		return
+	}
+
+	for _, f := range friendships {
+		friendID := f.UserID
+		if f.UserID == user.ID {
+			friendID = f.FriendID
+		}
+		if blocked[friendID.Hex()] {
+			continue
+		}
+
+		profile := *user
+		if _, err := s.privacyService.Redact(ctx, friendID, []*model.User{&profile}); err != nil {
+			log.Printf("Failed to redact profile for user_updated event: %v", err)
+			continue
+		}
+		s.eventBus.Publish(event.Event{
+			Type: event.UserUpdated,
+			Content: event.UserUpdatedContent{
+				UserID:     user.ID.Hex(),
+				Profile:    newPublicProfile(&profile),
+				Recipients: []string{friendID.Hex()},
+			},
+		})
+	}
+}
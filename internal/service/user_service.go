@@ -3,15 +3,15 @@ package service
 import (
 	"chatweb/internal/model"
 	"chatweb/internal/repository"
+	"chatweb/pkg/event"
 	"chatweb/pkg/jwt"
 	"context"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"mime/multipart"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
@@ -19,30 +19,63 @@ import (
 
 // UserService 提供用户相关的操作服务
 type UserService struct {
-	userRepo       *repository.UserRepository // 用户存储库，用于与数据库交互
-	jwtSecret      string                     // JWT的密钥，用于生成token
-	jwtExpireHours int                        // JWT的过期时间，单位小时
+	userRepo            *repository.UserRepository       // 用户存储库，用于与数据库交互
+	friendshipRepo      *repository.FriendshipRepository // 好友关系存储库，用于推送资料更新
+	groupRepo           *repository.GroupRepository      // 群组存储库，用于搜索结果排序
+	jwtSecret           string                           // JWT的密钥，用于生成token
+	jwtExpireHours      int                              // JWT的过期时间，单位小时
+	loginGuard          *LoginGuard                      // 登录失败跟踪，用于防爆破
+	notificationService *NotificationService             // 通知服务，用于发送安全提醒
+	auditService        *AuditService                    // 审计服务，用于记录锁定/解锁
+	eventBus            *event.EventBus                  // 事件总线，用于发布资料更新事件
+	blockService        *BlockService                    // 黑名单服务，拉黑双方互相不可见
+	privacyService      *PrivacyService                  // 隐私服务，按对方的隐私设置隐藏资料
+	contactHasher       *ContactHasher                   // 通讯录哈希计算，手机号或邮箱变更后更新哈希
+	fileService         *FileService                     // 文件服务，用于上传头像
 }
 
 // NewUserService 创建一个新的 UserService 实例
-func NewUserService(userRepo *repository.UserRepository, jwtSecret string, jwtExpireHours int) *UserService {
+func NewUserService(
+	userRepo *repository.UserRepository,
+	friendshipRepo *repository.FriendshipRepository,
+	groupRepo *repository.GroupRepository,
+	jwtSecret string,
+	jwtExpireHours int,
+	loginGuard *LoginGuard,
+	notificationService *NotificationService,
+	auditService *AuditService,
+	eventBus *event.EventBus,
+	blockService *BlockService,
+	privacyService *PrivacyService,
+	contactHasher *ContactHasher,
+	fileService *FileService,
+) *UserService {
 	return &UserService{
-		userRepo:       userRepo,       // 初始化用户存储库
-		jwtSecret:      jwtSecret,      // 设置JWT密钥
-		jwtExpireHours: jwtExpireHours, // 设置JWT的过期时间
+		userRepo:            userRepo,            // 初始化用户存储库
+		friendshipRepo:      friendshipRepo,      // 初始化好友关系存储库
+		groupRepo:           groupRepo,           // 初始化群组存储库
+		jwtSecret:           jwtSecret,           // 设置JWT密钥
+		jwtExpireHours:      jwtExpireHours,      // 设置JWT的过期时间
+		loginGuard:          loginGuard,          // 初始化登录防护
+		notificationService: notificationService, // 初始化通知服务
+		auditService:        auditService,        // 初始化审计服务
+		eventBus:            eventBus,            // 初始化事件总线
+		blockService:        blockService,        // 初始化黑名单服务
+		privacyService:      privacyService,      // 初始化隐私服务
+		contactHasher:       contactHasher,       // 初始化通讯录哈希
+		fileService:         fileService,         // 初始化文件服务
 	}
 }
 
-// Register 用户注册
+// Register 用户注册，用户名和手机号的格式与修改资料时的规则一致，邮箱、用户名和手机号都需要唯一
 func (s *UserService) Register(ctx context.Context, user *model.User) error {
-	// 检查邮箱是否已存在
-	if _, err := s.userRepo.FindByEmail(ctx, user.Email); err == nil {
-		return errors.New("email already exists") // 如果邮箱已存在，返回错误
+	user.Username = strings.TrimSpace(user.Username)
+	user.Phone = strings.TrimSpace(user.Phone)
+	if !usernamePattern.MatchString(user.Username) {
+		return errUsernameFormat
 	}
-
-	// 检查手机号是否已存在
-	if _, err := s.userRepo.FindByPhone(ctx, user.Phone); err == nil {
-		return errors.New("phone number already exists") // 如果手机号已存在，返回错误
+	if !phonePattern.MatchString(user.Phone) {
+		return errPhoneFormat
 	}
 
 	// 加密用户密码
@@ -51,33 +84,184 @@ func (s *UserService) Register(ctx context.Context, user *model.User) error {
 		return err // 密码加密失败，返回错误
 	}
 	user.Password = string(hashedPassword) // 将加密后的密码存储在用户模型中
+	user.Role = model.RoleUser             // 新注册的用户都是普通用户
+
+	// 将用户数据存入数据库，邮箱、用户名和手机号的唯一性由唯一索引保证
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return err
+	}
+	s.RefreshContactHashes(ctx, user)
+	return nil
+}
 
-	// 将用户数据存入数据库
-	return s.userRepo.Create(ctx, user)
+// RefreshContactHashes 根据用户当前的手机号和邮箱重新计算通讯录哈希，失败只记录日志，启动时的补算会兜底
+func (s *UserService) RefreshContactHashes(ctx context.Context, user *model.User) {
+	phoneHash := s.contactHasher.HashPhone(user.Phone)
+	emailHash := s.contactHasher.HashEmail(user.Email)
+	if err := s.userRepo.SetContactHashes(ctx, user.ID, phoneHash, emailHash, s.contactHasher.Key()); err != nil {
+		log.Printf("Failed to update contact hashes for user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	user.PhoneHash, user.EmailHash, user.ContactHashKey = phoneHash, emailHash, s.contactHasher.Key()
 }
 
-// Login 用户登录
-func (s *UserService) Login(ctx context.Context, email, password string) (string, *model.User, error) {
+// Login 用户登录，ip 为客户端地址，用于按 IP 统计失败次数
+func (s *UserService) Login(ctx context.Context, email, password, ip string) (string, *model.User, error) {
+	// 账号或 IP 处于退避/锁定期时直接拒绝，不再校验密码
+	if err := s.loginGuard.Check(email, ip); err != nil {
+		return "", nil, err
+	}
+
 	// 根据邮箱查找用户
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil {
+		s.recordLoginFailure(ctx, email, ip, nil)
 		return "", nil, fmt.Errorf("invalid email or password") // 如果用户不存在，返回错误
 	}
 
 	// 校验密码是否正确
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		s.recordLoginFailure(ctx, email, ip, user)
 		return "", nil, fmt.Errorf("invalid email or password") // 密码不匹配，返回错误
 	}
 
+	// 登录成功，清除该账号的失败记录并释放预占的尝试
+	s.loginGuard.RecordSuccess(email, ip)
+
 	// 生成JWT token
-	token, err := jwt.GenerateToken(user.ID.Hex(), s.jwtSecret, s.jwtExpireHours)
+	token, err := s.CompleteLogin(ctx, user)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate token: %v", err) // 如果生成token失败，返回错误
+		return "", nil, err
 	}
 
 	return token, user, nil // 返回token和用户信息
 }
 
+// CompleteLogin 身份校验通过后的公共登录流程：检查禁用状态、撤销计划中的注销并签发 token
+// 密码登录和 SSO 登录共用
+func (s *UserService) CompleteLogin(ctx context.Context, user *model.User) (string, error) {
+	// 被管理员禁用的账号不允许登录，放在身份校验之后，避免泄露账号状态
+	if user.Disabled {
+		return "", errors.New("account has been disabled")
+	}
+
+	// 冷静期内重新登录视为撤销注销
+	if user.DeletionScheduledAt != nil {
+		if err := s.userRepo.CancelDeletion(ctx, user.ID); err != nil {
+			return "", err
+		}
+		user.DeletionScheduledAt = nil
+		log.Printf("Account %s deletion cancelled by login", user.ID.Hex())
+	}
+
+	return s.IssueToken(user)
+}
+
+// IssueToken 为用户签发携带当前角色和 token 版本的 JWT
+func (s *UserService) IssueToken(user *model.User) (string, error) {
+	token, err := jwt.GenerateToken(user.ID.Hex(), string(user.GetRole()), user.TokenVersion, s.jwtSecret, s.jwtExpireHours)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err) // 如果生成token失败，返回错误
+	}
+	return token, nil
+}
+
+// ValidateSession 校验 token 是否仍然有效：用户存在、未注销、未禁用且 token 版本与当前一致
+func (s *UserService) ValidateSession(ctx context.Context, userID string, tokenVersion int) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, objID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	if user.TokenVersion != tokenVersion || user.Disabled || user.DeletionScheduledAt != nil || user.DeletedAt != nil {
+		return errors.New("session revoked")
+	}
+	return nil
+}
+
+// recordLoginFailure 记录一次登录失败，触发锁定时写审计日志并通知账号本人
+// user 为空表示账号不存在，此时仍按邮箱计数，避免通过锁定行为探测账号是否存在
+func (s *UserService) recordLoginFailure(ctx context.Context, email, ip string, user *model.User) {
+	accountLocked, ipLocked := s.loginGuard.RecordFailure(email, ip)
+
+	if ipLocked {
+		log.Printf("IP %s locked after repeated login failures", ip)
+		s.auditService.Record(ctx, model.AuditIPLocked, primitive.NilObjectID, primitive.NilObjectID, ip,
+			fmt.Sprintf("too many failed login attempts from %s", ip))
+	}
+
+	if !accountLocked || user == nil {
+		return
+	}
+
+	log.Printf("Account %s locked after repeated login failures", user.ID.Hex())
+	s.auditService.Record(ctx, model.AuditAccountLocked, user.ID, primitive.NilObjectID, ip,
+		fmt.Sprintf("too many failed login attempts, last from %s", ip))
+
+	content := fmt.Sprintf("您的账号因多次登录失败已被临时锁定，最近一次尝试来自 %s。如果不是您本人操作，请尽快修改密码。", ip)
+	if err := s.notificationService.CreateSystemNotification(ctx, user.ID, "账号安全提醒", content); err != nil {
+		log.Printf("Failed to send lockout notification: %v", err)
+	}
+}
+
+// UnlockAccount 管理员解除账号的登录锁定，ip 不为空时同时解除该 IP 的锁定
+func (s *UserService) UnlockAccount(ctx context.Context, adminID, userID, ip string) error {
+	adminObjID, err := primitive.ObjectIDFromHex(adminID)
+	if err != nil {
+		return fmt.Errorf("invalid admin ID: %v", err)
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID: %v", err)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userObjID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	s.loginGuard.ResetAccount(user.Email)
+	detail := "login lockout cleared by admin"
+	if ip != "" {
+		s.loginGuard.ResetIP(ip)
+		detail = fmt.Sprintf("login lockout cleared by admin, including IP %s", ip)
+	}
+
+	s.auditService.Record(ctx, model.AuditAccountUnlocked, user.ID, adminObjID, ip, detail)
+	return nil
+}
+
+// PromoteAdmins 将配置文件中指定的用户提升为管理员，用于初始化第一个管理员账号
+func (s *UserService) PromoteAdmins(ctx context.Context, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(userIDs))
+	for _, id := range userIDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return fmt.Errorf("invalid admin user ID %q: %v", id, err)
+		}
+		ids = append(ids, objID)
+	}
+
+	promoted, err := s.userRepo.PromoteToAdmin(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if promoted > 0 {
+		log.Printf("Promoted %d user(s) to admin from config", promoted)
+	}
+	return nil
+}
+
 // GetUserByID 根据用户ID获取用户信息
 func (s *UserService) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
 	log.Print("userId:", userID)
@@ -89,22 +273,45 @@ func (s *UserService) GetUserByID(ctx context.Context, userID string) (*model.Us
 	return s.userRepo.FindByID(ctx, objID) // 从数据库中获取用户信息
 }
 
-// UpdateUser 更新用户信息
-func (s *UserService) UpdateUser(ctx context.Context, userID string, updates map[string]interface{}) error {
-	objID, err := primitive.ObjectIDFromHex(userID)
+// SearchUser 根据标识符（邮箱、用户名或手机号）精确查找用户，viewerID 为发起搜索的用户
+// 双方存在拉黑关系、账号已注销，或对方关闭了通过手机号/邮箱被发现时，与用户不存在的结果相同
+func (s *UserService) SearchUser(ctx context.Context, viewerID, identifier string) (*PublicProfile, error) {
+	user, err := s.userRepo.SearchUserByIdentifier(ctx, identifier) // 根据标识符查找用户
 	if err != nil {
-		return err // 如果ID格式无效，返回错误
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, errors.New("user not found")
+	}
+
+	// 通过用户名命中时总是可见，通过手机号或邮箱命中时需要对方允许
+	if user.Username != identifier {
+		if (user.Email == identifier && !user.Privacy.IsDiscoverableByEmail()) ||
+			(user.Phone == identifier && !user.Privacy.IsDiscoverableByPhone()) {
+			return nil, errors.New("user not found")
+		}
 	}
-	return s.userRepo.Update(ctx, objID, updates) // 更新用户数据
-}
 
-// SearchUser 根据标识符（邮箱或手机号）查找用户
-func (s *UserService) SearchUser(ctx context.Context, identifier string) (*model.User, error) {
-	return s.userRepo.SearchUserByIdentifier(ctx, identifier) // 根据标识符查找用户
+	viewerObjID, err := primitive.ObjectIDFromHex(viewerID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if s.blockService.IsBlocked(ctx, viewerObjID, user.ID) {
+		return nil, errors.New("user not found")
+	}
+	if _, err := s.privacyService.Redact(ctx, viewerObjID, []*model.User{user}); err != nil {
+		return nil, err
+	}
+	return newPublicProfile(user), nil
 }
 
-// GetUsersByIDs 通过一组 ID 获取多个用户信息
-func (s *UserService) GetUsersByIDs(ctx context.Context, userIDs []string) ([]*model.User, []string, error) {
+// GetUsersByIDs 通过一组 ID 获取多个用户的公开资料，按每个用户的隐私设置对 viewerID 隐藏资料和最后在线时间
+func (s *UserService) GetUsersByIDs(ctx context.Context, viewerID string, userIDs []string) ([]*PublicProfile, []string, error) {
+	viewerObjID, err := primitive.ObjectIDFromHex(viewerID)
+	if err != nil {
+		return nil, nil, errors.New("invalid user ID")
+	}
+
 	var objectIDs []primitive.ObjectID
 	var failedIDs []string
 
@@ -129,10 +336,16 @@ func (s *UserService) GetUsersByIDs(ctx context.Context, userIDs []string) ([]*m
 		return nil, failedIDs, err
 	}
 
+	if _, err := s.privacyService.Redact(ctx, viewerObjID, users); err != nil {
+		return nil, failedIDs, err
+	}
+
 	// 确保所有请求的用户 ID 都匹配返回的数据
 	foundIDs := make(map[string]bool)
+	profiles := make([]*PublicProfile, 0, len(users))
 	for _, user := range users {
 		foundIDs[user.ID.Hex()] = true
+		profiles = append(profiles, newPublicProfile(user))
 	}
 
 	// 检查哪些 ID 没有找到
@@ -142,7 +355,7 @@ func (s *UserService) GetUsersByIDs(ctx context.Context, userIDs []string) ([]*m
 		}
 	}
 
-	return users, failedIDs, nil
+	return profiles, failedIDs, nil
 }
 
 // 允许的图片扩展名
@@ -152,37 +365,38 @@ var allowedExtensions = map[string]bool{
 	".png":  true,
 }
 
-// UploadAvatar 处理头像上传
-func (s *UserService) UploadAvatar(ctx context.Context, userID string, fileName string, fileData []byte) (string, error) {
+// UploadAvatar 通过文件服务上传头像并更新用户头像 URL，旧头像是本人上传的文件时一并删除
+func (s *UserService) UploadAvatar(ctx context.Context, userID string, file *multipart.FileHeader) (string, error) {
 	// 检查扩展名
-	ext := strings.ToLower(filepath.Ext(fileName))
+	ext := strings.ToLower(filepath.Ext(file.Filename))
 	if !allowedExtensions[ext] {
 		return "", fmt.Errorf("only .jpg, .jpeg, .png files are allowed")
 	}
 
-	// 创建目录
-	uploadDir := "uploads/avatar"
-	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-		return "", fmt.Errorf("failed to create upload directory")
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", errors.New("invalid user ID")
 	}
-
-	// 生成唯一文件名
-	newFileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-	savePath := filepath.Join(uploadDir, newFileName)
-
-	// 写入文件
-	if err := os.WriteFile(savePath, fileData, 0644); err != nil {
-		return "", fmt.Errorf("failed to save file")
+	user, err := s.userRepo.FindByID(ctx, userObjID)
+	if err != nil {
+		return "", err
 	}
 
-	// 生成头像 URL
-	baseURL := "http://localhost:8080"
-	fileURL := fmt.Sprintf("%s/uploads/avatar/%s", baseURL, newFileName)
+	avatar, err := s.fileService.UploadFile(ctx, file, userID)
+	if err != nil {
+		return "", err
+	}
 
 	// 更新数据库
-	if err := s.userRepo.UpdateUserAvatar(ctx, userID, fileURL); err != nil {
+	if err := s.userRepo.UpdateUserAvatar(ctx, userID, avatar.URL); err != nil {
+		_ = s.fileService.DeleteFileByID(ctx, avatar.ID)
 		return "", fmt.Errorf("failed to update user avatar: %v", err)
 	}
 
-	return fileURL, nil
+	if user.Avatar != "" {
+		if err := s.fileService.DeleteOwnedFileByURL(ctx, userObjID, user.Avatar); err != nil {
+			log.Printf("Failed to delete previous avatar of user %s: %v", userID, err)
+		}
+	}
+	return avatar.URL, nil
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"chatweb/api"
 	"chatweb/config"
@@ -10,6 +12,8 @@ import (
 	"chatweb/internal/service"
 	"chatweb/middleware"
 	"chatweb/pkg/event"
+	"chatweb/pkg/mail"
+	"chatweb/pkg/oidc"
 	"chatweb/pkg/storage"
 	"chatweb/pkg/websocketM"
 
@@ -23,16 +27,40 @@ func main() {
 	// 初始化数据库连接
 	mongodb.InitMongoDB(cfg.MongoDB.URI, cfg.MongoDB.Database)
 
-	// 初始化MinIO客户端
-	minioClient, err := storage.NewMinioClient(
-		cfg.MinIO.Endpoint,
-		cfg.MinIO.AccessKey,
-		cfg.MinIO.SecretKey,
-		cfg.MinIO.Bucket,
-		cfg.MinIO.UseSSL,
-	)
-	if err != nil {
-		log.Fatalf("Failed to initialize MinIO client: %v", err)
+	// 初始化文件存储后端
+	var fileStorage storage.Backend
+	switch cfg.Storage.Backend {
+	case "local":
+		baseURL := cfg.Storage.PublicBaseURL
+		if baseURL == "" {
+			baseURL = "/uploads"
+		}
+		localStorage, err := storage.NewLocalBackend(cfg.Storage.LocalDir, baseURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize local storage: %v", err)
+		}
+		fileStorage = localStorage
+	case "minio":
+		minioClient, err := storage.NewMinioClient(
+			cfg.MinIO.Endpoint,
+			cfg.MinIO.AccessKey,
+			cfg.MinIO.SecretKey,
+			cfg.MinIO.Bucket,
+			cfg.MinIO.UseSSL,
+			cfg.Storage.PublicBaseURL,
+		)
+		if err != nil {
+			log.Fatalf("Failed to initialize MinIO client: %v", err)
+		}
+		// 预签名 URL 需要用客户端可访问的地址签名
+		if cfg.MinIO.PublicEndpoint != "" {
+			if err := minioClient.SetPublicEndpoint(cfg.MinIO.PublicEndpoint, cfg.MinIO.AccessKey, cfg.MinIO.SecretKey, cfg.MinIO.Region, cfg.MinIO.PublicUseSSL); err != nil {
+				log.Fatalf("Failed to initialize MinIO presign client: %v", err)
+			}
+		}
+		fileStorage = minioClient
+	default:
+		log.Fatalf("Unknown storage backend %q", cfg.Storage.Backend)
 	}
 
 	// 初始化仓库
@@ -40,39 +68,146 @@ func main() {
 	messageRepo := repository.NewMessageRepository()
 	groupRepo := repository.NewGroupRepository()
 	fileRepo := repository.NewFileRepository()
+	fileUploadRepo := repository.NewFileUploadRepository()
+	resumableUploadRepo := repository.NewResumableUploadRepository()
 	notificationRepo := repository.NewNotificationRepository()
 	friendshipRepo := repository.NewFriendshipRepository()
+	friendRequestRepo := repository.NewFriendRequestRepository()
+	auditRepo := repository.NewAuditRepository()
+	blockRepo := repository.NewBlockRepository()
+	friendTagRepo := repository.NewFriendTagRepository()
+	friendMetaRepo := repository.NewFriendMetaRepository()
+	groupInviteRepo := repository.NewGroupInviteRepository()
+	groupJoinRepo := repository.NewGroupJoinRepository()
+	groupAnnouncementRepo := repository.NewGroupAnnouncementRepository()
+	groupChannelRepo := repository.NewGroupChannelRepository()
 
 	// 创建事件总线
 	eventBus := event.NewEventBus()
 
 	// 初始化服务
-	userService := service.NewUserService(userRepo, cfg.JWT.Secret, cfg.JWT.ExpireTime)
-	messageService := service.NewMessageService(messageRepo, nil, eventBus)
-	groupService := service.NewGroupService(groupRepo)
-	fileService := service.NewFileService(fileRepo, minioClient)
 	notificationService := service.NewNotificationService(notificationRepo, eventBus)
-	friendshipService := service.NewFriendshipService(friendshipRepo, userRepo)
+	auditService := service.NewAuditService(auditRepo)
+	blockService := service.NewBlockService(blockRepo, userRepo)
+	privacyService := service.NewPrivacyService(userRepo, friendshipRepo, blockService)
+	contactHasher := service.NewContactHasher(cfg.Contact.HashSalt)
+	loginGuard := service.NewLoginGuard(
+		cfg.Security.LoginMaxFailures,
+		cfg.Security.LoginIPMaxFailures,
+		time.Duration(cfg.Security.LoginBackoffBase)*time.Second,
+		time.Duration(cfg.Security.LoginLockoutMinutes)*time.Minute,
+	)
+	fileService := service.NewFileService(
+		fileRepo, fileUploadRepo, resumableUploadRepo, messageRepo, groupRepo, groupChannelRepo, userRepo, fileStorage,
+		int64(cfg.File.MaxUploadMB)*1024*1024,
+		time.Duration(cfg.File.UploadExpireMinutes)*time.Minute,
+		time.Duration(cfg.File.DownloadExpireSeconds)*time.Second,
+		int64(cfg.File.ResumableMaxUploadMB)*1024*1024,
+		int64(cfg.File.ResumablePartSizeMB)*1024*1024,
+		time.Duration(cfg.File.ResumableExpireHours)*time.Hour,
+	)
+	userService := service.NewUserService(userRepo, friendshipRepo, groupRepo, cfg.JWT.Secret, cfg.JWT.ExpireTime, loginGuard, notificationService, auditService, eventBus, blockService, privacyService, contactHasher, fileService)
+	groupService := service.NewGroupService(groupRepo, groupInviteRepo, groupJoinRepo, groupAnnouncementRepo, groupChannelRepo, messageRepo, friendshipRepo, eventBus, privacyService, notificationService, fileService, cfg.Group.MaxPins, cfg.Group.MaxMembers, time.Duration(cfg.Group.DissolveRetentionDays)*24*time.Hour)
+	messageService := service.NewMessageService(messageRepo, userRepo, nil, eventBus, privacyService, groupService, notificationService, fileService)
+	mailer := mail.NewSender(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+	accountService := service.NewAccountService(
+		userRepo, messageRepo, friendshipRepo, friendRequestRepo, blockRepo, friendTagRepo, friendMetaRepo,
+		groupRepo, groupChannelRepo, groupInviteRepo, groupJoinRepo, notificationRepo,
+		userService, fileService, notificationService, mailer, eventBus,
+		time.Duration(cfg.Account.EmailCodeExpireMinutes)*time.Minute,
+		time.Duration(cfg.Account.DeletionGraceDays)*24*time.Hour,
+	)
+	onlineService := service.NewOnlineService(userRepo, eventBus, privacyService)
+	friendshipService := service.NewFriendshipService(
+		friendshipRepo, userRepo, friendRequestRepo, friendTagRepo, friendMetaRepo, groupRepo, messageRepo,
+		onlineService, notificationService, blockService, privacyService,
+		time.Duration(cfg.Friend.RequestExpireDays)*24*time.Hour,
+	)
 	// 创建WebSocket hub
 	wsHub := websocketM.NewHub(eventBus)
-	onlineService := service.NewOnlineService(userRepo, eventBus)
+	discoveryService := service.NewDiscoveryService(userRepo, blockService, privacyService, contactHasher, cfg.Contact.MaxHashesPerRequest)
+	adminService := service.NewAdminService(userRepo, messageRepo, groupRepo, onlineService, notificationService, auditService, eventBus)
 	go wsHub.Run()
 
+	// 邮箱唯一索引，防止并发注册或修改邮箱产生重复邮箱
+	if err := userRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Fatalf("Failed to create user indexes (duplicate emails must be resolved first): %v", err)
+	}
+
+	// 邀请码唯一索引
+	if err := groupInviteRepo.EnsureIndexes(context.Background()); err != nil {
+		log.Fatalf("Failed to create group invite indexes: %v", err)
+	}
+
+	// 统一群组成员存储并创建唯一索引，已迁移过时直接跳过
+	if err := groupRepo.MigrateMembership(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate group membership: %v", err)
+	}
+
+	// 为已有群组创建默认频道并把历史消息归入其中，已迁移过时直接跳过
+	if err := groupService.MigrateChannels(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate group channels: %v", err)
+	}
+
+	// 为升级前的图片和文件消息补记文件 ID
+	go func() {
+		if err := fileService.MigrateMessageFileIDs(context.Background()); err != nil {
+			log.Printf("Failed to migrate message file IDs: %v", err)
+		}
+	}()
+
+	// 将配置文件中指定的用户提升为管理员
+	if err := userService.PromoteAdmins(context.Background(), cfg.Security.AdminUserIDs); err != nil {
+		log.Fatalf("Failed to promote admin users: %v", err)
+	}
+
+	// 为缺少通讯录哈希或盐已更换的用户补算哈希
+	go discoveryService.BackfillHashes(context.Background())
+
+	// 定期清理冷静期已结束的注销账号
+	go accountService.RunDeletionWorker(time.Hour)
+
+	// 定期清理保留期已结束的已解散群组和没有成员的群组
+	go groupService.RunPurgeWorker(time.Hour)
+
+	// 定期清理过期未确认的预签名上传和长时间没有进展的分片上传
+	go fileService.RunUploadCleanupWorker(10 * time.Minute)
+
 	// 初始化处理器
 	userHandler := api.NewUserHandler(userService)
-	chatHandler := api.NewChatHandler(messageService, notificationService, groupService, onlineService, eventBus)
+	chatHandler := api.NewChatHandler(messageService, notificationService, groupService, onlineService, wsHub)
 	groupHandler := api.NewGroupHandler(groupService, userService)
 	fileHandler := api.NewFileHandler(fileService)
 	notificationHandler := api.NewNotificationHandler(notificationService)
 	onlineHandler := api.NewOnlineHandler(onlineService)
+	messageHandler := api.NewMessageHandler(messageService)
 	friendshipHandler := api.NewFriendshipHandler(friendshipService)
+	blockHandler := api.NewBlockHandler(blockService)
+	discoveryHandler := api.NewDiscoveryHandler(discoveryService)
+	adminHandler := api.NewAdminHandler(userService, adminService)
+	accountHandler := api.NewAccountHandler(accountService)
+
+	// 启用 OIDC 时初始化单点登录
+	var ssoHandler *api.SSOHandler
+	if cfg.OIDC.Enabled {
+		provider := oidc.NewProvider(cfg.OIDC.Issuer, cfg.OIDC.ClientID, cfg.OIDC.ClientSecret, cfg.OIDC.RedirectURL, cfg.OIDC.Scopes)
+		ssoService := service.NewSSOService(provider, userRepo, userService, auditService)
+		ssoHandler = api.NewSSOHandler(ssoService)
+	}
 
 	// 设置gin模式
 	gin.SetMode(cfg.Server.Mode)
 
 	// 创建路由
 	r := gin.Default()
-	r.Static("/uploads", "./uploads")
+	// 只信任配置的反向代理转发的客户端地址，登录锁定和限流依赖真实的客户端 IP
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	// 只有本地存储的文件由本服务以静态文件方式提供
+	if cfg.Storage.Backend == "local" {
+		r.Static("/uploads", cfg.Storage.LocalDir)
+	}
 
 	// 添加全局中间件
 	r.Use(middleware.Cors())
@@ -86,11 +221,17 @@ func main() {
 		File:         fileHandler,
 		Notification: notificationHandler,
 		Online:       onlineHandler,
+		Message:      messageHandler,
 		Friendship:   friendshipHandler,
+		Block:        blockHandler,
+		Discovery:    discoveryHandler,
+		Admin:        adminHandler,
+		Account:      accountHandler,
+		SSO:          ssoHandler,
 	}
 
 	// 初始化路由
-	api.InitRoutes(r, cfg, handlers)
+	api.InitRoutes(r, cfg, handlers, userService)
 
 	// 启动服务器
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
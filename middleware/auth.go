@@ -2,14 +2,21 @@ package middleware
 
 import (
 	"chatweb/config"
+	"chatweb/internal/model"
 	"chatweb/pkg/jwt"
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Auth(cfg *config.Config) gin.HandlerFunc {
+// SessionValidator 校验 token 对应的会话是否仍然有效（未被撤销）
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, userID string, tokenVersion int) error
+}
+
+func Auth(cfg *config.Config, sessions SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,14 +32,51 @@ func Auth(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := jwt.ParseToken(parts[1], cfg.JWT.Secret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		authenticate(c, cfg, sessions, parts[1])
+	}
+}
+
+// WebSocketAuth WebSocket 握手的认证，浏览器无法在握手请求上设置 Authorization 头，
+// 因此同时接受 token 查询参数；校验规则与 Auth 相同
+func WebSocketAuth(cfg *config.Config, sessions SessionValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+			token = parts[1]
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is required"})
 			c.Abort()
 			return
 		}
 
-		c.Set("userID", claims.UserID)
-		c.Next()
+		authenticate(c, cfg, sessions, token)
 	}
 }
+
+// authenticate 解析 token 并校验会话，通过后把用户 ID 和角色写入上下文
+func authenticate(c *gin.Context, cfg *config.Config, sessions SessionValidator, token string) {
+	claims, err := jwt.ParseToken(token, cfg.JWT.Secret)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+
+	// 修改密码、注销账号等操作会让旧 token 失效
+	if err := sessions.ValidateSession(c.Request.Context(), claims.UserID, claims.TokenVersion); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+		c.Abort()
+		return
+	}
+
+	// 早期签发的 token 没有角色，按普通用户处理
+	role := claims.Role
+	if role == "" {
+		role = string(model.RoleUser)
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Set("role", role)
+	c.Next()
+}
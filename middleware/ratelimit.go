@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"chatweb/pkg/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 按客户端 IP 限流，超出限制时返回 429
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string {
+		return c.ClientIP()
+	})
+}
+
+// RateLimitByUser 按当前登录用户限流，必须放在 Auth 中间件之后；未登录时退化为按 IP 限流
+func RateLimitByUser(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string {
+		if userID := c.GetString("userID"); userID != "" {
+			return "user:" + userID
+		}
+		return "ip:" + c.ClientIP()
+	})
+}
+
+// rateLimit 按 keyFunc 返回的 key 限流，超出限制时返回 429
+func rateLimit(limiter *ratelimit.Limiter, keyFunc func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter := limiter.Allow(keyFunc(c))
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many requests, please try again later",
+				"retry_after": seconds,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"chatweb/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole 仅允许 token 中的角色属于 roles 之一的用户访问，需在 Auth 之后使用
+func RequireRole(roles ...model.UserRole) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[string(role)] = true
+	}
+
+	return func(c *gin.Context) {
+		if !allowed[c.GetString("role")] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -9,12 +9,15 @@ type EventType string
 
 // 定义几种常见的事件类型
 const (
-	MessageSent  EventType = "message_sent" // 消息已发送
-	MessageRead  EventType = "message_read" // 消息已读
-	GroupRead    EventType = "group_read"   // 群组消息已读
-	UserOnline   EventType = "user_online"  // 用户上线
-	UserOffline  EventType = "user_offline" // 用户下线
-	Notification EventType = "notification" // 通知
+	MessageSent    EventType = "message_sent"    // 消息已发送
+	MessageRead    EventType = "message_read"    // 消息已读
+	GroupRead      EventType = "group_read"      // 群组消息已读
+	UserOnline     EventType = "user_online"     // 用户上线
+	UserOffline    EventType = "user_offline"    // 用户下线
+	Notification   EventType = "notification"    // 通知
+	UserUpdated    EventType = "user_updated"    // 用户资料更新
+	SessionRevoked EventType = "session_revoked" // 用户会话被撤销（禁用、强制下线）
+	GroupUpdated   EventType = "group_updated"   // 群组成员或资料变动
 )
 
 // Event 表示一个事件的结构
@@ -31,6 +34,8 @@ type MessageReadContent struct {
 	UserID    string `json:"user_id"`    // 用户ID
 	ReadAt    string `json:"read_at"`    // 阅读时间
 	IsGroup   bool   `json:"is_group"`   // 是否是群组消息
+
+	Recipients []string `json:"-"` // 需要收到回执的用户（消息发送者）ID 列表
 }
 
 // GroupReadContent 表示群组消息已读事件的内容
@@ -40,7 +45,8 @@ type GroupReadContent struct {
 	ReadByUser string   `json:"read_by_user"` // 已读的用户ID
 	ReadAt     string   `json:"read_at"`      // 阅读时间
 	ReadCount  int      `json:"read_count"`   // 阅读人数
-	ReadBy     []string `json:"read_by"`      // 已阅读的用户列表
+	ReadBy     []string `json:"read_by"`      // 已阅读的用户列表（不含关闭了已读回执的用户）
+	Recipients []string `json:"-"`            // 需要收到回执的用户（消息发送者）ID 列表
 }
 
 // UserStatusContent 表示用户状态变化事件的内容
@@ -49,6 +55,30 @@ type UserStatusContent struct {
 	IsOnline bool   `json:"is_online"` // 是否在线
 }
 
+// UserUpdatedContent 表示用户资料更新事件的内容
+type UserUpdatedContent struct {
+	UserID     string      `json:"user_id"` // 资料被更新的用户ID
+	Profile    interface{} `json:"profile"` // 更新后的资料
+	Recipients []string    `json:"-"`       // 需要收到推送的用户（好友）ID 列表
+}
+
+// SessionRevokedContent 表示会话被撤销事件的内容
+type SessionRevokedContent struct {
+	UserID string `json:"user_id"` // 被撤销会话的用户ID
+	Reason string `json:"reason"`  // 撤销原因，如 disabled、force_logout
+}
+
+// GroupUpdatedContent 表示群组变动事件的内容
+type GroupUpdatedContent struct {
+	GroupID    string      `json:"group_id"`            // 群组ID
+	Action     string      `json:"action"`              // 动作类型，如 member_removed、admin_promoted
+	ActorID    string      `json:"actor_id"`            // 执行操作的用户ID
+	TargetID   string      `json:"target_id,omitempty"` // 被操作的成员ID，公告和置顶操作中为公告或消息ID
+	Role       string      `json:"role,omitempty"`      // 操作后被操作成员的角色
+	Message    interface{} `json:"message"`             // 对应的群系统消息
+	Recipients []string    `json:"-"`                   // 需要收到推送的用户（群成员及被移除的成员）ID 列表
+}
+
 // Handler 定义了事件处理函数的类型
 type Handler func(event Event)
 
@@ -10,6 +10,10 @@ import (
 type Claims struct {
 	// UserID 用户的唯一标识符
 	UserID string `json:"user_id"`
+	// Role 签发时用户的角色，角色变更时会递增 token 版本，保证 token 中的角色不会过期
+	Role string `json:"role"`
+	// TokenVersion 签发时用户的 token 版本，用户修改密码或被强制下线后版本递增，旧 token 随之失效
+	TokenVersion int `json:"ver"`
 	// 使用 jwt.RegisteredClaims 来包含标准的 JWT 声明（如过期时间、签发时间等）
 	jwt.RegisteredClaims
 }
@@ -17,16 +21,20 @@ type Claims struct {
 // GenerateToken 生成一个新的 JWT token
 // 输入:
 //   - userID: 用户的唯一标识符
+//   - role: 用户角色
+//   - tokenVersion: 用户当前的 token 版本
 //   - secret: 用于签名的密钥
 //   - expireHours: token 的过期时间，单位为小时
 //
 // 输出:
 //   - token 字符串: 生成的 JWT token
 //   - error: 错误信息（如果有的话）
-func GenerateToken(userID string, secret string, expireHours int) (string, error) {
-	// 创建自定义的 Claims，其中包含用户ID和注册的 JWT 声明（如过期时间、签发时间）
+func GenerateToken(userID, role string, tokenVersion int, secret string, expireHours int) (string, error) {
+	// 创建自定义的 Claims，其中包含用户ID、角色、token 版本和注册的 JWT 声明（如过期时间、签发时间）
 	claims := Claims{
-		UserID: userID,
+		UserID:       userID,
+		Role:         role,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			// 设置 token 的过期时间为当前时间 + expireHours 小时
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expireHours))),
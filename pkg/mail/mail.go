@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+)
+
+// Sender 定义发送邮件的接口
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// NewSender 根据配置创建邮件发送器，未配置 SMTP 服务器时退化为只打印日志，便于本地开发
+func NewSender(host string, port int, username, password, from string) Sender {
+	if host == "" {
+		return &LogSender{}
+	}
+
+	return &SMTPSender{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		auth: smtp.PlainAuth("", username, password, host),
+		from: from,
+	}
+}
+
+// SMTPSender 通过 SMTP 服务器发送邮件
+type SMTPSender struct {
+	addr string    // SMTP 服务器地址 host:port
+	auth smtp.Auth // SMTP 认证信息
+	from string    // 发件人地址
+}
+
+// Send 发送一封纯文本邮件
+func (s *SMTPSender) Send(to, subject, body string) error {
+	msg := strings.Join([]string{
+		"From: " + s.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(msg))
+}
+
+// LogSender 只把邮件内容打印到日志，不真正发送
+type LogSender struct{}
+
+// Send 打印邮件内容
+func (s *LogSender) Send(to, subject, body string) error {
+	log.Printf("[mail] to=%s subject=%s body=%s", to, subject, body)
+	return nil
+}
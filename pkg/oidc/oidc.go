@@ -0,0 +1,349 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksMinRefreshInterval 两次刷新 JWKS 之间的最短间隔，防止伪造 kid 的 token 频繁触发请求
+	jwksMinRefreshInterval = time.Minute
+	// clockSkew 校验 ID Token 时间字段时允许的时钟偏差
+	clockSkew = time.Minute
+	// maxResponseSize 读取身份提供方响应的最大字节数
+	maxResponseSize = 1 << 20
+)
+
+// Discovery 身份提供方 /.well-known/openid-configuration 中用到的字段
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse 令牌端点的响应
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// IDTokenClaims ID Token 中关心的声明
+type IDTokenClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"-"` // 由 RawEmailVerified 解析，部分提供方返回字符串 "true"
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+
+	RawEmailVerified interface{} `json:"email_verified"`
+
+	jwt.RegisteredClaims
+}
+
+// Provider 封装与一个 OIDC 身份提供方的交互：发现配置、授权码换取令牌和 ID Token 校验
+type Provider struct {
+	issuer       string       // 身份提供方的 issuer，必须与发现文档和 ID Token 中的一致
+	clientID     string       // 客户端 ID
+	clientSecret string       // 客户端密钥，公共客户端可为空
+	redirectURL  string       // 回调地址
+	scopes       []string     // 请求的 scope
+	httpClient   *http.Client // 访问身份提供方使用的 HTTP 客户端
+
+	mu            sync.Mutex                // 保护下面的缓存
+	discovery     *Discovery                // 发现文档缓存
+	keys          map[string]*rsa.PublicKey // JWKS 缓存，key 为 kid
+	keysFetchedAt time.Time                 // 最近一次拉取 JWKS 的时间
+}
+
+// NewProvider 创建一个新的 Provider 实例，发现文档在首次使用时才拉取，身份提供方暂时不可用不会影响服务启动
+func NewProvider(issuer, clientID, clientSecret, redirectURL string, scopes []string) *Provider {
+	return &Provider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Issuer 返回身份提供方的 issuer，用于标识外部身份的来源
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// AuthCodeURL 生成跳转到身份提供方的授权地址（授权码模式 + PKCE S256）
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange 使用授权码和 PKCE verifier 换取令牌
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		// client_secret_basic，按 RFC 6749 要求先做 URL 编码
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var token TokenResponse
+	if err := p.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %v", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response does not contain an id_token")
+	}
+	return &token, nil
+}
+
+// VerifyIDToken 校验 ID Token 的签名、issuer、audience、有效期和 nonce，返回其中的声明
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.getKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %v", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: missing subject")
+	}
+
+	// 存在多个 audience 时，azp 必须是本客户端
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.clientID {
+		return nil, errors.New("invalid id_token: unexpected authorized party")
+	}
+
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	switch v := claims.RawEmailVerified.(type) {
+	case bool:
+		claims.EmailVerified = v
+	case string:
+		claims.EmailVerified = v == "true"
+	}
+
+	return claims, nil
+}
+
+// getDiscovery 获取发现文档，成功后缓存
+func (p *Provider) getDiscovery(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Discovery
+	if err := p.doJSON(req, &d); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC discovery document: %v", err)
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match configured issuer %q", d.Issuer, p.issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing required endpoints")
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// getKey 根据 kid 查找签名公钥，未命中时刷新一次 JWKS（处理密钥轮换）
+func (p *Provider) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < jwksMinRefreshInterval && p.keys != nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx, d.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey 在缓存中查找公钥，token 未携带 kid 且只有一把密钥时直接使用该密钥
+func (p *Provider) lookupKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// jsonWebKey JWKS 中单个密钥的字段
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// fetchKeys 拉取 JWKS 并解析其中用于签名的 RSA 公钥
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS does not contain any RSA signing keys")
+	}
+	return keys, nil
+}
+
+// doJSON 发送请求并将 2xx 响应解析为 JSON
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// RandomString 生成 URL 安全的随机字符串，用于 state、nonce 和 PKCE verifier
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// S256Challenge 根据 PKCE verifier 计算 S256 challenge
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTrackedKeys 记录的 key 超过该数量时触发一次全量清理，防止内存无限增长
+const maxTrackedKeys = 10000
+
+// Limiter 基于内存的滑动窗口限流器
+// 每个 key 在 window 时间内最多允许 limit 次请求
+type Limiter struct {
+	mu     sync.Mutex             // 保护 hits 的并发访问
+	hits   map[string][]time.Time // 每个 key 在窗口内的请求时间
+	limit  int                    // 窗口内允许的最大请求数
+	window time.Duration          // 窗口长度
+}
+
+// NewLimiter 创建一个新的 Limiter 实例
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		hits:   make(map[string][]time.Time),
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow 判断 key 是否还能继续请求，允许时记录本次请求
+// 返回值 retryAfter 表示被拒绝时需要等待的时长
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.hits) > maxTrackedKeys {
+		l.sweep(now)
+	}
+	hits := l.prune(key, now)
+
+	if len(hits) >= l.limit {
+		// 最早的一次请求滑出窗口后即可重试
+		return false, hits[0].Add(l.window).Sub(now)
+	}
+
+	l.hits[key] = append(hits, now)
+	return true, 0
+}
+
+// prune 清理 key 在窗口外的请求记录，并返回剩余记录
+func (l *Limiter) prune(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	cutoff := now.Add(-l.window)
+
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	hits = hits[i:]
+
+	if len(hits) == 0 {
+		delete(l.hits, key)
+		return nil
+	}
+	l.hits[key] = hits
+	return hits
+}
+
+// sweep 清理所有已经滑出窗口的 key
+func (l *Limiter) sweep(now time.Time) {
+	for key := range l.hits {
+		l.prune(key, now)
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrObjectNotFound 对象不存在
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrChecksumMismatch 上传内容与声明的校验和不符
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrUploadNotFound 分片上传不存在（已完成或已取消）
+	ErrUploadNotFound = errors.New("multipart upload not found")
+)
+
+// Backend 文件存储后端，由配置选择本地文件系统或 MinIO/S3
+// 对象名是后端内的相对路径，对外的访问地址由配置的公网基础地址加对象名组成
+type Backend interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, objectName string) error
+	// Stat 查询对象的大小和类型，对象不存在时返回 ErrObjectNotFound
+	Stat(ctx context.Context, objectName string) (*ObjectStat, error)
+	// URL 返回对象的访问地址
+	URL(objectName string) string
+	// ObjectName 从访问地址中取出对象名
+	ObjectName(fileURL string) string
+}
+
+// ObjectStat 对象的元数据
+type ObjectStat struct {
+	Size        int64
+	ContentType string
+}
+
+// Presigner 支持预签名 URL 的存储后端，客户端可以直接向存储上传和下载
+type Presigner interface {
+	PresignPut(ctx context.Context, objectName, contentType string, size int64, expires time.Duration) (string, http.Header, error)
+	PresignGet(ctx context.Context, objectName, filename string, expires time.Duration) (string, error)
+}
+
+// MultipartUploader 支持分片上传的存储后端
+type MultipartUploader interface {
+	NewMultipartUpload(ctx context.Context, objectName, contentType string) (string, error)
+	PutPart(ctx context.Context, objectName, uploadID string, number int, data io.Reader, size int64, sha256Hex string) (string, error)
+	CompleteMultipartUpload(ctx context.Context, objectName, uploadID string, parts []Part) error
+	AbortMultipartUpload(ctx context.Context, objectName, uploadID string) error
+}
+
+// Part 分片上传中已上传的一个分片
+type Part struct {
+	Number int
+	ETag   string
+}
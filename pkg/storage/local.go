@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalBackend 基于本地文件系统的存储后端，文件由 HTTP 服务以静态文件方式提供，不支持预签名 URL 和分片上传
+type LocalBackend struct {
+	root    string // 存放文件的根目录
+	baseURL string // 根目录对外的访问地址前缀
+}
+
+var _ Backend = (*LocalBackend)(nil)
+
+// NewLocalBackend 创建本地存储后端，根目录不存在时自动创建
+func NewLocalBackend(root, baseURL string) (*LocalBackend, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+	return &LocalBackend{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// path 把对象名转换为根目录下的文件路径，拒绝跳出根目录的对象名
+func (b *LocalBackend) path(objectName string) (string, error) {
+	cleaned := path.Clean("/" + objectName)
+	if cleaned == "/" {
+		return "", errors.New("invalid object name")
+	}
+	return filepath.Join(b.root, filepath.FromSlash(cleaned)), nil
+}
+
+// Put 写入文件，先写临时文件再重命名，避免读到写了一半的文件
+func (b *LocalBackend) Put(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	target, err := b.path(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if written != size {
+		return fmt.Errorf("failed to write file: expected %d bytes, got %d", size, written)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to save file: %v", err)
+	}
+	return nil
+}
+
+// Delete 删除文件，文件不存在时不返回错误
+func (b *LocalBackend) Delete(ctx context.Context, objectName string) error {
+	target, err := b.path(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Stat 查询文件的大小，类型按扩展名推断
+func (b *LocalBackend) Stat(ctx context.Context, objectName string) (*ObjectStat, error) {
+	target, err := b.path(objectName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return &ObjectStat{Size: info.Size(), ContentType: mime.TypeByExtension(filepath.Ext(target))}, nil
+}
+
+// URL 返回文件的访问地址
+func (b *LocalBackend) URL(objectName string) string {
+	return b.baseURL + "/" + objectName
+}
+
+// ObjectName 从访问地址中取出对象名
+func (b *LocalBackend) ObjectName(fileURL string) string {
+	return strings.TrimPrefix(fileURL, b.baseURL+"/")
+}
@@ -3,18 +3,35 @@ package storage
 import (
 	"context"
 	"fmt"
-	"mime/multipart"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// MinioClient 基于 MinIO/S3 的存储后端，支持预签名 URL 和分片上传
 type MinioClient struct {
 	client     *minio.Client
 	bucketName string
+	// presigner 生成预签名 URL 的客户端，未设置公网地址时与 client 相同
+	presigner *minio.Client
+	// baseURL 对象访问地址的前缀，为空时使用 /bucket
+	baseURL string
 }
 
-func NewMinioClient(endpoint, accessKey, secretKey, bucket string, useSSL bool) (*MinioClient, error) {
+var (
+	_ Backend           = (*MinioClient)(nil)
+	_ Presigner         = (*MinioClient)(nil)
+	_ MultipartUploader = (*MinioClient)(nil)
+)
+
+// NewMinioClient 创建 MinIO 存储后端，bucket 不存在时自动创建；baseURL 为空时访问地址为 /bucket/object
+func NewMinioClient(endpoint, accessKey, secretKey, bucket string, useSSL bool, baseURL string) (*MinioClient, error) {
 	// 创建MinIO客户端
 	client, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
@@ -37,35 +54,149 @@ func NewMinioClient(endpoint, accessKey, secretKey, bucket string, useSSL bool)
 		}
 	}
 
+	if baseURL == "" {
+		baseURL = "/" + bucket
+	}
 	return &MinioClient{
 		client:     client,
 		bucketName: bucket,
+		presigner:  client,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
 	}, nil
 }
 
-func (m *MinioClient) UploadFile(ctx context.Context, file *multipart.FileHeader, objectName string) (string, error) {
-	src, err := file.Open()
+// SetPublicEndpoint 使用客户端可访问的地址生成预签名 URL，签名包含 Host，必须与客户端实际请求的地址一致
+// 预签名只在本地计算，指定 region 避免向公网地址查询 bucket 所在区域
+func (m *MinioClient) SetPublicEndpoint(endpoint, accessKey, secretKey, region string, useSSL bool) error {
+	presigner, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
 	if err != nil {
-		return "", err
+		return fmt.Errorf("failed to create MinIO presign client: %v", err)
 	}
-	defer src.Close()
+	m.presigner = presigner
+	return nil
+}
 
-	// 上传文件
-	_, err = m.client.PutObject(ctx, m.bucketName, objectName, src, file.Size, minio.PutObjectOptions{
-		ContentType: file.Header.Get("Content-Type"),
+// Put 上传对象
+func (m *MinioClient) Put(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	_, err := m.client.PutObject(ctx, m.bucketName, objectName, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to upload file: %v", err)
+		return fmt.Errorf("failed to upload file: %v", err)
 	}
-
-	// 返回文件URL
-	return fmt.Sprintf("/%s/%s", m.bucketName, objectName), nil
+	return nil
 }
 
-func (m *MinioClient) DeleteFile(ctx context.Context, objectName string) error {
+// Delete 删除对象，对象不存在时 MinIO 不返回错误
+func (m *MinioClient) Delete(ctx context.Context, objectName string) error {
 	return m.client.RemoveObject(ctx, m.bucketName, objectName, minio.RemoveObjectOptions{})
 }
 
-func (m *MinioClient) GetBucketName() string {
-	return m.bucketName
+// PresignPut 生成上传对象的预签名 PUT URL，Content-Type 和 Content-Length 参与签名，
+// 客户端上传时必须带上返回的请求头，类型或大小不符时 MinIO 会拒绝上传
+func (m *MinioClient) PresignPut(ctx context.Context, objectName, contentType string, size int64, expires time.Duration) (string, http.Header, error) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	headers.Set("Content-Length", strconv.FormatInt(size, 10))
+
+	u, err := m.presigner.PresignHeader(ctx, http.MethodPut, m.bucketName, objectName, expires, nil, headers)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to presign upload: %v", err)
+	}
+	return u.String(), headers, nil
+}
+
+// PresignGet 生成下载对象的预签名 GET URL，filename 不为空时浏览器以该文件名下载
+func (m *MinioClient) PresignGet(ctx context.Context, objectName, filename string, expires time.Duration) (string, error) {
+	params := url.Values{}
+	if filename != "" {
+		params.Set("response-content-disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(filename)))
+	}
+
+	u, err := m.presigner.PresignedGetObject(ctx, m.bucketName, objectName, expires, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download: %v", err)
+	}
+	return u.String(), nil
+}
+
+// Stat 查询对象的大小和类型，对象不存在时返回 ErrObjectNotFound
+func (m *MinioClient) Stat(ctx context.Context, objectName string) (*ObjectStat, error) {
+	info, err := m.client.StatObject(ctx, m.bucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %v", err)
+	}
+	return &ObjectStat{Size: info.Size, ContentType: info.ContentType}, nil
+}
+
+// NewMultipartUpload 创建分片上传，返回 MinIO 的 upload ID
+func (m *MinioClient) NewMultipartUpload(ctx context.Context, objectName, contentType string) (string, error) {
+	core := minio.Core{Client: m.client}
+	uploadID, err := core.NewMultipartUpload(ctx, m.bucketName, objectName, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %v", err)
+	}
+	return uploadID, nil
+}
+
+// PutPart 上传一个分片，sha256Hex 参与签名，内容与校验和不符时 MinIO 会拒绝该分片；返回分片的 ETag
+func (m *MinioClient) PutPart(ctx context.Context, objectName, uploadID string, number int, data io.Reader, size int64, sha256Hex string) (string, error) {
+	core := minio.Core{Client: m.client}
+	part, err := core.PutObjectPart(ctx, m.bucketName, objectName, uploadID, number, data, size, minio.PutObjectPartOptions{Sha256Hex: sha256Hex})
+	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "XAmzContentSHA256Mismatch", "BadDigest":
+			return "", ErrChecksumMismatch
+		case "NoSuchUpload":
+			return "", ErrUploadNotFound
+		}
+		return "", fmt.Errorf("failed to upload part %d: %v", number, err)
+	}
+	return part.ETag, nil
+}
+
+// CompleteMultipartUpload 按分片序号合并分片，生成最终对象
+func (m *MinioClient) CompleteMultipartUpload(ctx context.Context, objectName, uploadID string, parts []Part) error {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+
+	core := minio.Core{Client: m.client}
+	if _, err := core.CompleteMultipartUpload(ctx, m.bucketName, objectName, uploadID, completeParts, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %v", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload 取消分片上传并删除已上传的分片，upload 已不存在时不返回错误
+func (m *MinioClient) AbortMultipartUpload(ctx context.Context, objectName, uploadID string) error {
+	core := minio.Core{Client: m.client}
+	if err := core.AbortMultipartUpload(ctx, m.bucketName, objectName, uploadID); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+			return nil
+		}
+		return fmt.Errorf("failed to abort multipart upload: %v", err)
+	}
+	return nil
+}
+
+// URL 返回对象的访问地址
+func (m *MinioClient) URL(objectName string) string {
+	return m.baseURL + "/" + objectName
+}
+
+// ObjectName 从访问地址中取出对象名，兼容配置公网基础地址之前保存的 /bucket/object 格式
+func (m *MinioClient) ObjectName(fileURL string) string {
+	if name := strings.TrimPrefix(fileURL, m.baseURL+"/"); name != fileURL {
+		return name
+	}
+	return strings.TrimPrefix(fileURL, "/"+m.bucketName+"/")
 }
@@ -3,15 +3,15 @@ package websocketM
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"chatweb/internal/model"
 	"chatweb/internal/service"
 
-	"chatweb/internal/repository"
-
 	"github.com/gorilla/websocket"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -30,6 +30,10 @@ const (
 	MessageTypeOnline       = "online"
 	MessageTypeRead         = "read"
 	MessageTypeGroupRead    = "group_read"
+	MessageTypeUserUpdated  = "user_updated"
+	MessageTypeForceLogout  = "force_logout"
+	MessageTypeError        = "error"
+	MessageTypeGroupUpdated = "group_updated"
 )
 
 // Client 代表一个 WebSocket 连接的客户端
@@ -42,6 +46,11 @@ type Client struct {
 	id             string                  // 客户端的用户 ID
 	onlineService  *service.OnlineService  // 在线状态服务
 	messageService *service.MessageService // 消息服务
+
+	sendMu     sync.Mutex    // 保护 send 的写入和关闭
+	sendClosed bool          // send 是否已关闭
+	stop       chan struct{} // 关闭后 WritePump 发完排队的消息并断开连接
+	stopOnce   sync.Once
 }
 type MessageType string
 
@@ -56,16 +65,20 @@ type ReplyMessage struct {
 
 // Message 结构体用于解析 WebSocket 消息
 type Message struct {
-	Type       MessageType        `bson:"type" json:"type"`                             // 消息类型（文本、图片、文件）
-	Content    string             `bson:"content" json:"content"`                       // 消息内容
-	SenderID   primitive.ObjectID `bson:"sender_id" json:"sender_id"`                   // 发送者的用户 ID
-	ReceiverID primitive.ObjectID `bson:"receiver_id" json:"receiver_id"`               // 接收者的用户 ID
-	GroupID    primitive.ObjectID `bson:"group_id,omitempty" json:"group_id,omitempty"` // 群组 ID（如果是群消息）
-	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`                 // 消息发送时间
-	Sender     string             `bson:"sender" json:"sender"`                         // 发送者 name
-	Receiver   string             `bson:"receiver" json:"receiver"`                     // 接收者 name
-	FileName   string             `bson:"filename" json:"filename"`                     // 文件名称
-	Reply      []ReplyMessage     `bson:"reply" json:"reply"`                           // 被引用的消息列表（数组）
+	Type       MessageType          `bson:"type" json:"type"`                                   // 消息类型（文本、图片、文件）
+	Content    string               `bson:"content" json:"content"`                             // 消息内容
+	SenderID   primitive.ObjectID   `bson:"sender_id" json:"sender_id"`                         // 发送者的用户 ID
+	ReceiverID primitive.ObjectID   `bson:"receiver_id" json:"receiver_id"`                     // 接收者的用户 ID
+	GroupID    primitive.ObjectID   `bson:"group_id,omitempty" json:"group_id,omitempty"`       // 群组 ID（如果是群消息）
+	ChannelID  primitive.ObjectID   `bson:"channel_id,omitempty" json:"channel_id,omitempty"`   // 群频道 ID，为空时发到默认频道
+	CreatedAt  time.Time            `bson:"created_at" json:"created_at"`                       // 消息发送时间
+	Sender     string               `bson:"sender" json:"sender"`                               // 发送者 name
+	Receiver   string               `bson:"receiver" json:"receiver"`                           // 接收者 name
+	FileName   string               `bson:"filename" json:"filename"`                           // 文件名称
+	FileID     primitive.ObjectID   `bson:"file_id,omitempty" json:"file_id,omitempty"`         // 图片和文件消息引用的文件 ID
+	Reply      []ReplyMessage       `bson:"reply" json:"reply"`                                 // 被引用的消息列表（数组）
+	Mentions   []primitive.ObjectID `bson:"mentions,omitempty" json:"mentions,omitempty"`       // 被 @ 的用户 ID（服务端解析）
+	MentionAll bool                 `bson:"mention_all,omitempty" json:"mention_all,omitempty"` // 是否 @all
 }
 
 // OnlineStatusMessage 结构体用于用户在线状态的消息
@@ -104,23 +117,59 @@ var defaultUpgrader = websocket.Upgrader{
 }
 
 // NewClient 创建新的 WebSocket 客户端
-func NewClient(hub *Hub, conn *websocket.Conn, userID string, onlineService *service.OnlineService) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID string, onlineService *service.OnlineService, messageService *service.MessageService) *Client {
 	return &Client{
-		hub:           hub,
-		conn:          conn,
-		send:          make(chan []byte, 256),
-		id:            userID,
-		onlineService: onlineService,
+		hub:            hub,
+		conn:           conn,
+		send:           make(chan []byte, 256),
+		id:             userID,
+		onlineService:  onlineService,
+		messageService: messageService,
+		stop:           make(chan struct{}),
+	}
+}
+
+// trySend 把消息放入发送队列，send 已关闭或队列已满时返回 false
+func (c *Client) trySend(message []byte) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.sendClosed {
+		return false
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
 	}
 }
 
+// closeSend 关闭发送队列，可以重复调用
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.send)
+	}
+}
+
+// disconnect 通知 WritePump 发完已排队的消息后关闭连接，ReadPump 随之退出并注销客户端
+func (c *Client) disconnect() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
 // ReadPump 监听 WebSocket 连接的读取操作
 // 处理消息并将其转发到相应的处理器
 func (c *Client) ReadPump() {
 	defer func() {
-		c.hub.Unregister(c)
-		if err := c.onlineService.SetUserOffline(context.Background(), c.id); err != nil {
-			log.Printf("Failed to set user offline: %v", err)
+		// 同一用户已建立新连接时不改变在线状态
+		if c.hub.Unregister(c) {
+			if err := c.onlineService.SetUserOffline(context.Background(), c.id); err != nil {
+				log.Printf("Failed to set user offline: %v", err)
+			}
 		}
 		c.conn.Close()
 	}()
@@ -155,13 +204,11 @@ func (c *Client) ReadPump() {
 // 包括定期发送心跳包以保持连接活跃
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
-	// defer func() {
-	// 	ticker.Stop()
-	// 	if err := c.onlineService.SetUserOffline(context.Background(), c.id); err != nil {
-	// 		log.Printf("Failed to set user offline: %v", err)
-	// 	}
-	// 	c.conn.Close()
-	// }()
+	// 写入失败或被断开时关闭连接，让 ReadPump 退出并注销客户端
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
 
 	for {
 		select {
@@ -179,6 +226,13 @@ func (c *Client) WritePump() {
 			w.Write(message)
 			w.Close()
 
+		case <-c.stop:
+			// 发完已排队的消息（例如强制下线通知）后关闭连接
+			c.flush()
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -188,19 +242,39 @@ func (c *Client) WritePump() {
 	}
 }
 
+// flush 写出发送队列中已有的消息，写入失败时放弃剩余的消息
+func (c *Client) flush() {
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 // 处理不同类型的 WebSocket 消息
 func (c *Client) handleMessage(msg Message) {
 	c.handleChatMessage(msg)
 }
 
-// 处理聊天消息，广播给所有客户端
+// 处理聊天消息，私聊发送给接收者，群消息发送给所在频道的成员
 func (c *Client) handleChatMessage(msg Message) {
-	messageBytes, err := json.Marshal(msg)
+	// 发送者以连接身份为准，不信任客户端上报的 sender_id
+	senderID, err := primitive.ObjectIDFromHex(c.id)
 	if err != nil {
-		log.Printf("error marshaling message: %v", err)
+		c.sendError("invalid sender")
 		return
 	}
-	log.Printf(msg.Content, msg.SenderID)
+	msg.SenderID = senderID
+
 	log.Printf("hanleChatMessage: %v", msg)
 
 	// 构建消息对象
@@ -209,16 +283,12 @@ func (c *Client) handleChatMessage(msg Message) {
 		Content:    msg.Content,
 		SenderID:   msg.SenderID,
 		ReceiverID: msg.ReceiverID,
+		GroupID:    msg.GroupID,
+		ChannelID:  msg.ChannelID,
 		Sender:     msg.Sender,
 		Receiver:   msg.Receiver,
 		FileName:   msg.FileName,
-		Status:     "sent",
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-	}
-
-	if msg.GroupID.String() != "" {
-		message.GroupID = msg.GroupID
+		FileID:     msg.FileID,
 	}
 
 	log.Print("msg.Reply", len(msg.Reply))
@@ -236,33 +306,69 @@ func (c *Client) handleChatMessage(msg Message) {
 		message.Reply = convertedReplies
 	}
 
-	repository.NewMessageRepository().Create(context.Background(), message)
-	// 如果有接收者ID，发送给目标客户端
-	if msg.ReceiverID.String() != "" && msg.ReceiverID != primitive.NilObjectID {
-		log.Println(c.hub.clients)
-		log.Println(msg.ReceiverID)
-		log.Println(msg.ReceiverID.Hex())
-		id := msg.ReceiverID.Hex()
-		recipientClient, ok := c.hub.clients[id]
-		if ok {
-			recipientClient.send <- messageBytes
-		} else {
-			log.Printf("Recipient %s not found", msg.ReceiverID.String())
+	if err := c.messageService.SendMessage(context.Background(), message); err != nil {
+		log.Printf("Failed to send message from %s: %v", c.id, err)
+		var postErr *service.GroupPostError
+		if errors.As(err, &postErr) {
+			// 群发言限制附带错误码和等待时间，方便客户端提示和倒计时
+			c.sendErrorFrame(map[string]interface{}{
+				"message":     err.Error(),
+				"code":        postErr.Code,
+				"group_id":    msg.GroupID.Hex(),
+				"retry_after": postErr.RetryAfterSeconds(),
+			})
+			return
 		}
-	} else if msg.GroupID.String() != "" {
-		// 如果有群组ID，发送给群组内所有客户端
-		for _, client := range c.hub.clients {
-			if client.id == c.id {
-				continue
-			}
-			if client.id == c.id {
-				continue
-			}
-			client.send <- messageBytes
+		c.sendError(err.Error())
+		return
+	}
+	msg.CreatedAt = message.CreatedAt
+	msg.Content = message.Content
+	msg.FileName = message.FileName
+	msg.ChannelID = message.ChannelID
+	msg.Mentions = message.Mentions
+	msg.MentionAll = message.MentionAll
+
+	messageBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("error marshaling message: %v", err)
+		return
+	}
+
+	if !msg.GroupID.IsZero() {
+		// 群消息只发送给所在频道的在线成员
+		recipients, err := c.messageService.GroupMessageRecipients(context.Background(), message)
+		if err != nil {
+			log.Printf("Failed to resolve recipients of message %s: %v", message.ID.Hex(), err)
+			return
 		}
+		c.hub.BroadcastToUsers(recipients, messageBytes)
 	} else {
-		// 否则广播给所有客户端
-		c.hub.broadcast <- messageBytes
+		// 私聊消息只发送给接收者
+		c.hub.SendToUser(msg.ReceiverID.Hex(), messageBytes)
+	}
+}
+
+// sendError 向当前客户端发送错误帧
+func (c *Client) sendError(message string) {
+	c.sendErrorFrame(map[string]interface{}{"message": message})
+}
+
+// sendErrorFrame 向当前客户端发送内容为 content 的错误帧
+func (c *Client) sendErrorFrame(content map[string]interface{}) {
+	frame := struct {
+		Type    string                 `json:"type"`
+		Content map[string]interface{} `json:"content"`
+	}{
+		Type:    MessageTypeError,
+		Content: content,
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return
+	}
+	if !c.trySend(data) {
+		log.Printf("Send buffer full for user %s, dropping error frame", c.id)
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"sync"
 
+	"chatweb/internal/model"
 	"chatweb/pkg/event"
 )
 
@@ -35,7 +36,7 @@ func NewHub(eventBus *event.EventBus) *Hub {
 
 // subscribeToEvents 订阅事件总线中的相关事件并处理消息
 func (h *Hub) subscribeToEvents() {
-	// 订阅消息已读事件
+	// 订阅消息已读事件，只推送给消息的发送者
 	h.eventBus.Subscribe(event.MessageRead, func(e event.Event) {
 		if content, ok := e.Content.(event.MessageReadContent); ok {
 			// 构造消息格式
@@ -46,14 +47,14 @@ func (h *Hub) subscribeToEvents() {
 				Type:    "read",
 				Content: content,
 			}
-			// 将消息序列化并广播
+			// 将消息序列化并推送，阅读者关闭了已读回执时不会发布该事件
 			if messageBytes, err := json.Marshal(msg); err == nil {
-				h.broadcast <- messageBytes
+				h.BroadcastToUsers(content.Recipients, messageBytes)
 			}
 		}
 	})
 
-	// 订阅群聊已读事件
+	// 订阅群聊已读事件，只推送给消息的发送者
 	h.eventBus.Subscribe(event.GroupRead, func(e event.Event) {
 		if content, ok := e.Content.(event.GroupReadContent); ok {
 			// 构造消息格式
@@ -64,9 +65,74 @@ func (h *Hub) subscribeToEvents() {
 				Type:    "group_read",
 				Content: content,
 			}
-			// 将消息序列化并广播
+			// 将消息序列化并推送，阅读者关闭了已读回执时不会发布该事件
 			if messageBytes, err := json.Marshal(msg); err == nil {
-				h.broadcast <- messageBytes
+				h.BroadcastToUsers(content.Recipients, messageBytes)
+			}
+		}
+	})
+
+	// 订阅通知事件，只推送给通知的接收者
+	h.eventBus.Subscribe(event.Notification, func(e event.Event) {
+		if notification, ok := e.Content.(*model.Notification); ok {
+			msg := struct {
+				Type    string              `json:"type"`
+				Content *model.Notification `json:"content"`
+			}{
+				Type:    MessageTypeNotification,
+				Content: notification,
+			}
+			if messageBytes, err := json.Marshal(msg); err == nil {
+				h.SendToUser(notification.UserID.Hex(), messageBytes)
+			}
+		}
+	})
+
+	// 订阅用户资料更新事件，只推送给该用户的好友
+	h.eventBus.Subscribe(event.UserUpdated, func(e event.Event) {
+		if content, ok := e.Content.(event.UserUpdatedContent); ok {
+			msg := struct {
+				Type    string                   `json:"type"`
+				Content event.UserUpdatedContent `json:"content"`
+			}{
+				Type:    MessageTypeUserUpdated,
+				Content: content,
+			}
+			if messageBytes, err := json.Marshal(msg); err == nil {
+				h.BroadcastToUsers(content.Recipients, messageBytes)
+			}
+		}
+	})
+
+	// 订阅会话撤销事件，通知客户端后断开该用户的连接
+	h.eventBus.Subscribe(event.SessionRevoked, func(e event.Event) {
+		if content, ok := e.Content.(event.SessionRevokedContent); ok {
+			msg := struct {
+				Type    string                      `json:"type"`
+				Content event.SessionRevokedContent `json:"content"`
+			}{
+				Type:    MessageTypeForceLogout,
+				Content: content,
+			}
+			if messageBytes, err := json.Marshal(msg); err == nil {
+				h.SendToUser(content.UserID, messageBytes)
+			}
+			h.DisconnectUser(content.UserID)
+		}
+	})
+
+	// 订阅群组变动事件，推送给群成员
+	h.eventBus.Subscribe(event.GroupUpdated, func(e event.Event) {
+		if content, ok := e.Content.(event.GroupUpdatedContent); ok {
+			msg := struct {
+				Type    string                    `json:"type"`
+				Content event.GroupUpdatedContent `json:"content"`
+			}{
+				Type:    MessageTypeGroupUpdated,
+				Content: content,
+			}
+			if messageBytes, err := json.Marshal(msg); err == nil {
+				h.BroadcastToUsers(content.Recipients, messageBytes)
 			}
 		}
 	})
@@ -83,35 +149,56 @@ func (h *Hub) Run() {
 			h.mu.RLock()
 			// 遍历所有客户端并将消息发送过去
 			for _, client := range h.clients {
-				select {
-				case client.send <- message: // 如果客户端连接正常，发送消息
-				default:
-					// 如果客户端连接异常，关闭连接并从 clients 中删除
-					close(client.send)
-					delete(h.clients, client.id)
-				}
+				h.deliver(client, message)
 			}
 			h.mu.RUnlock()
 		}
 	}
 }
 
-// Register 将客户端注册到 Hub 中
+// deliver 把消息放入客户端的发送队列，队列已满时断开该连接，由连接自身的 ReadPump 注销
+func (h *Hub) deliver(client *Client, message []byte) {
+	if !client.trySend(message) {
+		client.disconnect()
+	}
+}
+
+// Register 将客户端注册到 Hub 中，同一用户之前的连接会被断开
 func (h *Hub) Register(client *Client) {
 	h.mu.Lock() // 获取写锁
+	previous := h.clients[client.id]
 	h.clients[client.id] = client
 	h.mu.Unlock() // 释放写锁
+
+	if previous != nil && previous != client {
+		previous.disconnect()
+	}
 }
 
-// Unregister 从 Hub 中注销客户端
-func (h *Hub) Unregister(client *Client) {
+// Unregister 从 Hub 中注销客户端并关闭其发送通道
+// 只有 client 仍是该用户当前的连接时才从 clients 中删除，返回是否删除
+func (h *Hub) Unregister(client *Client) bool {
 	h.mu.Lock() // 获取写锁
-	if _, ok := h.clients[client.id]; ok {
-		// 删除客户端并关闭发送通道
+	current, ok := h.clients[client.id]
+	removed := ok && current == client
+	if removed {
 		delete(h.clients, client.id)
-		close(client.send)
 	}
 	h.mu.Unlock() // 释放写锁
+
+	client.closeSend()
+	return removed
+}
+
+// DisconnectUser 断开指定用户的连接，已排队的消息发送完后连接关闭
+func (h *Hub) DisconnectUser(userID string) {
+	h.mu.RLock() // 获取读锁
+	client, ok := h.clients[userID]
+	h.mu.RUnlock() // 释放读锁
+
+	if ok {
+		client.disconnect()
+	}
 }
 
 // SendToUser 发送定向消息给指定用户
@@ -119,13 +206,7 @@ func (h *Hub) SendToUser(userID string, message []byte) {
 	h.mu.RLock() // 获取读锁
 	if client, ok := h.clients[userID]; ok {
 		// 如果该用户存在，发送消息
-		select {
-		case client.send <- message:
-		default:
-			// 如果客户端连接异常，关闭连接并从 clients 中删除
-			close(client.send)
-			delete(h.clients, client.id)
-		}
+		h.deliver(client, message)
 	}
 	h.mu.RUnlock() // 释放读锁
 }
@@ -136,13 +217,7 @@ func (h *Hub) BroadcastToUsers(userIDs []string, message []byte) {
 	for _, userID := range userIDs {
 		// 遍历用户列表，向每个用户发送消息
 		if client, ok := h.clients[userID]; ok {
-			select {
-			case client.send <- message:
-			default:
-				// 如果客户端连接异常，关闭连接并从 clients 中删除
-				close(client.send)
-				delete(h.clients, client.id)
-			}
+			h.deliver(client, message)
 		}
 	}
 	h.mu.RUnlock() // 释放读锁
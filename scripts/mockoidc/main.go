@@ -0,0 +1,212 @@
+// mockoidc 是一个用于本地联调 SSO 登录的最小 OIDC 身份提供方，不做任何身份认证，授权请求会被直接批准。
+//
+// 用法：
+//
+//	go run ./scripts/mockoidc -addr :9090 -client-id chatweb -email alice@example.com
+//
+// 然后在 config.yaml 中设置 oidc.enabled=true、oidc.issuer=http://localhost:9090。
+// 授权地址可以附带 login_hint=<邮箱> 以不同用户身份登录。
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-key-1"
+
+// authRequest 已批准但尚未换取令牌的授权请求
+type authRequest struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	expiresAt   time.Time
+}
+
+type server struct {
+	issuer   string
+	clientID string
+	email    string
+	name     string
+	key      *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*authRequest
+}
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9090", "issuer URL, must match oidc.issuer in config.yaml")
+	clientID := flag.String("client-id", "chatweb", "expected client_id")
+	email := flag.String("email", "alice@example.com", "default email of the signed-in user")
+	name := flag.String("name", "Alice", "display name of the signed-in user")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	s := &server{
+		issuer:   strings.TrimSuffix(*issuer, "/"),
+		clientID: *clientID,
+		email:    *email,
+		name:     *name,
+		key:      key,
+		codes:    make(map[string]*authRequest),
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	http.HandleFunc("/authorize", s.authorize)
+	http.HandleFunc("/token", s.token)
+	http.HandleFunc("/jwks", s.jwks)
+
+	log.Printf("Mock OIDC provider listening on %s (issuer %s)", *addr, s.issuer)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize 直接批准授权请求并带着授权码跳回 redirect_uri
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.clientID || q.Get("response_type") != "code" {
+		http.Error(w, "invalid client_id or response_type", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		email = s.email
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = &authRequest{
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		email:       email,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token 校验授权码和 PKCE verifier，签发 ID Token
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.mu.Lock()
+	req, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(req.expiresAt) || req.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                s.issuer,
+		"sub":                "mock|" + req.email,
+		"aud":                s.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              req.nonce,
+		"email":              req.email,
+		"email_verified":     true,
+		"name":               s.name,
+		"preferred_username": strings.SplitN(req.email, "@", 2)[0],
+	})
+	idToken.Header["kid"] = keyID
+
+	signed, err := idToken.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}